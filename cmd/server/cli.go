@@ -0,0 +1,145 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Ragnar-BY/companies-handler/internal/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const usage = `Usage: server [flags] [command]
+
+Commands:
+  import     import companies from file, see "server import -h"
+  migrate    apply or roll back migrations of postgres, see "server migrate"
+
+Server is run if command is not given. Flags override environment variables,
+which override config file (.env, YAML or TOML). Config is reloaded on SIGHUP,
+settings which can not be changed without restart keep their values.
+
+Flags:
+`
+
+// cli is command line of server
+type cli struct {
+	fs *flag.FlagSet
+
+	configPath  string
+	printConfig bool
+
+	logFile       string
+	logMaxSize    int
+	logMaxBackups int
+	logMaxAge     int
+	logLevel      string
+	logFormat     string
+	address       string
+}
+
+// parseCLI parses flags of command line, arguments after flags are command and its arguments
+func parseCLI(args []string, output io.Writer) (*cli, error) {
+	c := &cli{fs: flag.NewFlagSet("server", flag.ContinueOnError)}
+	c.fs.SetOutput(output)
+	c.fs.Usage = func() {
+		fmt.Fprint(output, usage)
+		c.fs.PrintDefaults()
+	}
+	c.fs.StringVar(&c.configPath, "config", ".env", "config file, environment variables are used if it does not exist")
+	c.fs.BoolVar(&c.printConfig, "print-config", false, "print effective config with redacted secrets and exit")
+	c.fs.StringVar(&c.logFile, "log", "", "file of logs, rotated by size (LOG_FILE), stdout if empty")
+	c.fs.IntVar(&c.logMaxSize, "log-max-size", 0, "size of log file in megabytes which triggers rotation (LOG_MAX_SIZE)")
+	c.fs.IntVar(&c.logMaxBackups, "log-max-backups", 0, "number of rotated log files kept (LOG_MAX_BACKUPS)")
+	c.fs.IntVar(&c.logMaxAge, "log-max-age", 0, "days rotated log files are kept (LOG_MAX_AGE)")
+	c.fs.StringVar(&c.logLevel, "log-level", "", "minimal level of logs: debug, info, warn or error (LOG_LEVEL)")
+	c.fs.StringVar(&c.logFormat, "log-format", "", "format of logs: json or console (LOG_FORMAT)")
+	c.fs.StringVar(&c.address, "addr", "", "listen address of server (SERVER_ADDRESS)")
+	if err := c.fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// isSet checks if flag is given in command line
+func (c *cli) isSet(name string) bool {
+	set := false
+	c.fs.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+	return set
+}
+
+// loadConfig loads config from config file and environment and overrides it with flags given in command line.
+// Config file given in command line must exist.
+func (c *cli) loadConfig() (config.Config, error) {
+	if c.isSet("config") {
+		if _, err := os.Stat(c.configPath); err != nil {
+			return config.Config{}, err
+		}
+	}
+	return config.LoadConfig(c.configPath, c.override)
+}
+
+// override sets settings given by flags in command line
+func (c *cli) override(cfg *config.Config) {
+	c.fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "log":
+			cfg.Log.File = c.logFile
+		case "log-max-size":
+			cfg.Log.MaxSize = c.logMaxSize
+		case "log-max-backups":
+			cfg.Log.MaxBackups = c.logMaxBackups
+		case "log-max-age":
+			cfg.Log.MaxAge = c.logMaxAge
+		case "log-level":
+			cfg.Log.Level = c.logLevel
+		case "log-format":
+			cfg.Log.Format = c.logFormat
+		case "addr":
+			cfg.Server.Address = c.address
+		}
+	})
+}
+
+// command returns command and its arguments, command is empty to run server
+func (c *cli) command() (string, []string) {
+	args := c.fs.Args()
+	if len(args) == 0 {
+		return "", nil
+	}
+	return args[0], args[1:]
+}
+
+// newLogger creates logger writing to log file or stdout as configured.
+// Returned level can be changed while logger is used.
+func newLogger(cfg config.Config) (*zap.Logger, zap.AtomicLevel, error) {
+	level, err := zap.ParseAtomicLevel(cfg.Log.Level)
+	if err != nil {
+		return nil, level, err
+	}
+	var encoder zapcore.Encoder
+	switch cfg.Log.Format {
+	case config.LogFormatJSON:
+		encoder = zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	case config.LogFormatConsole:
+		encoder = zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig())
+	default:
+		return nil, level, fmt.Errorf("unknown log format %q", cfg.Log.Format)
+	}
+	out := zapcore.Lock(os.Stdout)
+	if cfg.Log.File != "" {
+		out = zapcore.AddSync(&lumberjack.Logger{
+			Filename:   cfg.Log.File,
+			MaxSize:    cfg.Log.MaxSize,
+			MaxBackups: cfg.Log.MaxBackups,
+			MaxAge:     cfg.Log.MaxAge,
+		})
+	}
+	core := zapcore.NewCore(encoder, out, level)
+	return zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel)), level, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCLILoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	require.NoError(t, os.WriteFile(path, []byte("SERVER_ADDRESS=:8080\nLOG_FORMAT=console\n"), 0o600))
+	t.Setenv("SERVER_ADDRESS", ":9000")
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("STORAGE", "memory")
+	t.Setenv("JWT_KEY", "key")
+	// variable set by file is restored on cleanup
+	t.Setenv("LOG_FORMAT", "")
+	require.NoError(t, os.Unsetenv("LOG_FORMAT"))
+
+	cmd, err := parseCLI([]string{"-config", path, "-addr", ":9100", "migrate", "up"}, io.Discard)
+	require.NoError(t, err)
+	cfg, err := cmd.loadConfig()
+	require.NoError(t, err)
+	// flags override environment, which overrides file
+	require.Equal(t, ":9100", cfg.Server.Address)
+	require.Equal(t, "warn", cfg.Log.Level)
+	require.Equal(t, "console", cfg.Log.Format)
+
+	name, args := cmd.command()
+	require.Equal(t, "migrate", name)
+	require.Equal(t, []string{"up"}, args)
+
+	// flags are validated
+	cmd, err = parseCLI([]string{"-config", path, "-log-level", "verbose"}, io.Discard)
+	require.NoError(t, err)
+	_, err = cmd.loadConfig()
+	require.ErrorContains(t, err, "LOG_LEVEL must be one of")
+
+	// config file given in command line must exist
+	cmd, err = parseCLI([]string{"-config", filepath.Join(t.TempDir(), "missing.env")}, io.Discard)
+	require.NoError(t, err)
+	_, err = cmd.loadConfig()
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Ragnar-BY/companies-handler/internal/broker"
+	"github.com/Ragnar-BY/companies-handler/internal/config"
+	"github.com/Ragnar-BY/companies-handler/internal/controllers/rest"
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/cache"
+	"github.com/Ragnar-BY/companies-handler/internal/service"
+	"github.com/Ragnar-BY/companies-handler/internal/usecase"
+	"go.uber.org/zap"
+)
+
+// runImport imports companies from file, usage:
+//
+//	server import -file companies.csv [-format csv|ndjson] [-organization 1] [-dry-run]
+func runImport(args []string, cfg config.Config, logger *zap.Logger) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	file := fs.String("file", "-", "file to import, - is stdin")
+	format := fs.String("format", "", "format of file, csv or ndjson, detected by extension of file if empty")
+	organization := fs.Int64("organization", domain.DefaultOrganizationID, "id of organization to import companies into")
+	dryRun := fs.Bool("dry-run", false, "only validate file")
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return fmt.Errorf("can not open file: %w", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(*file), ".")
+		if *format == "jsonl" {
+			*format = rest.ImportFormatNDJSON
+		}
+	}
+	reader, err := rest.NewCompanyReader(in, *format)
+	if err != nil {
+		return err
+	}
+
+	store, err := newStorage(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("can not open storage: %w", err)
+	}
+	defer store.Close()
+
+	// companies cached by server instances in Redis are invalidated after import, in-process caches of
+	// instances are not reachable and expire after their ttl
+	var companyOpts []usecase.CompanyOption
+	if cfg.Redis.Address != "" {
+		backend := newCacheBackend(cfg, logger)
+		if closer, ok := backend.(io.Closer); ok {
+			defer closer.Close()
+		}
+		companyOpts = append(companyOpts, usecase.WithCompanyCache(cache.NewCompanyCache(store, backend, cfg.Cache.TTL, logger)))
+	}
+	companyUsecase := usecase.NewCompanyUsecase(service.NewCompanyService(store), service.NewEventSender(broker.NewBroker()),
+		companyOpts...)
+	ctx := domain.WithTenant(context.Background(), *organization)
+	result, err := companyUsecase.Import(ctx, reader, *dryRun)
+	if err != nil {
+		return err
+	}
+	report := reader.Report()
+	report.DryRun = *dryRun
+	report.Created = result.Created
+	report.Updated = result.Updated
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
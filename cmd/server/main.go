@@ -2,48 +2,285 @@ package main
 
 import (
 	"context"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/Ragnar-BY/companies-handler/internal/broker"
 	"github.com/Ragnar-BY/companies-handler/internal/config"
 	"github.com/Ragnar-BY/companies-handler/internal/controllers/rest"
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/cache"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/instrumented"
 	"github.com/Ragnar-BY/companies-handler/internal/repository/postgres"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/ratelimit"
 	"github.com/Ragnar-BY/companies-handler/internal/service"
 	"github.com/Ragnar-BY/companies-handler/internal/usecase"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"go.uber.org/zap"
 )
 
 func main() {
-	logger, _ := zap.NewProduction()
-
-	cfg, err := config.LoadConfig(".env")
+	cmd, err := parseCLI(os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+	cfg, err := cmd.loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "can not load config:", err)
+		os.Exit(1)
+	}
+	if cmd.printConfig {
+		for _, line := range cfg.Dump() {
+			fmt.Println(line)
+		}
+		return
+	}
+	logger, logLevel, err := newLogger(cfg)
 	if err != nil {
-		logger.Fatal("can not load config", zap.Error(err))
+		fmt.Fprintln(os.Stderr, "can not create logger:", err)
+		os.Exit(1)
 	}
+	defer func() { _ = logger.Sync() }()
+	// logger of context falls back to global one outside of requests
+	zap.ReplaceGlobals(logger)
 
-	pgSettings := postgres.PostgresSettings{
-		Addr:     cfg.PostgresAddress,
-		Username: cfg.PostgresUser,
-		Password: cfg.PostgresPassword,
-		Database: cfg.PostgresDB,
+	switch name, args := cmd.command(); name {
+	case "":
+	case "import":
+		if err := runImport(args, cfg, logger); err != nil {
+			logger.Fatal("can not import companies", zap.Error(err))
+		}
+		return
+	case "migrate":
+		if err := runMigrate(args, cfg, logger); err != nil {
+			logger.Fatal("can not migrate database", zap.Error(err))
+		}
+		return
+	default:
+		logger.Fatal("unknown command, see \"server -h\"", zap.String("command", name))
 	}
-	dbClient, err := postgres.NewPostgresClient(pgSettings)
+
+	shutdownTracing, err := setupTracing(cfg)
 	if err != nil {
-		logger.Fatal("can not connect to database", zap.Error(err))
+		logger.Fatal("can not set up tracing", zap.Error(err))
 	}
+
+	store, err := newStorage(cfg, logger)
+	if err != nil {
+		logger.Fatal("can not open storage", zap.String("storage", cfg.Storage.Type), zap.Error(err))
+	}
+	if dbClient, ok := store.(*postgres.PostgresClient); ok {
+		if err := prepareSchema(dbClient, cfg.Postgres.MigrateOnStart); err != nil {
+			logger.Fatal("database schema is not ready, run \"server migrate up\" or set MIGRATE_ON_START",
+				zap.Error(err))
+		}
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	if pools, ok := store.(interface{ Collectors() []prometheus.Collector }); ok {
+		registry.MustRegister(pools.Collectors()...)
+	}
+	repoMetrics := instrumented.NewMetrics(registry)
+	// store is kept unwrapped for features available only with particular backend
+	repo := instrumented.NewStorage(store, repoMetrics)
+	registry.MustRegister(service.NewCompanyStatsCollector(repo, cfg.Server.MetricsQueryTimeout, cfg.Server.MetricsCacheTTL, logger))
+
 	msgBroker := broker.NewBroker()
-	eventSrv := service.NewEventSender(msgBroker)
+	eventSrv := service.NewEventSender(msgBroker, service.WithEventMetrics(registry))
+
+	var companyRepo service.CompanyRepository = repo
+	// purge drops cached companies of all organizations
+	var companyPurger service.CompanyPurger = repo
+	var companyOpts []usecase.CompanyOption
+	cacheBackend := newCacheBackend(cfg, logger)
+	if cacheBackend != nil {
+		companyCache := cache.NewCompanyCache(repo, cacheBackend, cfg.Cache.TTL, logger)
+		expvar.Publish("company_cache", expvar.Func(func() any {
+			return companyCache.Stats()
+		}))
+		registerCacheMetrics(registry, companyCache)
+		companyRepo = companyCache
+		companyPurger = companyCache
+		companyOpts = append(companyOpts, usecase.WithCompanyCache(companyCache))
+	}
+	companySrv := service.NewCompanyService(companyRepo)
+	companyUsecase := usecase.NewCompanyUsecase(companySrv, eventSrv, companyOpts...)
+
+	userSrv := service.NewUserService(repo)
+	orgSrv := service.NewOrganizationService(repo)
+	authSrv := service.NewAuthService([]byte(cfg.Auth.JWTKey))
+	authUsecase := usecase.NewAuthUsecase(authSrv, userSrv, orgSrv)
+
+	// transactions are started through company repository, so that cache is bypassed inside them
+	txSrv := service.NewTxService(companyRepo)
+	userUsecase := usecase.NewUserUsecase(userSrv, eventSrv, txSrv)
+	adminUsecase := usecase.NewAdminUsecase(userSrv, eventSrv, txSrv)
+	orgUsecase := usecase.NewOrganizationUsecase(orgSrv, authSrv)
+	opts := []rest.Option{
+		rest.WithUsers(userUsecase),
+		rest.WithAdmin(adminUsecase),
+		rest.WithOrganizations(orgUsecase),
+		rest.WithBatchLimit(cfg.Server.BatchMaxSize),
+		rest.WithMetrics(registry),
+		rest.WithMetricsAccess(cfg.Server.MetricsAddress, cfg.Server.MetricsToken),
+		rest.WithTrustedProxies(cfg.Server.TrustedProxies),
+		rest.WithRemoteIPHeaders(cfg.Server.RemoteIPHeaders),
+		rest.WithSecurityHeaders(cfg.Server.ContentSecurityPolicy, cfg.Server.HSTSMaxAge),
+		rest.WithBodyLimit(cfg.Server.MaxBodySize, cfg.Server.MaxUploadSize),
+		rest.WithTimeouts(rest.Timeouts{
+			ReadHeader: cfg.Server.ReadHeaderTimeout,
+			Read:       cfg.Server.ReadTimeout,
+			Write:      cfg.Server.WriteTimeout,
+			Idle:       cfg.Server.IdleTimeout,
+		}),
+	}
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		opts = append(opts, rest.WithCORS(rest.CORSPolicy{
+			AllowedOrigins:   cfg.CORS.AllowedOrigins,
+			AllowedMethods:   cfg.CORS.AllowedMethods,
+			AllowedHeaders:   cfg.CORS.AllowedHeaders,
+			ExposedHeaders:   cfg.CORS.ExposedHeaders,
+			AllowCredentials: cfg.CORS.AllowCredentials,
+			MaxAge:           cfg.CORS.MaxAge,
+		}))
+	}
+	var certs *rest.CertificateLoader
+	if cfg.Server.TLSCertFile != "" {
+		certs, err = rest.NewCertificateLoader(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile)
+		if err != nil {
+			logger.Fatal("can not enable tls", zap.Error(err))
+		}
+		opts = append(opts, rest.WithTLS(certs))
+	}
+	// rate limits are enabled even without policies, so that policies can be added by reload
+	rateLimitPolicies, err := rest.ParseRateLimitPolicies(cfg.RateLimit.Policies)
+	if err != nil {
+		logger.Fatal("can not parse rate limits", zap.Error(err))
+	}
+	rateLimitStore := newRateLimitStore(cfg, logger)
+	rateLimitSrv := service.NewRateLimitService(rateLimitStore)
+	opts = append(opts, rest.WithRateLimit(usecase.NewRateLimitUsecase(rateLimitSrv), rateLimitPolicies))
+
+	healthChecks := []service.HealthCheck{
+		{Name: cfg.Storage.Type, Check: store.Ping, Timeout: cfg.Server.HealthCheckTimeout},
+		{Name: "broker", Check: eventSrv.Ping, Timeout: cfg.Server.HealthCheckTimeout},
+	}
+
+	// jobs and idempotency keys are stored only in postgres
+	var idempotencySrv *service.IdempotencyService
+	var workers *service.JobWorkers
+	if dbClient, ok := store.(*postgres.PostgresClient); ok {
+		// requests fail fast while database is unavailable, state of circuit breaker is exposed in debug vars
+		opts = append(opts, rest.WithAvailability(dbClient, cfg.Postgres.BreakerCooldown))
+		expvar.Publish("postgres_breaker", expvar.Func(func() any {
+			return dbClient.BreakerStatus()
+		}))
+		healthChecks = append(healthChecks, service.HealthCheck{
+			Name: "migrations", Check: checkSchema(dbClient), Timeout: cfg.Server.HealthCheckTimeout,
+		}, service.HealthCheck{
+			// instance is taken out of rotation while breaker fails requests fast
+			Name: "postgres_breaker", Check: func(context.Context) error { return dbClient.Available() },
+		})
+
+		idempotencySrv = service.NewIdempotencyService(instrumented.NewIdempotencyRepository(dbClient, repoMetrics), logger)
+		opts = append(opts, rest.WithIdempotency(usecase.NewIdempotencyUsecase(idempotencySrv, cfg.Server.IdempotencyKeyTTL, cfg.Server.IdempotencyKeyLease)))
+
+		artifacts, err := service.NewArtifactStore(cfg.Jobs.Dir)
+		if err != nil {
+			logger.Fatal("can not create job artifact store", zap.Error(err))
+		}
+		jobRepo := instrumented.NewJobRepository(dbClient, repoMetrics)
+		jobSrv := service.NewJobService(jobRepo)
+		opts = append(opts, rest.WithJobs(usecase.NewJobUsecase(jobSrv, artifacts, cfg.Jobs.MaxAttempts)))
+		workers = service.NewJobWorkers(jobRepo, artifacts, service.WorkerSettings{
+			Workers:           cfg.Jobs.Workers,
+			PollInterval:      cfg.Jobs.PollInterval,
+			HeartbeatInterval: cfg.Jobs.HeartbeatInterval,
+			StaleAfter:        cfg.Jobs.StaleAfter,
+			RetryBackoff:      cfg.Jobs.RetryBackoff,
+			MaxRetryBackoff:   cfg.Jobs.MaxRetryBackoff,
+		}, logger)
+		workers.Handle(domain.JobImport, rest.ImportJob(companyUsecase))
+		workers.Handle(domain.JobExport, rest.ExportJob(companyUsecase))
+		workers.Start()
+	} else {
+		logger.Info("jobs and idempotency keys are disabled, they require postgres storage",
+			zap.String("storage", cfg.Storage.Type))
+	}
+	if cfg.Auth.OIDC.Issuer != "" {
+		roleMapping := make(map[string]domain.Role, len(cfg.Auth.OIDC.RoleMapping))
+		for group, role := range cfg.Auth.OIDC.RoleMapping {
+			roleMapping[group] = domain.Role(role)
+		}
+		oidcSrv, err := service.NewOIDCService(context.Background(), service.OIDCSettings{
+			Issuer:       cfg.Auth.OIDC.Issuer,
+			ClientID:     cfg.Auth.OIDC.ClientID,
+			ClientSecret: cfg.Auth.OIDC.ClientSecret,
+			RedirectURL:  cfg.Auth.OIDC.RedirectURL,
+			Scopes:       cfg.Auth.OIDC.Scopes,
+			GroupsClaim:  cfg.Auth.OIDC.GroupsClaim,
+			RoleMapping:  roleMapping,
+		})
+		if err != nil {
+			logger.Fatal("can not configure oidc", zap.Error(err))
+		}
+		opts = append(opts, rest.WithOIDC(usecase.NewOIDCUsecase(oidcSrv, authSrv, userSrv, orgSrv)))
+	}
+	healthSrv := service.NewHealthService(cfg.Server.HealthCacheTTL, healthChecks...)
+	opts = append(opts, rest.WithHealth(usecase.NewHealthUsecase(healthSrv)))
+	srv := rest.NewServer(cfg.Server.Address, logger, companyUsecase, authUsecase, opts...)
 
-	companySrv := service.NewCompanyService(dbClient)
-	companyUsecase := usecase.NewCompanyUsecase(companySrv, eventSrv)
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	var purgeWG sync.WaitGroup
+	purgeWG.Add(2)
+	go func() {
+		defer purgeWG.Done()
+		if cfg.Storage.CompanyRetention == 0 {
+			return
+		}
+		service.NewPurgeService(companyPurger, cfg.Storage.CompanyRetention, cfg.Storage.PurgeInterval, logger).Run(purgeCtx)
+	}()
+	go func() {
+		defer purgeWG.Done()
+		if idempotencySrv == nil {
+			return
+		}
+		idempotencySrv.Run(purgeCtx, cfg.Storage.PurgeInterval)
+	}()
 
-	userSrv := service.NewUserService(dbClient)
-	authSrv := service.NewAuthService([]byte(cfg.JWTKey))
-	authUsecase := usecase.NewAuthUsecase(authSrv, userSrv)
-	srv := rest.NewServer(cfg.ServerAddress, logger, companyUsecase, authUsecase)
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+	go watchReload(reloadCtx, cmd, logger, func(cfg config.Config) error {
+		return logLevel.UnmarshalText([]byte(cfg.Log.Level))
+	}, func(cfg config.Config) error {
+		policies, err := rest.ParseRateLimitPolicies(cfg.RateLimit.Policies)
+		if err != nil {
+			return err
+		}
+		srv.SetRateLimitPolicies(policies)
+		return nil
+	}, func(config.Config) error {
+		// renewed certificate is loaded from the same files, other files require restart
+		if certs == nil {
+			return nil
+		}
+		return certs.Reload()
+	})
 
 	go func() {
 		err = srv.Run()
@@ -57,6 +294,10 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	// readiness fails from now on, requests are still served until load balancers notice it
+	healthSrv.Shutdown()
+	time.Sleep(cfg.Server.ShutdownDelay)
+
 	// The context is used to inform the server it has 5 seconds to finish
 	// the request it is currently handling
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -64,9 +305,94 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Fatal("Server forced to shutdown: ", zap.Error(err))
 	}
-	if err := dbClient.Close(); err != nil {
+	stopPurge()
+	purgeWG.Wait()
+
+	// Running jobs are waited for, jobs which do not finish in time are queued again
+	jobsCtx, cancelJobs := context.WithTimeout(context.Background(), cfg.Jobs.ShutdownTimeout)
+	defer cancelJobs()
+	if workers != nil {
+		if err := workers.Shutdown(jobsCtx); err != nil {
+			logger.Warn("jobs interrupted by shutdown, they are queued again", zap.Error(err))
+		}
+	}
+	// connections to Redis are closed
+	for _, backend := range []any{cacheBackend, rateLimitStore} {
+		if closer, ok := backend.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				logger.Warn("can not close redis connection", zap.Error(err))
+			}
+		}
+	}
+	if err := store.Close(); err != nil {
 		logger.Fatal("Database service forced to shutdown: ", zap.Error(err))
 	}
+	if shutdownTracing != nil {
+		tracingCtx, cancelTracing := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelTracing()
+		if err := shutdownTracing(tracingCtx); err != nil {
+			logger.Warn("can not flush traces", zap.Error(err))
+		}
+	}
 
 	logger.Info("Server exiting")
 }
+
+// newCacheBackend creates backend of company cache: Redis if it is configured, in-process LRU otherwise.
+// It returns nil if cache is disabled.
+func newCacheBackend(cfg config.Config, logger *zap.Logger) cache.Backend {
+	if cfg.Redis.Address != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		backend, err := cache.NewRedis(ctx, cache.RedisSettings{
+			Addr:     cfg.Redis.Address,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+			Prefix:   "companies-handler:",
+		})
+		if err != nil {
+			logger.Fatal("can not connect to redis", zap.Error(err))
+		}
+		return backend
+	}
+	if cfg.Cache.Size > 0 {
+		return cache.NewLRU(cfg.Cache.Size)
+	}
+	return nil
+}
+
+// newRateLimitStore creates store of token buckets of rate limits: Redis shared by instances or in-process one
+func newRateLimitStore(cfg config.Config, logger *zap.Logger) service.RateLimitStore {
+	if cfg.RateLimit.Store != config.RateLimitStoreRedis {
+		return ratelimit.NewMemory()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	store, err := ratelimit.NewRedis(ctx, ratelimit.RedisSettings{
+		Addr:     cfg.Redis.Address,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
+		Prefix:   "companies-handler:ratelimit:",
+	})
+	if err != nil {
+		logger.Fatal("can not connect to redis", zap.Error(err))
+	}
+	return store
+}
+
+// registerCacheMetrics exposes counters of company cache lookups
+func registerCacheMetrics(reg prometheus.Registerer, c *cache.CompanyCache) {
+	counter := func(name, help string, value func(cache.Stats) uint64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{Name: name, Help: help}, func() float64 {
+			return float64(value(c.Stats()))
+		})
+	}
+	reg.MustRegister(
+		counter("company_cache_hits_total", "Number of reads of companies served from cache.",
+			func(s cache.Stats) uint64 { return s.Hits }),
+		counter("company_cache_misses_total", "Number of reads of companies missed in cache.",
+			func(s cache.Stats) uint64 { return s.Misses }),
+		counter("company_cache_errors_total", "Number of failures of cache backend.",
+			func(s cache.Stats) uint64 { return s.Errors }),
+	)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/config"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/postgres"
+	"github.com/Ragnar-BY/companies-handler/postgres/migrations"
+	"go.uber.org/zap"
+)
+
+// migrateUsage is usage of migrate command
+const migrateUsage = "usage: server migrate up | down [N] | status | force VERSION"
+
+// startMigrationTimeout limits waiting for migrations applied on start by another instance
+const startMigrationTimeout = 5 * time.Minute
+
+// runMigrate migrates postgres database with embedded migrations, usage:
+//
+//	server migrate up             apply all migrations
+//	server migrate down [N]       revert N last migrations, 1 by default
+//	server migrate status         print applied and latest versions
+//	server migrate force VERSION  set version without applying migrations, e.g. after failed migration
+func runMigrate(args []string, cfg config.Config, logger *zap.Logger) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	dbClient, err := connectPostgres(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer dbClient.Close()
+	migrator, err := dbClient.NewMigrator(context.Background(), migrations.FS)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch args[0] {
+	case "up":
+		err = migrator.Up(context.Background())
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of migrations %q", args[1])
+			}
+		}
+		err = migrator.Down(context.Background(), steps)
+	case "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		err = migrator.Force(context.Background(), version)
+	case "status":
+	default:
+		return errors.New(migrateUsage)
+	}
+	if err != nil {
+		return err
+	}
+	status, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("version: %d\nlatest: %d\ndirty: %t\n", status.Version, status.Latest, status.Dirty)
+	return nil
+}
+
+// checkSchema returns health check failing while schema is dirty or behind migrations
+func checkSchema(dbClient *postgres.PostgresClient) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		migrator, err := dbClient.NewMigrator(ctx, migrations.FS)
+		if err != nil {
+			return err
+		}
+		defer migrator.Close()
+		return migrator.Check()
+	}
+}
+
+// prepareSchema applies migrations if it is enabled and checks that schema is up to date
+func prepareSchema(dbClient *postgres.PostgresClient, migrateOnStart bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), startMigrationTimeout)
+	defer cancel()
+	migrator, err := dbClient.NewMigrator(ctx, migrations.FS)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+	if migrateOnStart {
+		if err = migrator.Up(ctx); err != nil {
+			return err
+		}
+	}
+	return migrator.Check()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Ragnar-BY/companies-handler/internal/config"
+	"go.uber.org/zap"
+)
+
+// watchReload reloads config on SIGHUP until ctx is done and applies settings which can be changed
+// without restart. Config which fails to load or validate is ignored and previous config is kept.
+func watchReload(ctx context.Context, cmd *cli, logger *zap.Logger, apply ...func(cfg config.Config) error) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+		cfg, err := cmd.loadConfig()
+		if err != nil {
+			logger.Error("can not reload config, previous config is kept", zap.Error(err))
+			continue
+		}
+		for _, f := range apply {
+			if err := f(cfg); err != nil {
+				logger.Error("can not apply reloaded config", zap.Error(err))
+			}
+		}
+		logger.Info("config reloaded", zap.String("log_level", cfg.Log.Level))
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/config"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/memory"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/postgres"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/sqlite"
+	"github.com/Ragnar-BY/companies-handler/internal/service"
+	"go.uber.org/zap"
+)
+
+// storage is backend implementing repositories of companies, users and organizations
+type storage interface {
+	service.CompanyRepository
+	service.UserRepository
+	service.OrganizationRepository
+	service.CompanyPurger
+	service.CompanyCounter
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// newStorage opens storage backend selected by config
+func newStorage(cfg config.Config, logger *zap.Logger) (storage, error) {
+	switch cfg.Storage.Type {
+	case config.StoragePostgres:
+		return connectPostgres(cfg, logger)
+	case config.StorageSQLite:
+		return sqlite.NewSQLiteClient(cfg.Storage.SQLitePath)
+	case config.StorageMemory:
+		return memory.NewRepository(), nil
+	}
+	return nil, fmt.Errorf("unknown storage %q", cfg.Storage.Type)
+}
+
+// connectPostgres connects to postgres, connection is retried with backoff until startup timeout
+// passes, so that server can be started before database
+func connectPostgres(cfg config.Config, logger *zap.Logger) (*postgres.PostgresClient, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Postgres.StartupTimeout)
+	defer cancel()
+	return postgres.Connect(ctx, postgresSettings(cfg), func(err error, delay time.Duration) {
+		logger.Warn("can not connect to database, retrying", zap.Duration("delay", delay), zap.Error(err))
+	})
+}
+
+func postgresSettings(cfg config.Config) postgres.PostgresSettings {
+	return postgres.PostgresSettings{
+		Addr:     cfg.Postgres.Address,
+		Username: cfg.Postgres.User,
+		Password: cfg.Postgres.Password,
+		Database: cfg.Postgres.DB,
+
+		SSLMode:          cfg.Postgres.SSLMode,
+		SSLRootCert:      cfg.Postgres.SSLRootCert,
+		SSLCert:          cfg.Postgres.SSLCert,
+		SSLKey:           cfg.Postgres.SSLKey,
+		ApplicationName:  cfg.Postgres.ApplicationName,
+		ConnectTimeout:   cfg.Postgres.ConnectTimeout,
+		StatementTimeout: cfg.Postgres.StatementTimeout,
+		LockTimeout:      cfg.Postgres.LockTimeout,
+
+		MaxOpenConns:    cfg.Postgres.MaxOpenConns,
+		MaxIdleConns:    cfg.Postgres.MaxIdleConns,
+		ConnMaxLifetime: cfg.Postgres.ConnMaxLifetime,
+		ConnMaxIdleTime: cfg.Postgres.ConnMaxIdleTime,
+
+		Replicas:              cfg.Postgres.Replicas,
+		ReplicaCheckInterval:  cfg.Postgres.ReplicaCheckInterval,
+		ReplicaMaxLag:         cfg.Postgres.ReplicaMaxLag,
+		PrimaryReadAfterWrite: cfg.Postgres.PrimaryReadAfterWrite,
+
+		BreakerThreshold:  cfg.Postgres.BreakerThreshold,
+		BreakerCooldown:   cfg.Postgres.BreakerCooldown,
+		ConnectBackoff:    cfg.Postgres.ConnectBackoff,
+		MaxConnectBackoff: cfg.Postgres.MaxConnectBackoff,
+	}
+}
@@ -1,11 +1,20 @@
 package broker
 
+import "context"
+
 // Broker is mock implementation of broker
 type Broker struct{}
 
 func NewBroker() *Broker {
 	return &Broker{}
 }
-func (b *Broker) SendEvent(topic string, message []byte) error {
+
+// SendEvent sends message with headers to topic, mock broker drops it
+func (b *Broker) SendEvent(ctx context.Context, topic string, message []byte, headers map[string]string) error {
+	return nil
+}
+
+// Ping checks connection to broker, mock broker is always connected
+func (b *Broker) Ping(ctx context.Context) error {
 	return nil
 }
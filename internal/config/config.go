@@ -2,28 +2,312 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/joho/godotenv"
 )
 
-// Config is config struct
+// Storage backends
+const (
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+	StorageMemory   = "memory"
+)
+
+// Formats of logs
+const (
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
+)
+
+// Stores of rate limits
+const (
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreRedis  = "redis"
+)
+
+// Exporters of traces
+const (
+	TracingNone   = "none"
+	TracingOTLP   = "otlp"
+	TracingStdout = "stdout"
+)
+
+// Config is config of server. It is loaded from .env, YAML or TOML file and environment variables,
+// every setting has environment variable and key in section of file.
 type Config struct {
-	PostgresAddress  string `env:"POSTGRES_ADDRESS"`
-	PostgresUser     string `env:"POSTGRES_USER"`
-	PostgresPassword string `env:"POSTGRES_PASSWORD"`
-	PostgresDB       string `env:"POSTGRES_DB"`
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	CORS      CORSConfig      `yaml:"cors" toml:"cors"`
+	Log       LogConfig       `yaml:"log" toml:"log"`
+	Storage   StorageConfig   `yaml:"storage" toml:"storage"`
+	Postgres  PostgresConfig  `yaml:"postgres" toml:"postgres"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	Cache     CacheConfig     `yaml:"cache" toml:"cache"`
+	Redis     RedisConfig     `yaml:"redis" toml:"redis"`
+	RateLimit RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+	Jobs      JobsConfig      `yaml:"jobs" toml:"jobs"`
+	Tracing   TracingConfig   `yaml:"tracing" toml:"tracing"`
+}
+
+// ServerConfig is config of HTTP server
+type ServerConfig struct {
+	Address string `env:"SERVER_ADDRESS" env-default:":8080" yaml:"address" toml:"address" validate:"required"`
+	// ShutdownDelay is how long server keeps serving requests after readiness starts failing on shutdown,
+	// so that load balancers stop sending requests before server stops accepting them
+	ShutdownDelay time.Duration `env:"SHUTDOWN_DELAY" env-default:"0s" yaml:"shutdown_delay" toml:"shutdown_delay" validate:"gte=0"`
+	// HealthCacheTTL is how long result of readiness checks is reused, HealthCheckTimeout limits every check
+	HealthCacheTTL     time.Duration `env:"HEALTH_CACHE_TTL" env-default:"2s" yaml:"health_cache_ttl" toml:"health_cache_ttl" validate:"gte=0"`
+	HealthCheckTimeout time.Duration `env:"HEALTH_CHECK_TIMEOUT" env-default:"2s" yaml:"health_check_timeout" toml:"health_check_timeout" validate:"gt=0"`
+	// MetricsQueryTimeout limits queries run to collect metrics on scrape of /metrics, their result is reused
+	// for MetricsCacheTTL
+	MetricsQueryTimeout time.Duration `env:"METRICS_QUERY_TIMEOUT" env-default:"2s" yaml:"metrics_query_timeout" toml:"metrics_query_timeout" validate:"gt=0"`
+	MetricsCacheTTL     time.Duration `env:"METRICS_CACHE_TTL" env-default:"15s" yaml:"metrics_cache_ttl" toml:"metrics_cache_ttl" validate:"gte=0"`
+	// MetricsAddress serves /metrics on separate listener, e.g. one reachable only by scraper, instead of Address
+	MetricsAddress string `env:"METRICS_ADDRESS" yaml:"metrics_address" toml:"metrics_address"`
+	// MetricsToken is bearer token required by /metrics, it is not required if it is empty
+	MetricsToken string `env:"METRICS_TOKEN" yaml:"metrics_token" toml:"metrics_token" secret:"true"`
+	// BatchMaxSize is maximum number of operations in POST /companies:batch
+	BatchMaxSize int `env:"BATCH_MAX_SIZE" env-default:"1000" yaml:"batch_max_size" toml:"batch_max_size" validate:"min=1"`
+	// IdempotencyKeyTTL is how long responses of requests with Idempotency-Key are stored
+	IdempotencyKeyTTL time.Duration `env:"IDEMPOTENCY_KEY_TTL" env-default:"24h" yaml:"idempotency_key_ttl" toml:"idempotency_key_ttl" validate:"gt=0"`
+	// IdempotencyKeyLease is how long key of request in progress is kept, after it retry takes the key,
+	// it should be longer than WriteTimeout
+	IdempotencyKeyLease time.Duration `env:"IDEMPOTENCY_KEY_LEASE" env-default:"10m" yaml:"idempotency_key_lease" toml:"idempotency_key_lease" validate:"gt=0"`
+	// TrustedProxies are addresses or CIDRs of proxies whose RemoteIPHeaders give IP of client
+	TrustedProxies  []string `env:"TRUSTED_PROXIES" yaml:"trusted_proxies" toml:"trusted_proxies" validate:"dive,ip|cidr"`
+	RemoteIPHeaders []string `env:"REMOTE_IP_HEADERS" env-default:"X-Forwarded-For,X-Real-IP" yaml:"remote_ip_headers" toml:"remote_ip_headers"`
+	// TLSCertFile and TLSKeyFile enable HTTPS, certificate is loaded again on SIGHUP
+	TLSCertFile string `env:"SERVER_TLS_CERT_FILE" yaml:"tls_cert_file" toml:"tls_cert_file" validate:"required_with=TLSKeyFile"`
+	TLSKeyFile  string `env:"SERVER_TLS_KEY_FILE" yaml:"tls_key_file" toml:"tls_key_file" validate:"required_with=TLSCertFile"`
+	// HSTSMaxAge is max age of Strict-Transport-Security header sent with TLS, 0 disables header
+	HSTSMaxAge            time.Duration `env:"SERVER_HSTS_MAX_AGE" env-default:"8760h" yaml:"hsts_max_age" toml:"hsts_max_age" validate:"gte=0"`
+	ContentSecurityPolicy string        `env:"SERVER_CONTENT_SECURITY_POLICY" env-default:"default-src 'none'; frame-ancestors 'none'" yaml:"content_security_policy" toml:"content_security_policy"`
+	// MaxBodySize limits request bodies in bytes, MaxUploadSize limits files of imports. 0 disables limit.
+	MaxBodySize   int64 `env:"SERVER_MAX_BODY_SIZE" env-default:"1048576" yaml:"max_body_size" toml:"max_body_size" validate:"gte=0"`
+	MaxUploadSize int64 `env:"SERVER_MAX_UPLOAD_SIZE" env-default:"104857600" yaml:"max_upload_size" toml:"max_upload_size" validate:"gte=0"`
+	// Timeouts of connections, 0 disables timeout. Streamed downloads are not limited by WriteTimeout.
+	ReadHeaderTimeout time.Duration `env:"SERVER_READ_HEADER_TIMEOUT" env-default:"10s" yaml:"read_header_timeout" toml:"read_header_timeout" validate:"gte=0"`
+	ReadTimeout       time.Duration `env:"SERVER_READ_TIMEOUT" env-default:"5m" yaml:"read_timeout" toml:"read_timeout" validate:"gte=0"`
+	WriteTimeout      time.Duration `env:"SERVER_WRITE_TIMEOUT" env-default:"5m" yaml:"write_timeout" toml:"write_timeout" validate:"gte=0"`
+	IdleTimeout       time.Duration `env:"SERVER_IDLE_TIMEOUT" env-default:"2m" yaml:"idle_timeout" toml:"idle_timeout" validate:"gte=0"`
+}
 
-	ServerAddress string `env:"SERVER_ADDRESS"`
+// CORSConfig is config of CORS, it is disabled if AllowedOrigins is empty. Default AllowedHeaders are
+// request headers read by API, organization is selected by token and not by header.
+type CORSConfig struct {
+	// AllowedOrigins are origins of browser frontends, e.g. https://app.example.com, * allows any origin
+	AllowedOrigins   []string      `env:"CORS_ALLOWED_ORIGINS" yaml:"allowed_origins" toml:"allowed_origins"`
+	AllowedMethods   []string      `env:"CORS_ALLOWED_METHODS" env-default:"GET,POST,PUT,PATCH,DELETE" yaml:"allowed_methods" toml:"allowed_methods"`
+	AllowedHeaders   []string      `env:"CORS_ALLOWED_HEADERS" env-default:"Authorization,Content-Type,Idempotency-Key,X-Request-ID" yaml:"allowed_headers" toml:"allowed_headers"`
+	ExposedHeaders   []string      `env:"CORS_EXPOSED_HEADERS" env-default:"X-Request-ID,Retry-After,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy" yaml:"exposed_headers" toml:"exposed_headers"`
+	AllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS" env-default:"false" yaml:"allow_credentials" toml:"allow_credentials"`
+	MaxAge           time.Duration `env:"CORS_MAX_AGE" env-default:"10m" yaml:"max_age" toml:"max_age" validate:"gte=0"`
+}
+
+// LogConfig is config of logs, Level can be changed without restart by SIGHUP
+type LogConfig struct {
+	// File is file of logs, it is rotated once it grows over MaxSize megabytes and rotated files are kept
+	// for MaxAge days. Logs are written to stdout if it is empty.
+	File       string `env:"LOG_FILE" yaml:"file" toml:"file"`
+	MaxSize    int    `env:"LOG_MAX_SIZE" env-default:"100" yaml:"max_size" toml:"max_size" validate:"min=1"`
+	MaxBackups int    `env:"LOG_MAX_BACKUPS" env-default:"5" yaml:"max_backups" toml:"max_backups" validate:"gte=0"`
+	MaxAge     int    `env:"LOG_MAX_AGE" env-default:"30" yaml:"max_age" toml:"max_age" validate:"gte=0"`
+	Level      string `env:"LOG_LEVEL" env-default:"info" yaml:"level" toml:"level" validate:"oneof=debug info warn error"`
+	Format     string `env:"LOG_FORMAT" env-default:"json" yaml:"format" toml:"format" validate:"oneof=json console"`
+}
+
+// StorageConfig selects storage backend of companies, users and organizations
+type StorageConfig struct {
+	// Type is postgres, sqlite or memory. Jobs and idempotency keys are stored only in postgres,
+	// they are disabled with other backends.
+	Type       string `env:"STORAGE" env-default:"postgres" yaml:"type" toml:"type" validate:"oneof=postgres sqlite memory"`
+	SQLitePath string `env:"SQLITE_PATH" env-default:"companies.db" yaml:"sqlite_path" toml:"sqlite_path"`
+	// CompanyRetention is how long soft deleted companies are kept, 0 disables purge
+	CompanyRetention time.Duration `env:"COMPANY_RETENTION" env-default:"720h" yaml:"company_retention" toml:"company_retention" validate:"gte=0"`
+	PurgeInterval    time.Duration `env:"PURGE_INTERVAL" env-default:"1h" yaml:"purge_interval" toml:"purge_interval" validate:"gt=0"`
+}
+
+// PostgresConfig is config of postgres storage
+type PostgresConfig struct {
+	Address  string `env:"POSTGRES_ADDRESS" yaml:"address" toml:"address"`
+	User     string `env:"POSTGRES_USER" yaml:"user" toml:"user"`
+	Password string `env:"POSTGRES_PASSWORD" yaml:"password" toml:"password" secret:"true"`
+	DB       string `env:"POSTGRES_DB" yaml:"db" toml:"db"`
+	// SSLMode is TLS mode of connections: disable, allow, prefer, require, verify-ca or verify-full
+	SSLMode         string        `env:"POSTGRES_SSLMODE" env-default:"prefer" yaml:"sslmode" toml:"sslmode" validate:"oneof=disable allow prefer require verify-ca verify-full"`
+	SSLRootCert     string        `env:"POSTGRES_SSLROOTCERT" yaml:"sslrootcert" toml:"sslrootcert"`
+	SSLCert         string        `env:"POSTGRES_SSLCERT" yaml:"sslcert" toml:"sslcert"`
+	SSLKey          string        `env:"POSTGRES_SSLKEY" yaml:"sslkey" toml:"sslkey"`
+	ApplicationName string        `env:"POSTGRES_APPLICATION_NAME" env-default:"companies-handler" yaml:"application_name" toml:"application_name"`
+	ConnectTimeout  time.Duration `env:"POSTGRES_CONNECT_TIMEOUT" env-default:"5s" yaml:"connect_timeout" toml:"connect_timeout" validate:"gte=0"`
+	// StatementTimeout and LockTimeout abort slow queries, 0 disables timeout.
+	// Migrations and imports are not limited by them.
+	StatementTimeout time.Duration `env:"POSTGRES_STATEMENT_TIMEOUT" env-default:"30s" yaml:"statement_timeout" toml:"statement_timeout" validate:"gte=0"`
+	LockTimeout      time.Duration `env:"POSTGRES_LOCK_TIMEOUT" env-default:"10s" yaml:"lock_timeout" toml:"lock_timeout" validate:"gte=0"`
+	MaxOpenConns     int           `env:"POSTGRES_MAX_OPEN_CONNS" env-default:"20" yaml:"max_open_conns" toml:"max_open_conns" validate:"gte=0"`
+	MaxIdleConns     int           `env:"POSTGRES_MAX_IDLE_CONNS" env-default:"10" yaml:"max_idle_conns" toml:"max_idle_conns" validate:"gte=0"`
+	ConnMaxLifetime  time.Duration `env:"POSTGRES_CONN_MAX_LIFETIME" env-default:"30m" yaml:"conn_max_lifetime" toml:"conn_max_lifetime" validate:"gte=0"`
+	ConnMaxIdleTime  time.Duration `env:"POSTGRES_CONN_MAX_IDLE_TIME" env-default:"5m" yaml:"conn_max_idle_time" toml:"conn_max_idle_time" validate:"gte=0"`
+	// Replicas are comma separated addresses of read replicas, reads of companies are routed to them
+	Replicas             []string      `env:"POSTGRES_REPLICAS" yaml:"replicas" toml:"replicas"`
+	ReplicaCheckInterval time.Duration `env:"POSTGRES_REPLICA_CHECK_INTERVAL" env-default:"5s" yaml:"replica_check_interval" toml:"replica_check_interval" validate:"gt=0"`
+	ReplicaMaxLag        time.Duration `env:"POSTGRES_REPLICA_MAX_LAG" env-default:"10s" yaml:"replica_max_lag" toml:"replica_max_lag" validate:"gte=0"`
+	// PrimaryReadAfterWrite is how long reads go to primary after write of instance, it should exceed replica lag
+	PrimaryReadAfterWrite time.Duration `env:"POSTGRES_PRIMARY_READ_AFTER_WRITE" env-default:"15s" yaml:"primary_read_after_write" toml:"primary_read_after_write" validate:"gte=0"`
+	// StartupTimeout is how long connection to postgres is retried on start
+	StartupTimeout    time.Duration `env:"POSTGRES_STARTUP_TIMEOUT" env-default:"1m" yaml:"startup_timeout" toml:"startup_timeout" validate:"gte=0"`
+	ConnectBackoff    time.Duration `env:"POSTGRES_CONNECT_BACKOFF" env-default:"500ms" yaml:"connect_backoff" toml:"connect_backoff" validate:"gt=0"`
+	MaxConnectBackoff time.Duration `env:"POSTGRES_MAX_CONNECT_BACKOFF" env-default:"10s" yaml:"max_connect_backoff" toml:"max_connect_backoff" validate:"gtefield=ConnectBackoff"`
+	// BreakerThreshold is number of consecutive failed connections requests fail fast with 503 after,
+	// 0 disables circuit breaker
+	BreakerThreshold int           `env:"POSTGRES_BREAKER_THRESHOLD" env-default:"3" yaml:"breaker_threshold" toml:"breaker_threshold" validate:"gte=0"`
+	BreakerCooldown  time.Duration `env:"POSTGRES_BREAKER_COOLDOWN" env-default:"5s" yaml:"breaker_cooldown" toml:"breaker_cooldown" validate:"gt=0"`
+	// MigrateOnStart applies migrations of postgres on start, otherwise server refuses to start
+	// until schema is migrated with "server migrate up"
+	MigrateOnStart bool `env:"MIGRATE_ON_START" env-default:"false" yaml:"migrate_on_start" toml:"migrate_on_start"`
+}
+
+// AuthConfig is config of authentication
+type AuthConfig struct {
+	// JWTKey signs tokens of users
+	JWTKey string     `env:"JWT_KEY" yaml:"jwt_key" toml:"jwt_key" secret:"true" validate:"required"`
+	OIDC   OIDCConfig `yaml:"oidc" toml:"oidc"`
+}
+
+// OIDCConfig is config of single sign-on, it is enabled if Issuer is set
+type OIDCConfig struct {
+	Issuer       string            `env:"OIDC_ISSUER" yaml:"issuer" toml:"issuer"`
+	ClientID     string            `env:"OIDC_CLIENT_ID" yaml:"client_id" toml:"client_id" validate:"required_with=Issuer"`
+	ClientSecret string            `env:"OIDC_CLIENT_SECRET" yaml:"client_secret" toml:"client_secret" secret:"true"`
+	RedirectURL  string            `env:"OIDC_REDIRECT_URL" yaml:"redirect_url" toml:"redirect_url" validate:"required_with=Issuer"`
+	Scopes       []string          `env:"OIDC_SCOPES" yaml:"scopes" toml:"scopes"`
+	GroupsClaim  string            `env:"OIDC_GROUPS_CLAIM" yaml:"groups_claim" toml:"groups_claim"`
+	RoleMapping  map[string]string `env:"OIDC_ROLE_MAPPING" yaml:"role_mapping" toml:"role_mapping"`
+}
 
-	JWTKey string `env:"JWT_KEY"`
+// CacheConfig is config of cache of companies
+type CacheConfig struct {
+	// Size is number of cached reads of companies kept in memory, 0 disables in-process cache
+	Size int           `env:"CACHE_SIZE" env-default:"10000" yaml:"size" toml:"size" validate:"gte=0"`
+	TTL  time.Duration `env:"CACHE_TTL" env-default:"1m" yaml:"ttl" toml:"ttl" validate:"gt=0"`
 }
 
-// LoadConfig loads config from .env file
-func LoadConfig(path string) (config Config, err error) {
-	err = cleanenv.ReadConfig(path, &config)
+// RedisConfig is config of Redis, cache is shared by instances in Redis instead of in-process cache
+// if Address is set
+type RedisConfig struct {
+	Address  string `env:"REDIS_ADDRESS" yaml:"address" toml:"address"`
+	Password string `env:"REDIS_PASSWORD" yaml:"password" toml:"password" secret:"true"`
+	DB       int    `env:"REDIS_DB" yaml:"db" toml:"db" validate:"gte=0"`
+}
+
+// RateLimitConfig is config of rate limits of requests, Policies can be changed without restart by SIGHUP
+type RateLimitConfig struct {
+	// Policies are "<method> <route> <requests>/<period> <key> [burst=<n>]" where key is ip or user
+	// and * matches any method or route. First policy matching request is applied, empty list disables limits.
+	Policies []string `env:"RATE_LIMITS" env-default:"GET /companies 100/1m ip,POST /signin 10/1m ip" yaml:"policies" toml:"policies"`
+	// Store keeps buckets of clients: memory (every instance limits requests on its own) or redis
+	Store string `env:"RATE_LIMIT_STORE" env-default:"memory" yaml:"store" toml:"store" validate:"oneof=memory redis"`
+}
+
+// JobsConfig is config of asynchronous jobs
+type JobsConfig struct {
+	// Workers is number of workers of asynchronous jobs, 0 disables workers
+	Workers           int           `env:"JOB_WORKERS" env-default:"2" yaml:"workers" toml:"workers" validate:"gte=0"`
+	Dir               string        `env:"JOB_DIR" env-default:"jobs" yaml:"dir" toml:"dir" validate:"required"`
+	MaxAttempts       int           `env:"JOB_MAX_ATTEMPTS" env-default:"3" yaml:"max_attempts" toml:"max_attempts" validate:"min=1"`
+	PollInterval      time.Duration `env:"JOB_POLL_INTERVAL" env-default:"1s" yaml:"poll_interval" toml:"poll_interval" validate:"gt=0"`
+	RetryBackoff      time.Duration `env:"JOB_RETRY_BACKOFF" env-default:"10s" yaml:"retry_backoff" toml:"retry_backoff" validate:"gt=0"`
+	MaxRetryBackoff   time.Duration `env:"JOB_MAX_RETRY_BACKOFF" env-default:"10m" yaml:"max_retry_backoff" toml:"max_retry_backoff" validate:"gtefield=RetryBackoff"`
+	HeartbeatInterval time.Duration `env:"JOB_HEARTBEAT_INTERVAL" env-default:"10s" yaml:"heartbeat_interval" toml:"heartbeat_interval" validate:"gt=0"`
+	StaleAfter        time.Duration `env:"JOB_STALE_AFTER" env-default:"1m" yaml:"stale_after" toml:"stale_after" validate:"gtfield=HeartbeatInterval"`
+	// ShutdownTimeout is how long running jobs are waited for on shutdown before they are queued again
+	ShutdownTimeout time.Duration `env:"JOB_SHUTDOWN_TIMEOUT" env-default:"30s" yaml:"shutdown_timeout" toml:"shutdown_timeout" validate:"gte=0"`
+}
+
+// TracingConfig is config of export of traces
+type TracingConfig struct {
+	// Exporter exports traces: none, otlp (over HTTP to OTLPEndpoint) or stdout for local debugging
+	Exporter     string `env:"TRACING_EXPORTER" env-default:"none" yaml:"exporter" toml:"exporter" validate:"oneof=none otlp stdout"`
+	OTLPEndpoint string `env:"TRACING_OTLP_ENDPOINT" env-default:"localhost:4318" yaml:"otlp_endpoint" toml:"otlp_endpoint"`
+	OTLPInsecure bool   `env:"TRACING_OTLP_INSECURE" env-default:"false" yaml:"otlp_insecure" toml:"otlp_insecure"`
+	// SampleRatio is share of traces started by server which are sampled,
+	// traces started by callers are sampled as they decided
+	SampleRatio float64 `env:"TRACING_SAMPLE_RATIO" env-default:"1" yaml:"sample_ratio" toml:"sample_ratio" validate:"gte=0,lte=1"`
+}
+
+var (
+	dotenvMu sync.Mutex
+	// dotenvVars are variables set from .env file, they are set again when config is reloaded
+	dotenvVars = map[string]bool{}
+)
+
+// loadDotenv sets variables of .env file which are not set in environment. Variables set from file
+// by previous load are updated, so that changes of file are applied when config is reloaded.
+func loadDotenv(path string) error {
+	if path == "" {
+		return nil
+	}
+	vars, err := godotenv.Read(path)
 	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("can not read %s: %w", path, err)
+	}
+
+	dotenvMu.Lock()
+	defer dotenvMu.Unlock()
+	for name := range dotenvVars {
+		if _, ok := vars[name]; !ok {
+			delete(dotenvVars, name)
+			if err := os.Unsetenv(name); err != nil {
+				return err
+			}
+		}
+	}
+	for name, value := range vars {
+		if _, ok := os.LookupEnv(name); ok && !dotenvVars[name] {
+			continue
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return err
+		}
+		dotenvVars[name] = true
+	}
+	return nil
+}
+
+// LoadConfig loads config from file and environment, environment variables override settings of file.
+// File is YAML (.yaml, .yml), TOML (.toml) or .env file otherwise, config is loaded from environment only
+// if file does not exist. Secrets are read from files named by *_FILE variables. Overrides, e.g. flags
+// of command line, are applied last and config is validated.
+func LoadConfig(path string, overrides ...func(*Config)) (config Config, err error) {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml", ".toml":
+		err = cleanenv.ReadConfig(path, &config)
+		if errors.Is(err, os.ErrNotExist) {
+			err = cleanenv.ReadEnv(&config)
+		}
+	default:
+		// cleanenv sets variables of .env file over environment, so file is loaded here without overriding it
+		if err = loadDotenv(path); err != nil {
+			return config, err
+		}
 		err = cleanenv.ReadEnv(&config)
 	}
-	return
+	if err != nil {
+		return config, err
+	}
+	if err = readSecretFiles(&config); err != nil {
+		return config, err
+	}
+	for _, override := range overrides {
+		override(&config)
+	}
+	return config, config.Validate()
 }
@@ -0,0 +1,201 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := writeFile(t, ".env", "SERVER_ADDRESS=:8080\nLOG_LEVEL=debug\nSTORAGE=memory\nJWT_KEY=key\n")
+	t.Setenv("SERVER_ADDRESS", ":9000")
+	unsetenv(t, "LOG_LEVEL")
+	unsetenv(t, "STORAGE")
+	unsetenv(t, "JWT_KEY")
+
+	cfg, err := config.LoadConfig(path)
+	require.NoError(t, err)
+	// environment overrides file
+	require.Equal(t, ":9000", cfg.Server.Address)
+	require.Equal(t, "debug", cfg.Log.Level)
+	require.Equal(t, "key", cfg.Auth.JWTKey)
+	require.Equal(t, []string{"Authorization", "Content-Type", "Idempotency-Key", "X-Request-ID"}, cfg.CORS.AllowedHeaders)
+
+	// changes of file are applied on reload, variables removed from file get defaults
+	require.NoError(t, os.WriteFile(path, []byte("SERVER_ADDRESS=:8080\nSTORAGE=memory\nJWT_KEY=other\n"), 0o600))
+	cfg, err = config.LoadConfig(path)
+	require.NoError(t, err)
+	require.Equal(t, ":9000", cfg.Server.Address)
+	require.Equal(t, "info", cfg.Log.Level)
+	require.Equal(t, "other", cfg.Auth.JWTKey)
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			data: `
+server:
+  address: ":8080"
+log:
+  level: debug
+storage:
+  type: memory
+auth:
+  jwt_key: key
+  oidc:
+    scopes: [openid, email]
+cache:
+  ttl: 5m
+`,
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			data: `
+[server]
+address = ":8080"
+
+[log]
+level = "debug"
+
+[storage]
+type = "memory"
+
+[auth]
+jwt_key = "key"
+
+[auth.oidc]
+scopes = ["openid", "email"]
+
+[cache]
+ttl = "5m"
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, tt.file, tt.data)
+			t.Setenv("SERVER_ADDRESS", ":9000")
+			unsetenv(t, "LOG_LEVEL")
+			unsetenv(t, "STORAGE")
+			unsetenv(t, "JWT_KEY")
+			unsetenv(t, "CACHE_TTL")
+
+			cfg, err := config.LoadConfig(path)
+			require.NoError(t, err)
+			// environment overrides file
+			require.Equal(t, ":9000", cfg.Server.Address)
+			require.Equal(t, "debug", cfg.Log.Level)
+			require.Equal(t, config.StorageMemory, cfg.Storage.Type)
+			require.Equal(t, "key", cfg.Auth.JWTKey)
+			require.Equal(t, []string{"openid", "email"}, cfg.Auth.OIDC.Scopes)
+			require.Equal(t, 5*time.Minute, cfg.Cache.TTL)
+			// settings missing in file have defaults
+			require.Equal(t, 2, cfg.Jobs.Workers)
+		})
+	}
+}
+
+func TestLoadConfigSecretFile(t *testing.T) {
+	t.Setenv("STORAGE", config.StorageMemory)
+	t.Setenv("JWT_KEY", "variable")
+	t.Setenv("JWT_KEY_FILE", writeFile(t, "jwt_key", "file\n"))
+
+	cfg, err := config.LoadConfig("")
+	require.NoError(t, err)
+	require.Equal(t, "file", cfg.Auth.JWTKey)
+
+	t.Setenv("JWT_KEY_FILE", filepath.Join(t.TempDir(), "missing"))
+	_, err = config.LoadConfig("")
+	require.ErrorContains(t, err, "can not read JWT_KEY_FILE")
+}
+
+func TestValidate(t *testing.T) {
+	t.Setenv("STORAGE", config.StorageMemory)
+	t.Setenv("JWT_KEY", "key")
+	unsetenv(t, "JWT_KEY_FILE")
+	defaults, err := config.LoadConfig("")
+	require.NoError(t, err)
+	require.NoError(t, defaults.Validate())
+
+	tests := []struct {
+		name   string
+		modify func(cfg *config.Config)
+		err    string
+	}{
+		{
+			name:   "missing secret",
+			modify: func(cfg *config.Config) { cfg.Auth.JWTKey = "" },
+			err:    "invalid config: JWT_KEY is required",
+		},
+		{
+			name:   "unknown value",
+			modify: func(cfg *config.Config) { cfg.Log.Level = "verbose" },
+			err:    `invalid config: LOG_LEVEL must be one of debug, info, warn, error, got "verbose"`,
+		},
+		{
+			name:   "out of range",
+			modify: func(cfg *config.Config) { cfg.Tracing.SampleRatio = 2 },
+			err:    "invalid config: TRACING_SAMPLE_RATIO must be at most 1, got 2",
+		},
+		{
+			name:   "related settings",
+			modify: func(cfg *config.Config) { cfg.Jobs.StaleAfter = time.Second },
+			err:    "invalid config: JOB_STALE_AFTER must be greater than JOB_HEARTBEAT_INTERVAL, got 1s",
+		},
+		{
+			name:   "required with",
+			modify: func(cfg *config.Config) { cfg.Auth.OIDC.Issuer = "https://issuer" },
+			err:    "invalid config: OIDC_CLIENT_ID is required with OIDC_ISSUER; OIDC_REDIRECT_URL is required with OIDC_ISSUER",
+		},
+		{
+			name:   "tls key",
+			modify: func(cfg *config.Config) { cfg.Server.TLSCertFile = "cert.pem" },
+			err:    "invalid config: SERVER_TLS_KEY_FILE is required with SERVER_TLS_CERT_FILE",
+		},
+		{
+			name:   "list item",
+			modify: func(cfg *config.Config) { cfg.Server.TrustedProxies = []string{"10.0.0.0/8", "proxy"} },
+			err:    `invalid config: TRUSTED_PROXIES[1] must be IP address or CIDR, got "proxy"`,
+		},
+		{
+			name:   "redis rate limits",
+			modify: func(cfg *config.Config) { cfg.RateLimit.Store = config.RateLimitStoreRedis },
+			err:    "invalid config: REDIS_ADDRESS is required with redis store of rate limits",
+		},
+		{
+			name:   "postgres storage",
+			modify: func(cfg *config.Config) { cfg.Storage.Type = config.StoragePostgres },
+			err:    "invalid config: POSTGRES_ADDRESS is required with postgres storage",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaults
+			tt.modify(&cfg)
+			require.EqualError(t, cfg.Validate(), tt.err)
+		})
+	}
+}
+
+func writeFile(t *testing.T, name, data string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	return path
+}
+
+// unsetenv unsets variable for test, it is restored on cleanup together with variables set by .env file
+func unsetenv(t *testing.T, key string) {
+	t.Setenv(key, "")
+	require.NoError(t, os.Unsetenv(key))
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// redacted replaces values of secrets in dump of config
+const redacted = "REDACTED"
+
+// Dump returns config as lines of .env file in order of fields, values of fields tagged
+// with secret:"true" are redacted
+func (c Config) Dump() []string {
+	return dump(reflect.ValueOf(c), nil)
+}
+
+func dump(v reflect.Value, lines []string) []string {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := field.Tag.Lookup("env")
+		if !ok {
+			if field.Type.Kind() == reflect.Struct {
+				lines = dump(v.Field(i), lines)
+			}
+			continue
+		}
+		value := formatValue(v.Field(i))
+		if field.Tag.Get("secret") == "true" && value != "" {
+			value = redacted
+		}
+		lines = append(lines, name+"="+value)
+	}
+	return lines
+}
+
+// formatValue formats value as it is parsed from environment
+func formatValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = formatValue(v.Index(i))
+		}
+		return strings.Join(items, ",")
+	case reflect.Map:
+		items := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			items = append(items, formatValue(iter.Key())+":"+formatValue(iter.Value()))
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	}
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
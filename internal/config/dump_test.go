@@ -0,0 +1,30 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDump(t *testing.T) {
+	cfg := config.Config{
+		Postgres: config.PostgresConfig{
+			Password: "password",
+			Replicas: []string{"replica1:5432", "replica2:5432"},
+		},
+		Cache: config.CacheConfig{TTL: time.Minute},
+		Auth: config.AuthConfig{
+			OIDC: config.OIDCConfig{RoleMapping: map[string]string{"b": "user", "a": "admin"}},
+		},
+	}
+	lines := cfg.Dump()
+	require.Contains(t, lines, "POSTGRES_PASSWORD=REDACTED")
+	require.Contains(t, lines, "POSTGRES_REPLICAS=replica1:5432,replica2:5432")
+	require.Contains(t, lines, "CACHE_TTL=1m0s")
+	require.Contains(t, lines, "OIDC_ROLE_MAPPING=a:admin,b:user")
+	// empty secrets are shown, so that missing secret is visible
+	require.Contains(t, lines, "JWT_KEY=")
+	require.Equal(t, "SERVER_ADDRESS=", lines[0])
+}
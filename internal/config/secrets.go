@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// secretFileSuffix is suffix of variables naming files of secrets, e.g. JWT_KEY_FILE
+const secretFileSuffix = "_FILE"
+
+// readSecretFiles sets secrets from files named by variables with _FILE suffix, so that secrets
+// can be mounted as files (Docker secrets) instead of being passed in environment.
+// Secret from file overrides value of variable.
+func readSecretFiles(config *Config) error {
+	return readSecrets(reflect.ValueOf(config).Elem())
+}
+
+func readSecrets(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := field.Tag.Lookup("env")
+		if !ok {
+			if field.Type.Kind() == reflect.Struct {
+				if err := readSecrets(v.Field(i)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		path := os.Getenv(name + secretFileSuffix)
+		if field.Tag.Get("secret") != "true" || path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("can not read %s: %w", name+secretFileSuffix, err)
+		}
+		v.Field(i).SetString(strings.TrimRight(string(data), "\r\n"))
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var validate = newValidator()
+
+// newValidator creates validator naming fields by their variables
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(envName)
+	return v
+}
+
+// envName returns variable of field, fields of sections have no variables and are named as they are
+func envName(field reflect.StructField) string {
+	if name := field.Tag.Get("env"); name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// Validate checks that required settings are set and settings are in their ranges
+func (c Config) Validate() error {
+	err := validate.Struct(c)
+	var fieldErrs validator.ValidationErrors
+	if errors.As(err, &fieldErrs) {
+		msgs := make([]string, len(fieldErrs))
+		for i, fieldErr := range fieldErrs {
+			msgs[i] = describe(fieldErr)
+		}
+		return fmt.Errorf("invalid config: %s", strings.Join(msgs, "; "))
+	}
+	if err != nil {
+		return err
+	}
+	if c.Storage.Type == StoragePostgres && c.Postgres.Address == "" {
+		return errors.New("invalid config: POSTGRES_ADDRESS is required with postgres storage")
+	}
+	if c.RateLimit.Store == RateLimitStoreRedis && c.Redis.Address == "" {
+		return errors.New("invalid config: REDIS_ADDRESS is required with redis store of rate limits")
+	}
+	return nil
+}
+
+// describe describes failed validation of field by its variable
+func describe(err validator.FieldError) string {
+	switch err.Tag() {
+	case "required":
+		return err.Field() + " is required"
+	case "required_with":
+		return fmt.Sprintf("%s is required with %s", err.Field(), siblingName(err))
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s, got %q", err.Field(), strings.ReplaceAll(err.Param(), " ", ", "), err.Value())
+	case "min", "gte":
+		return fmt.Sprintf("%s must be at least %s, got %v", err.Field(), err.Param(), err.Value())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s, got %v", err.Field(), err.Param(), err.Value())
+	case "lte":
+		return fmt.Sprintf("%s must be at most %s, got %v", err.Field(), err.Param(), err.Value())
+	case "gtefield":
+		return fmt.Sprintf("%s must be at least %s, got %v", err.Field(), siblingName(err), err.Value())
+	case "ip|cidr":
+		return fmt.Sprintf("%s must be IP address or CIDR, got %q", err.Field(), err.Value())
+	case "gtfield":
+		return fmt.Sprintf("%s must be greater than %s, got %v", err.Field(), siblingName(err), err.Value())
+	}
+	return fmt.Sprintf("%s is invalid (%s)", err.Field(), err.Tag())
+}
+
+// siblingName returns variable of field of the same section named in param of failed validation
+func siblingName(err validator.FieldError) string {
+	t := reflect.TypeOf(Config{})
+	path := strings.Split(err.StructNamespace(), ".")
+	// namespace starts with name of config and ends with name of field
+	for _, name := range path[1 : len(path)-1] {
+		field, _ := t.FieldByName(name)
+		t = field.Type
+	}
+	if field, ok := t.FieldByName(err.Param()); ok {
+		return envName(field)
+	}
+	return err.Param()
+}
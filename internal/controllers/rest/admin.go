@@ -0,0 +1,174 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type adminUser struct {
+	profile
+	Disabled              bool `json:"disabled"`
+	PasswordResetRequired bool `json:"password_reset_required"`
+}
+
+type roleUpdate struct {
+	Role string `json:"role" validate:"required,oneof=user admin"`
+}
+
+func domainToAdminUser(u domain.User) adminUser {
+	return adminUser{
+		profile:               domainToProfile(u),
+		Disabled:              u.Disabled,
+		PasswordResetRequired: u.PasswordResetToken != "",
+	}
+}
+
+// parseUserID parses user id from path param
+func (s *Server) parseUserID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		s.logger(c).Error("can not parse user id", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return 0, false
+	}
+	return id, true
+}
+
+// ListUsers selects users according query params query, limit and offset, limit is at most maxLimit
+func (s *Server) ListUsers(c *gin.Context) {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil {
+		s.logger(c).Warn("can not parse limit", zap.Error(err))
+		limit = defaultLimit
+	}
+	if limit <= 0 {
+		limit = defaultLimit
+	} else if limit > maxLimit {
+		limit = maxLimit
+	}
+	offset, err := strconv.Atoi(c.Query("offset"))
+	if err != nil {
+		s.logger(c).Warn("can not parse offset", zap.Error(err))
+		offset = 0
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	filter := domain.UserFilter{
+		Query:  c.Query("query"),
+		Limit:  limit,
+		Offset: offset,
+	}
+	users, err := s.admin.ListUsers(c.Request.Context(), currentUser(c), filter)
+	if err != nil {
+		s.logger(c).Error("can not select users", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	result := make([]adminUser, 0, len(users))
+	for _, u := range users {
+		result = append(result, domainToAdminUser(u))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetUser gets user by id from path param
+func (s *Server) GetUser(c *gin.Context) {
+	id, ok := s.parseUserID(c)
+	if !ok {
+		return
+	}
+	user, err := s.admin.GetUser(c.Request.Context(), currentUser(c), id)
+	if err != nil {
+		s.logger(c).Error("can not get user", zap.Error(err))
+		s.respondError(c, userErrorStatus(err), err)
+		return
+	}
+	c.JSON(http.StatusOK, domainToAdminUser(*user))
+}
+
+// SetUserRole changes role of user
+func (s *Server) SetUserRole(c *gin.Context) {
+	id, ok := s.parseUserID(c)
+	if !ok {
+		return
+	}
+	var r roleUpdate
+	err := c.ShouldBind(&r)
+	if err != nil {
+		s.logger(c).Error("can not bind role", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	err = validate.Struct(r)
+	if err != nil {
+		s.logger(c).Error("can not validate role", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	user, err := s.admin.SetRole(c.Request.Context(), currentUser(c), id, domain.Role(r.Role))
+	if err != nil {
+		s.logger(c).Error("can not set user role", zap.Error(err))
+		s.respondError(c, userErrorStatus(err), err)
+		return
+	}
+	c.JSON(http.StatusOK, domainToAdminUser(*user))
+}
+
+// DisableUser disables user
+func (s *Server) DisableUser(c *gin.Context) {
+	s.setUserDisabled(c, true)
+}
+
+// EnableUser enables user
+func (s *Server) EnableUser(c *gin.Context) {
+	s.setUserDisabled(c, false)
+}
+
+func (s *Server) setUserDisabled(c *gin.Context, disabled bool) {
+	id, ok := s.parseUserID(c)
+	if !ok {
+		return
+	}
+	user, err := s.admin.SetDisabled(c.Request.Context(), currentUser(c), id, disabled)
+	if err != nil {
+		s.logger(c).Error("can not change user status", zap.Error(err))
+		s.respondError(c, userErrorStatus(err), err)
+		return
+	}
+	c.JSON(http.StatusOK, domainToAdminUser(*user))
+}
+
+// ForcePasswordReset forces user to reset password
+func (s *Server) ForcePasswordReset(c *gin.Context) {
+	id, ok := s.parseUserID(c)
+	if !ok {
+		return
+	}
+	err := s.admin.ForcePasswordReset(c.Request.Context(), currentUser(c), id)
+	if err != nil {
+		s.logger(c).Error("can not force password reset", zap.Error(err))
+		s.respondError(c, userErrorStatus(err), err)
+		return
+	}
+	c.JSON(http.StatusOK, "")
+}
+
+// DeleteUser deletes user by id
+func (s *Server) DeleteUser(c *gin.Context) {
+	id, ok := s.parseUserID(c)
+	if !ok {
+		return
+	}
+	err := s.admin.DeleteUser(c.Request.Context(), currentUser(c), id)
+	if err != nil {
+		s.logger(c).Error("can not delete user", zap.Error(err))
+		s.respondError(c, userErrorStatus(err), err)
+		return
+	}
+	c.JSON(http.StatusOK, "")
+}
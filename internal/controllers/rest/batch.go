@@ -0,0 +1,149 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	batchMethod = ":batch"
+
+	batchModeAtomic     = "atomic"
+	batchModeBestEffort = "best_effort"
+
+	defaultBatchLimit = 1000
+)
+
+type batchOperation struct {
+	Op      string    `json:"op" validate:"required,oneof=create update delete"`
+	ID      uuid.UUID `json:"id"`
+	Company *company  `json:"company"`
+}
+
+type batchRequest struct {
+	Mode       string           `json:"mode" validate:"omitempty,oneof=atomic best_effort"`
+	Operations []batchOperation `json:"operations" validate:"required,min=1"`
+}
+
+type batchResult struct {
+	Index  int        `json:"index"`
+	Op     string     `json:"op"`
+	Status int        `json:"status"`
+	ID     *uuid.UUID `json:"id,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// validateOperation validates operation of batch and converts it to domain
+func validateOperation(op batchOperation) (domain.CompanyOperation, error) {
+	err := validate.Struct(op)
+	if err != nil {
+		return domain.CompanyOperation{}, err
+	}
+	action := domain.CompanyAction(op.Op)
+	if action != domain.CompanyCreated && op.ID == uuid.Nil {
+		return domain.CompanyOperation{}, fmt.Errorf("id is required for %s operation", op.Op)
+	}
+	result := domain.CompanyOperation{Action: action, ID: op.ID}
+	if action == domain.CompanyDeleted {
+		return result, nil
+	}
+	if op.Company == nil {
+		return domain.CompanyOperation{}, fmt.Errorf("company is required for %s operation", op.Op)
+	}
+	err = validate.Struct(op.Company)
+	if err != nil {
+		return domain.CompanyOperation{}, err
+	}
+	result.Company = companyToDomain(*op.Company)
+	return result, nil
+}
+
+// batchStatus returns http status for result of batch operation
+func batchStatus(op domain.CompanyOperation, err error) int {
+	switch {
+	case err == nil && op.Action == domain.CompanyCreated:
+		return http.StatusCreated
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, domain.ErrCompanyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrBatchRolledBack):
+		return http.StatusFailedDependency
+	}
+	return http.StatusBadRequest
+}
+
+// BatchCompanies executes create, update and delete operations of batch, atomically
+// or in best-effort mode, and returns result of every operation
+func (s *Server) BatchCompanies(c *gin.Context) {
+	var req batchRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		s.logger(c).Error("can not bind batch", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	err = validate.Struct(req)
+	if err != nil {
+		s.logger(c).Error("can not validate batch", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if len(req.Operations) > s.batchLimit {
+		c.JSON(http.StatusRequestEntityTooLarge, errorBody(c, fmt.Sprintf("batch exceeds %d operations", s.batchLimit)))
+		return
+	}
+	atomic := req.Mode != batchModeBestEffort
+
+	ops := make([]domain.CompanyOperation, 0, len(req.Operations))
+	// indexes of valid operations in request
+	indexes := make([]int, 0, len(req.Operations))
+	results := make([]batchResult, len(req.Operations))
+	invalid := false
+	for i, op := range req.Operations {
+		results[i] = batchResult{Index: i, Op: op.Op}
+		domainOp, err := validateOperation(op)
+		if err != nil {
+			invalid = true
+			results[i].Status = http.StatusBadRequest
+			results[i].Error = err.Error()
+			continue
+		}
+		ops = append(ops, domainOp)
+		indexes = append(indexes, i)
+	}
+
+	if invalid && atomic {
+		for _, i := range indexes {
+			results[i].Status = http.StatusFailedDependency
+			results[i].Error = domain.ErrBatchRolledBack.Error()
+		}
+		c.JSON(http.StatusMultiStatus, gin.H{"results": results})
+		return
+	}
+
+	status := http.StatusOK
+	if invalid {
+		status = http.StatusMultiStatus
+	}
+	for j, res := range s.companies.Batch(c.Request.Context(), ops, atomic) {
+		i := indexes[j]
+		results[i].Status = batchStatus(ops[j], res.Err)
+		if res.ID != uuid.Nil {
+			id := res.ID
+			results[i].ID = &id
+		}
+		if res.Err != nil {
+			status = http.StatusMultiStatus
+			results[i].Error = res.Err.Error()
+			s.logger(c).Error("can not execute batch operation", zap.Int("index", i), zap.Error(res.Err))
+		}
+	}
+	c.JSON(status, gin.H{"results": results})
+}
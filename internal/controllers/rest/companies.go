@@ -1,9 +1,12 @@
 package rest
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -11,43 +14,56 @@ import (
 
 const (
 	defaultLimit = 20
+	// maxLimit is maximum number of items in one page of listing
+	maxLimit = 100
 )
 
-// GetCompany gets company by id from path param
+// GetCompany gets company by id from path param,
+// with query param as_of it gets state of company at that moment
 func (s *Server) GetCompany(c *gin.Context) {
 	paramID := c.Param("id")
 	id, err := uuid.Parse(paramID)
 	if err != nil {
-		s.log.Error("can not parse id", zap.Error(err))
-		c.JSON(http.StatusBadRequest, err)
+		s.logger(c).Error("can not parse id", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
 		return
 	}
-	company, err := s.companies.Get(c.Request.Context(), id)
+	var company domain.Company
+	if asOfQuery := c.Query("as_of"); asOfQuery != "" {
+		var asOf time.Time
+		asOf, err = time.Parse(time.RFC3339, asOfQuery)
+		if err != nil {
+			s.logger(c).Error("can not parse as_of", zap.Error(err))
+			s.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		company, err = s.companies.GetAsOf(c.Request.Context(), id, asOf)
+	} else {
+		company, err = s.companies.Get(c.Request.Context(), id)
+	}
 	if err != nil {
-		s.log.Error("can not get company", zap.Error(err))
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not get company", zap.Error(err))
+		s.respondError(c, http.StatusNotFound, err)
 		return
 	}
 	c.JSON(http.StatusOK, domainToCompany(company))
 }
 
-// SelectCompanies selects list of company according query params limit and offset
+// SelectCompanies selects list of company according query params limit and offset, limit is at most maxLimit,
+// admins can include deleted companies with query param include_deleted=true
 func (s *Server) SelectCompanies(c *gin.Context) {
-	limitQuery, offsetQuery := c.Query("limit"), c.Query("offset")
-	limit, err := strconv.Atoi(limitQuery)
+	filter, err := s.companyFilter(c, defaultLimit)
 	if err != nil {
-		s.log.Warn("can not parse limit", zap.Error(err))
-		limit = defaultLimit
+		s.respondError(c, http.StatusForbidden, err)
+		return
 	}
-	offset, err := strconv.Atoi(offsetQuery)
-	if err != nil {
-		s.log.Warn("can not parse offset", zap.Error(err))
-		offset = 0
+	if filter.Limit > maxLimit {
+		filter.Limit = maxLimit
 	}
-	companies, err := s.companies.Select(c.Request.Context(), limit, offset)
+	companies, err := s.companies.Select(c.Request.Context(), filter)
 	if err != nil {
-		s.log.Error("can not select companies", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not select companies", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
 		return
 	}
 	result := make([]company, 0)
@@ -57,44 +73,110 @@ func (s *Server) SelectCompanies(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
+// companyFilter parses query params limit, offset and include_deleted of listing of companies
+func (s *Server) companyFilter(c *gin.Context, defaultLimit int) (domain.CompanyFilter, error) {
+	limit, offset := defaultLimit, 0
+	var err error
+	if limitQuery := c.Query("limit"); limitQuery != "" {
+		limit, err = strconv.Atoi(limitQuery)
+		if err != nil {
+			s.logger(c).Warn("can not parse limit", zap.Error(err))
+			limit = defaultLimit
+		}
+	}
+	if offsetQuery := c.Query("offset"); offsetQuery != "" {
+		offset, err = strconv.Atoi(offsetQuery)
+		if err != nil {
+			s.logger(c).Warn("can not parse offset", zap.Error(err))
+			offset = 0
+		}
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	includeDeleted := c.Query("include_deleted") == "true"
+	if user, ok := optionalUser(c); includeDeleted && (!ok || user.Role != domain.RoleAdmin) {
+		return domain.CompanyFilter{}, errors.New("admin role is required to include deleted companies")
+	}
+	return domain.CompanyFilter{
+		Limit:          limit,
+		Offset:         offset,
+		IncludeDeleted: includeDeleted,
+	}, nil
+}
+
 // DeleteCompany deletes company by id from path param
 func (s *Server) DeleteCompany(c *gin.Context) {
 	paramID := c.Param("id")
 	id, err := uuid.Parse(paramID)
 	if err != nil {
-		s.log.Error("can not parse id", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not parse id", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
 		return
 	}
 	err = s.companies.Delete(c.Request.Context(), id)
 	if err != nil {
-		s.log.Error("can not delete company", zap.Error(err))
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not delete company", zap.Error(err))
+		s.respondError(c, http.StatusNotFound, err)
 		return
 	}
 	c.JSON(http.StatusOK, "")
 }
 
+// RestoreCompany restores deleted company by id from path param
+func (s *Server) RestoreCompany(c *gin.Context) {
+	paramID := c.Param("id")
+	id, err := uuid.Parse(paramID)
+	if err != nil {
+		s.logger(c).Error("can not parse id", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	err = s.companies.Restore(c.Request.Context(), id)
+	if err != nil {
+		s.logger(c).Error("can not restore company", zap.Error(err))
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrCompanyNotFound) {
+			status = http.StatusNotFound
+		}
+		s.respondError(c, status, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id})
+}
+
+// CompanyMethod dispatches custom methods of companies collection, e.g. POST /companies:batch
+func (s *Server) CompanyMethod(c *gin.Context) {
+	switch c.Param("method") {
+	case batchMethod:
+		s.BatchCompanies(c)
+	case importMethod:
+		s.ImportCompanies(c)
+	default:
+		c.JSON(http.StatusNotFound, errorBody(c, "unknown method"))
+	}
+}
+
 // CreateCompany creates new company
 func (s *Server) CreateCompany(c *gin.Context) {
 	var cmp company
 	err := c.ShouldBind(&cmp)
 	if err != nil {
-		s.log.Error("can not bind company", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not bind company", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
 		return
 	}
 	err = validate.Struct(cmp)
 	if err != nil {
-		s.log.Error("can not validate company", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not validate company", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
 		return
 	}
 	newCompany := companyToDomain(cmp)
 	id, err := s.companies.Create(c.Request.Context(), newCompany)
 	if err != nil {
-		s.log.Error("can not create new company", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not create new company", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{"id": id})
@@ -105,29 +187,61 @@ func (s *Server) UpdateCompany(c *gin.Context) {
 	var cmp company
 	err := c.ShouldBind(&cmp)
 	if err != nil {
-		s.log.Error("can not bind company", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not bind company", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
 		return
 	}
 	paramID := c.Param("id")
 	id, err := uuid.Parse(paramID)
 	if err != nil {
-		s.log.Error("can not parse id", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not parse id", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
 		return
 	}
 	err = validate.Struct(cmp)
 	if err != nil {
-		s.log.Error("can not validate company", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not validate company", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
 		return
 	}
 	newCompany := companyToDomain(cmp)
 	err = s.companies.Update(c.Request.Context(), id, newCompany)
 	if err != nil {
-		s.log.Error("can not update company", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not update company", zap.Error(err))
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrCompanyNotFound) {
+			status = http.StatusNotFound
+		}
+		s.respondError(c, status, err)
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{"id": id})
 }
+
+// CompanyHistory selects history of changes of company by id from path param
+func (s *Server) CompanyHistory(c *gin.Context) {
+	paramID := c.Param("id")
+	id, err := uuid.Parse(paramID)
+	if err != nil {
+		s.logger(c).Error("can not parse id", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	history, err := s.companies.History(c.Request.Context(), id)
+	if err != nil {
+		s.logger(c).Error("can not select company history", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if len(history) == 0 {
+		err = domain.ErrCompanyNotFound
+		s.logger(c).Error("can not select company history", zap.Error(err))
+		s.respondError(c, http.StatusNotFound, err)
+		return
+	}
+	result := make([]companyChange, 0, len(history))
+	for _, h := range history {
+		result = append(result, domainToCompanyChange(h))
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -1,17 +1,30 @@
 package rest
 
 import (
+	"time"
+
 	"github.com/Ragnar-BY/companies-handler/internal/domain"
 	"github.com/google/uuid"
 )
 
 type company struct {
-	ID                uuid.UUID `json:"id"`
-	Name              string    `json:"name" validate:"required,min=4,max=15"`
-	Description       string    `json:"description" validate:"max=3000"`
-	AmountOfEmployees int       `json:"amount_of_employees" validate:"required,min=1"`
-	Registered        bool      `json:"registered" validate:"required"`
-	Type              string    `json:"type" validate:"required,oneof='Corporations' 'NonProfit' 'Cooperative' 'Sole Proprietorship'"`
+	ID                uuid.UUID  `json:"id"`
+	Name              string     `json:"name" validate:"required,min=4,max=15"`
+	Description       string     `json:"description" validate:"max=3000"`
+	AmountOfEmployees int        `json:"amount_of_employees" validate:"required,min=1"`
+	Registered        bool       `json:"registered" validate:"required"`
+	Type              string     `json:"type" validate:"required,oneof='Corporations' 'NonProfit' 'Cooperative' 'Sole Proprietorship'"`
+	DeletedAt         *time.Time `json:"deleted_at,omitempty"`
+}
+
+type companyChange struct {
+	ID        int64     `json:"id"`
+	Action    string    `json:"action"`
+	Before    *company  `json:"before"`
+	After     *company  `json:"after"`
+	ActorID   int64     `json:"actor_id,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 func companyToDomain(c company) domain.Company {
@@ -33,5 +46,25 @@ func domainToCompany(c domain.Company) company {
 		AmountOfEmployees: c.AmountOfEmployees,
 		Registered:        c.Registered,
 		Type:              string(c.Type),
+		DeletedAt:         c.DeletedAt,
+	}
+}
+
+func domainToCompanyChange(c domain.CompanyChange) companyChange {
+	change := companyChange{
+		ID:        c.ID,
+		Action:    string(c.Action),
+		ActorID:   c.ActorID,
+		RequestID: c.RequestID,
+		CreatedAt: c.CreatedAt,
+	}
+	if c.Before != nil {
+		before := domainToCompany(*c.Before)
+		change.Before = &before
+	}
+	if c.After != nil {
+		after := domainToCompany(*c.After)
+		change.After = &after
 	}
+	return change
 }
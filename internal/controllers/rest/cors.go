@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSPolicy allows browser frontends on other origins to call server
+type CORSPolicy struct {
+	// AllowedOrigins are origins allowed to call server, * allows any origin
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// ExposedHeaders are response headers readable by frontend
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers cache result of preflight request
+	MaxAge time.Duration
+}
+
+// CORS is middleware to answer preflight requests and add CORS headers to responses to allowed origins.
+// Requests of other origins are served without CORS headers, so that browsers block them.
+func (s *Server) CORS() gin.HandlerFunc {
+	origins := make(map[string]bool, len(s.cors.AllowedOrigins))
+	for _, origin := range s.cors.AllowedOrigins {
+		origins[origin] = true
+	}
+	anyOrigin := origins["*"]
+	methods := strings.Join(s.cors.AllowedMethods, ", ")
+	headers := strings.Join(s.cors.AllowedHeaders, ", ")
+	exposed := strings.Join(s.cors.ExposedHeaders, ", ")
+	maxAge := strconv.Itoa(int(s.cors.MaxAge.Seconds()))
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !anyOrigin && !origins[origin] {
+			if preflight {
+				c.AbortWithStatus(http.StatusNoContent)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		// credentials are not allowed with wildcard origin, so that origin of request is returned then
+		if anyOrigin && !s.cors.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if s.cors.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if preflight {
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", methods)
+			h.Set("Access-Control-Allow-Headers", headers)
+			h.Set("Access-Control-Max-Age", maxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		if exposed != "" {
+			h.Set("Access-Control-Expose-Headers", exposed)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,214 @@
+package rest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+	ExportFormatXLSX   = "xlsx"
+)
+
+const (
+	// maxExportRows is maximum number of companies in export, larger registry is exported in pages
+	maxExportRows = 1000000
+	// maxXLSXRows is maximum number of companies in XLSX export, its workbook is assembled before it is sent
+	maxXLSXRows = 100000
+)
+
+// exportColumns are columns of CSV and XLSX export, they are named as JSON fields of company
+var exportColumns = []string{"id", "name", "description", "amount_of_employees", "registered", "type", "deleted_at"}
+
+// companyEncoder writes companies of export
+type companyEncoder interface {
+	Encode(c company) error
+	// Close writes rest of export, it is not called if export failed
+	Close() error
+}
+
+// exportRecord returns values of export columns of company
+func exportRecord(c company) []string {
+	var deletedAt string
+	if c.DeletedAt != nil {
+		deletedAt = c.DeletedAt.Format(time.RFC3339)
+	}
+	return []string{
+		c.ID.String(),
+		c.Name,
+		c.Description,
+		strconv.Itoa(c.AmountOfEmployees),
+		strconv.FormatBool(c.Registered),
+		c.Type,
+		deletedAt,
+	}
+}
+
+type csvEncoder struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (e *csvEncoder) Encode(c company) error {
+	if !e.header {
+		e.header = true
+		if err := e.w.Write(exportColumns); err != nil {
+			return err
+		}
+	}
+	return e.w.Write(exportRecord(c))
+}
+
+func (e *csvEncoder) Close() error {
+	if !e.header {
+		e.header = true
+		if err := e.w.Write(exportColumns); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(c company) error {
+	return e.enc.Encode(c)
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return nil
+}
+
+// xlsxEncoder writes rows with stream writer of excelize, which keeps only part of sheet in memory.
+// Workbook is written to w on Close, since XLSX is zip archive with sheet inside.
+type xlsxEncoder struct {
+	w    io.Writer
+	file *excelize.File
+	sw   *excelize.StreamWriter
+	row  int
+}
+
+func newXLSXEncoder(w io.Writer) (*xlsxEncoder, error) {
+	file := excelize.NewFile()
+	sw, err := file.NewStreamWriter("Sheet1")
+	if err != nil {
+		return nil, err
+	}
+	header := make([]any, 0, len(exportColumns))
+	for _, column := range exportColumns {
+		header = append(header, column)
+	}
+	if err = sw.SetRow("A1", header); err != nil {
+		return nil, err
+	}
+	return &xlsxEncoder{w: w, file: file, sw: sw, row: 1}, nil
+}
+
+func (e *xlsxEncoder) Encode(c company) error {
+	e.row++
+	cell, err := excelize.CoordinatesToCellName(1, e.row)
+	if err != nil {
+		return err
+	}
+	var deletedAt any
+	if c.DeletedAt != nil {
+		deletedAt = c.DeletedAt.Format(time.RFC3339)
+	}
+	return e.sw.SetRow(cell, []any{
+		c.ID.String(), c.Name, c.Description, c.AmountOfEmployees, c.Registered, c.Type, deletedAt,
+	})
+}
+
+func (e *xlsxEncoder) Close() error {
+	defer e.file.Close()
+	if err := e.sw.Flush(); err != nil {
+		return err
+	}
+	return e.file.Write(e.w)
+}
+
+// exportLimit returns limit of export of format, export without limit has maximum number of companies
+func exportLimit(limit int, format string) (int, error) {
+	maxRows := maxExportRows
+	if format == ExportFormatXLSX {
+		maxRows = maxXLSXRows
+	}
+	if limit > maxRows {
+		return 0, fmt.Errorf("limit of %s export must be at most %d", format, maxRows)
+	}
+	if limit <= 0 {
+		limit = maxRows
+	}
+	return limit, nil
+}
+
+// newCompanyEncoder creates encoder of export format and returns its content type
+func newCompanyEncoder(w io.Writer, format string) (companyEncoder, string, error) {
+	switch format {
+	case ExportFormatCSV:
+		return &csvEncoder{w: csv.NewWriter(w)}, "text/csv", nil
+	case ExportFormatNDJSON:
+		return &ndjsonEncoder{enc: json.NewEncoder(w)}, "application/x-ndjson", nil
+	case ExportFormatXLSX:
+		enc, err := newXLSXEncoder(w)
+		return enc, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", err
+	}
+	return nil, "", fmt.Errorf("unknown export format %q", format)
+}
+
+// ExportCompanies streams companies matching listing filters as downloadable file of format
+// from query param format: csv (default), ndjson or xlsx. Number of companies is limited by exportLimit.
+// Export is stopped when client disconnects.
+func (s *Server) ExportCompanies(c *gin.Context) {
+	filter, err := s.companyFilter(c, 0)
+	if err != nil {
+		s.respondError(c, http.StatusForbidden, err)
+		return
+	}
+	format := c.DefaultQuery("format", ExportFormatCSV)
+	filter.Limit, err = exportLimit(filter.Limit, format)
+	if err != nil {
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	enc, contentType, err := newCompanyEncoder(c.Writer, format)
+	if err != nil {
+		s.logger(c).Error("can not export companies", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="companies.%s"`, format))
+	s.clearWriteDeadline(c)
+
+	err = s.companies.Export(c.Request.Context(), filter, func(cmp domain.Company) error {
+		return enc.Encode(domainToCompany(cmp))
+	})
+	if err == nil {
+		err = enc.Close()
+	}
+	if err != nil {
+		s.logger(c).Error("can not export companies", zap.Error(err))
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Disposition")
+			s.respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		// response is already partially sent, usually client has disconnected
+		c.Abort()
+	}
+}
@@ -0,0 +1,69 @@
+package rest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Ragnar-BY/companies-handler/internal/controllers/rest"
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+// exportingCompanies records filter of export and exports nothing
+type exportingCompanies struct {
+	rest.CompaniesUsecase
+	filter domain.CompanyFilter
+}
+
+func (c *exportingCompanies) Export(_ context.Context, filter domain.CompanyFilter, _ func(domain.Company) error) error {
+	c.filter = filter
+	return nil
+}
+
+func TestExportLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		limit  int
+	}{
+		{
+			name:   "csv without limit",
+			query:  "",
+			status: http.StatusOK,
+			limit:  1000000,
+		},
+		{
+			name:   "csv with limit",
+			query:  "?limit=10",
+			status: http.StatusOK,
+			limit:  10,
+		},
+		{
+			name:   "csv over limit",
+			query:  "?limit=1000001",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "xlsx without limit",
+			query:  "?format=xlsx",
+			status: http.StatusOK,
+			limit:  100000,
+		},
+		{
+			name:   "xlsx over limit",
+			query:  "?format=xlsx&limit=100001",
+			status: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			companies := &exportingCompanies{}
+			h := newTestServer(t, companies)
+			rec := serve(h, http.MethodGet, "/companies/export"+tt.query, "", nil)
+			require.Equal(t, tt.status, rec.Code, rec.Body.String())
+			require.Equal(t, tt.limit, companies.filter.Limit)
+		})
+	}
+}
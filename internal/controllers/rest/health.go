@@ -0,0 +1,63 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	healthOK   = "ok"
+	healthFail = "fail"
+)
+
+type healthCheck struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+type healthReport struct {
+	Status       string                 `json:"status"`
+	ShuttingDown bool                   `json:"shutting_down,omitempty"`
+	CheckedAt    time.Time              `json:"checked_at"`
+	Checks       map[string]healthCheck `json:"checks"`
+}
+
+func domainToHealthReport(r domain.HealthReport) healthReport {
+	report := healthReport{
+		Status:       healthOK,
+		ShuttingDown: r.ShuttingDown,
+		CheckedAt:    r.CheckedAt,
+		Checks:       make(map[string]healthCheck, len(r.Checks)),
+	}
+	if !r.Healthy() {
+		report.Status = healthFail
+	}
+	for name, c := range r.Checks {
+		check := healthCheck{Status: healthOK, DurationMS: float64(c.Duration.Microseconds()) / 1000}
+		if c.Err != nil {
+			check.Status = healthFail
+			check.Error = c.Err.Error()
+		}
+		report.Checks[name] = check
+	}
+	return report
+}
+
+// Livez reports that process is alive, it does not check dependencies
+func (s *Server) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": healthOK})
+}
+
+// Readyz reports if instance is ready to serve requests with result of every check of dependencies
+func (s *Server) Readyz(c *gin.Context) {
+	report := s.health.Ready()
+	status := http.StatusOK
+	if !report.Healthy() {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, domainToHealthReport(report))
+}
@@ -0,0 +1,171 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/logging"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	idempotencyKeyHeader       = "Idempotency-Key"
+	idempotentReplayedHeader   = "Idempotent-Replayed"
+	maxIdempotencyKeyLength    = 255
+	idempotencyCompleteTimeout = 5 * time.Second
+	// maxIdempotencyMemoryBody is size of body kept in memory, larger bodies, e.g. uploads, are spooled to file
+	maxIdempotencyMemoryBody = 1 << 20
+)
+
+// recordingWriter records body of response
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// spoolBody reads body of request while writing it to hash, so that it can be read again by handler.
+// Body up to maxIdempotencyMemoryBody is kept in memory, larger body is written to temporary file.
+// Returned function removes the file.
+func spoolBody(body io.Reader, h hash.Hash) (io.ReadCloser, func(), error) {
+	tee := io.TeeReader(body, h)
+	var buf bytes.Buffer
+	_, err := io.CopyN(&buf, tee, maxIdempotencyMemoryBody+1)
+	if errors.Is(err, io.EOF) {
+		return io.NopCloser(&buf), func() {}, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.CreateTemp("", "idempotency-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("can not create file for body: %w", err)
+	}
+	remove := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	if _, err = io.Copy(f, io.MultiReader(&buf, tee)); err != nil {
+		remove()
+		return nil, nil, err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		remove()
+		return nil, nil, fmt.Errorf("can not rewind body: %w", err)
+	}
+	return io.NopCloser(f), remove, nil
+}
+
+// Idempotency is middleware to make POST and PATCH requests with Idempotency-Key header idempotent,
+// it must be used after Auth. Response of request is stored per user and replayed on retries
+// with the same key. Server errors are not stored, such requests can be retried. Body is hashed
+// while it is read, large bodies are kept in temporary file instead of memory.
+func (s *Server) Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyHeader := c.GetHeader(idempotencyKeyHeader)
+		method := c.Request.Method
+		if keyHeader == "" || (method != http.MethodPost && method != http.MethodPatch) {
+			c.Next()
+			return
+		}
+		if len(keyHeader) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, errorBody(c, "idempotency key is too long"))
+			c.Abort()
+			return
+		}
+		// fingerprint is hash of method, path with query and body of request
+		h := sha256.New()
+		h.Write([]byte(method + " " + c.Request.URL.RequestURI() + "\n"))
+		body, remove, err := spoolBody(c.Request.Body, h)
+		if err != nil {
+			s.logger(c).Error("can not read body", zap.Error(err))
+			status := http.StatusBadRequest
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			s.respondError(c, status, err)
+			c.Abort()
+			return
+		}
+		defer remove()
+		c.Request.Body = body
+
+		key := domain.IdempotencyKey{
+			UserID:      currentUser(c).ID,
+			Key:         keyHeader,
+			Fingerprint: hex.EncodeToString(h.Sum(nil)),
+		}
+		stored, started, err := s.idempotency.Start(c.Request.Context(), key)
+		if err != nil {
+			s.logger(c).Error("can not start idempotent request", zap.Error(err))
+			status := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, domain.ErrIdempotencyKeyReused):
+				status = http.StatusUnprocessableEntity
+			case errors.Is(err, domain.ErrIdempotencyKeyInProgress):
+				status = http.StatusConflict
+			}
+			s.respondError(c, status, err)
+			c.Abort()
+			return
+		}
+		key.StartedAt = stored.StartedAt
+		if !started {
+			c.Header(idempotentReplayedHeader, "true")
+			c.Data(stored.Status, stored.ContentType, stored.Response)
+			c.Abort()
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		// response is stored even if client has gone away
+		ctx, cancel := context.WithTimeout(context.Background(), idempotencyCompleteTimeout)
+		defer cancel()
+		defer func() {
+			if r := recover(); r != nil {
+				s.abortIdempotency(ctx, key)
+				panic(r)
+			}
+		}()
+		c.Next()
+
+		if writer.Status() >= http.StatusInternalServerError {
+			s.abortIdempotency(ctx, key)
+			return
+		}
+		key.Status = writer.Status()
+		key.ContentType = writer.Header().Get("Content-Type")
+		key.Response = writer.body.Bytes()
+		if err = s.idempotency.Complete(ctx, key); err != nil {
+			s.logger(c).Error("can not complete idempotent request", zap.Error(err))
+		}
+	}
+}
+
+func (s *Server) abortIdempotency(ctx context.Context, key domain.IdempotencyKey) {
+	if err := s.idempotency.Abort(ctx, key); err != nil {
+		logging.FromContext(ctx).Error("can not abort idempotent request", zap.Error(err))
+	}
+}
@@ -0,0 +1,195 @@
+package rest_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/controllers/rest"
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/service"
+	"github.com/Ragnar-BY/companies-handler/internal/usecase"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// memoryIdempotency is repository of idempotency keys in memory
+type memoryIdempotency struct {
+	mu   sync.Mutex
+	keys map[string]domain.IdempotencyKey
+}
+
+func newMemoryIdempotency() *memoryIdempotency {
+	return &memoryIdempotency{keys: make(map[string]domain.IdempotencyKey)}
+}
+
+func idempotencyMapKey(key domain.IdempotencyKey) string {
+	return fmt.Sprintf("%d/%s", key.UserID, key.Key)
+}
+
+func (m *memoryIdempotency) StartIdempotencyKey(_ context.Context, key domain.IdempotencyKey, ttl, lease time.Duration) (domain.IdempotencyKey, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	stored, ok := m.keys[idempotencyMapKey(key)]
+	leaseExpired := stored.Status == 0 && stored.StartedAt.Before(now.Add(-lease))
+	if ok && stored.ExpiresAt.After(now) && !leaseExpired {
+		return stored, false, nil
+	}
+	key.StartedAt = now
+	key.ExpiresAt = now.Add(ttl)
+	m.keys[idempotencyMapKey(key)] = key
+	return key, true, nil
+}
+
+func (m *memoryIdempotency) CompleteIdempotencyKey(_ context.Context, key domain.IdempotencyKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.keys[idempotencyMapKey(key)]
+	if ok && stored.Status == 0 && stored.StartedAt.Equal(key.StartedAt) {
+		key.ExpiresAt = stored.ExpiresAt
+		m.keys[idempotencyMapKey(key)] = key
+	}
+	return nil
+}
+
+func (m *memoryIdempotency) DeleteIdempotencyKey(_ context.Context, key domain.IdempotencyKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.keys[idempotencyMapKey(key)]
+	if ok && stored.Status == 0 && stored.StartedAt.Equal(key.StartedAt) {
+		delete(m.keys, idempotencyMapKey(key))
+	}
+	return nil
+}
+
+func (m *memoryIdempotency) PurgeIdempotencyKeys(context.Context) (int64, error) {
+	return 0, nil
+}
+
+// creatingCompanies counts created companies
+type creatingCompanies struct {
+	rest.CompaniesUsecase
+	mu      sync.Mutex
+	created int
+}
+
+func (c *creatingCompanies) Create(context.Context, domain.Company) (uuid.UUID, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.created++
+	return uuid.New(), nil
+}
+
+func newIdempotencyServer(t *testing.T, repo *memoryIdempotency, lease time.Duration) (http.Handler, *creatingCompanies) {
+	t.Helper()
+	companies := &creatingCompanies{}
+	uc := usecase.NewIdempotencyUsecase(service.NewIdempotencyService(repo, zap.NewNop()), time.Hour, lease)
+	return newTestServer(t, companies, rest.WithIdempotency(uc)), companies
+}
+
+func companyBody(name string) string {
+	return fmt.Sprintf(`{"name":%q,"amount_of_employees":10,"registered":true,"type":"NonProfit"}`, name)
+}
+
+func TestIdempotency(t *testing.T) {
+	headers := map[string]string{
+		"Authorization":   "Bearer " + validToken,
+		"Content-Type":    "application/json",
+		"Idempotency-Key": "create",
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		h, companies := newIdempotencyServer(t, newMemoryIdempotency(), time.Minute)
+		first := serve(h, http.MethodPost, "/companies", companyBody("company"), headers)
+		require.Equal(t, http.StatusCreated, first.Code, first.Body.String())
+		require.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+		retry := serve(h, http.MethodPost, "/companies", companyBody("company"), headers)
+		require.Equal(t, http.StatusCreated, retry.Code, retry.Body.String())
+		require.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+		require.Equal(t, first.Body.String(), retry.Body.String())
+		require.Equal(t, 1, companies.created)
+	})
+
+	t.Run("large body", func(t *testing.T) {
+		h, companies := newIdempotencyServer(t, newMemoryIdempotency(), time.Minute)
+		// body larger than memory buffer is spooled to file and read again by handler
+		body := companyBody("company") + strings.Repeat(" ", 2<<20)
+		first := serve(h, http.MethodPost, "/companies", body, headers)
+		require.Equal(t, http.StatusCreated, first.Code, first.Body.String())
+
+		retry := serve(h, http.MethodPost, "/companies", body, headers)
+		require.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+		require.Equal(t, first.Body.String(), retry.Body.String())
+		require.Equal(t, 1, companies.created)
+	})
+
+	t.Run("key reused with other body", func(t *testing.T) {
+		h, companies := newIdempotencyServer(t, newMemoryIdempotency(), time.Minute)
+		rec := serve(h, http.MethodPost, "/companies", companyBody("company"), headers)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		rec = serve(h, http.MethodPost, "/companies", companyBody("other company"), headers)
+		require.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+		require.Equal(t, 1, companies.created)
+	})
+
+	t.Run("key of other user", func(t *testing.T) {
+		h, companies := newIdempotencyServer(t, newMemoryIdempotency(), time.Minute)
+		rec := serve(h, http.MethodPost, "/companies", companyBody("company"), headers)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+		other := map[string]string{
+			"Authorization":   "Bearer " + orgToken,
+			"Content-Type":    "application/json",
+			"Idempotency-Key": "create",
+		}
+		rec = serve(h, http.MethodPost, "/companies", companyBody("company"), other)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		require.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+		require.Equal(t, 2, companies.created)
+	})
+
+	// key of request in progress is taken by retry only after lease
+	inProgress := func(repo *memoryIdempotency, startedAt time.Time) {
+		h, _ := newIdempotencyServer(t, repo, time.Minute)
+		// fingerprint of request is stored by first request, which is then marked as unfinished
+		rec := serve(h, http.MethodPost, "/companies", companyBody("company"), headers)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		for k, key := range repo.keys {
+			key.Status = 0
+			key.Response = nil
+			key.StartedAt = startedAt
+			repo.keys[k] = key
+		}
+	}
+
+	t.Run("in progress", func(t *testing.T) {
+		repo := newMemoryIdempotency()
+		inProgress(repo, time.Now())
+		h, companies := newIdempotencyServer(t, repo, time.Minute)
+		rec := serve(h, http.MethodPost, "/companies", companyBody("company"), headers)
+		require.Equal(t, http.StatusConflict, rec.Code, rec.Body.String())
+		require.Equal(t, 0, companies.created)
+	})
+
+	t.Run("in progress after lease", func(t *testing.T) {
+		repo := newMemoryIdempotency()
+		inProgress(repo, time.Now().Add(-2*time.Minute))
+		h, companies := newIdempotencyServer(t, repo, time.Minute)
+		rec := serve(h, http.MethodPost, "/companies", companyBody("company"), headers)
+		require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+		require.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+		require.Equal(t, 1, companies.created)
+
+		rec = serve(h, http.MethodPost, "/companies", companyBody("company"), headers)
+		require.Equal(t, "true", rec.Header().Get("Idempotent-Replayed"))
+		require.Equal(t, 1, companies.created)
+	})
+}
@@ -0,0 +1,280 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	importMethod = ":import"
+
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+
+	// maxImportErrors limits number of row errors kept in report, invalid rows are still counted
+	maxImportErrors = 10000
+	// maxImportLineSize is maximum size of line of NDJSON
+	maxImportLineSize = 1 << 20
+)
+
+// ImportReport is report of import of companies
+type ImportReport struct {
+	DryRun  bool          `json:"dry_run"`
+	Rows    int           `json:"rows"`
+	Valid   int           `json:"valid"`
+	Invalid int           `json:"invalid"`
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Errors  []ImportError `json:"errors"`
+}
+
+// ImportError is error of row of import
+type ImportError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// WriteCSV writes errors of report as CSV
+func (r ImportReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"row", "error"})
+	if err != nil {
+		return err
+	}
+	for _, e := range r.Errors {
+		err = cw.Write([]string{strconv.Itoa(e.Row), e.Error})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// rowError is error of single row, reading continues after it
+type rowError struct {
+	err error
+}
+
+func (e rowError) Error() string {
+	return e.err.Error()
+}
+
+// CompanyReader stream-parses companies from CSV or NDJSON and validates them as REST API does.
+// Invalid rows are skipped and recorded in report.
+type CompanyReader struct {
+	next    func() (company, int, error)
+	current domain.Company
+	err     error
+	report  ImportReport
+}
+
+// NewCompanyReader creates reader of companies in format csv or ndjson. CSV must have header
+// with names of columns equal to JSON fields of company, so that export can be imported back.
+func NewCompanyReader(r io.Reader, format string) (*CompanyReader, error) {
+	var next func() (company, int, error)
+	var err error
+	switch format {
+	case ImportFormatCSV:
+		next, err = csvCompanies(r)
+	case ImportFormatNDJSON:
+		next = ndjsonCompanies(r)
+	default:
+		err = fmt.Errorf("unknown import format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &CompanyReader{next: next, report: ImportReport{Errors: make([]ImportError, 0)}}, nil
+}
+
+// Next reads next valid company, it returns false at the end of input or on error
+func (r *CompanyReader) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	for {
+		cmp, row, err := r.next()
+		if errors.Is(err, io.EOF) {
+			return false
+		}
+		var rowErr rowError
+		if err != nil && !errors.As(err, &rowErr) {
+			r.err = err
+			return false
+		}
+		r.report.Rows++
+		if err == nil {
+			err = validate.Struct(cmp)
+		}
+		if err != nil {
+			r.report.Invalid++
+			if len(r.report.Errors) < maxImportErrors {
+				r.report.Errors = append(r.report.Errors, ImportError{Row: row, Error: err.Error()})
+			}
+			continue
+		}
+		r.report.Valid++
+		r.current = companyToDomain(cmp)
+		return true
+	}
+}
+
+// Company returns company read by Next
+func (r *CompanyReader) Company() domain.Company {
+	return r.current
+}
+
+// Err returns error that stopped reading
+func (r *CompanyReader) Err() error {
+	return r.err
+}
+
+// Report returns report of rows read so far
+func (r *CompanyReader) Report() ImportReport {
+	return r.report
+}
+
+// csvCompanies reads header and returns function reading companies row by row
+func csvCompanies(r io.Reader) (func() (company, int, error), error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("can not read csv header: %w", err)
+	}
+	columns := make([]string, len(header))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		switch h {
+		case "name", "description", "amount_of_employees", "registered", "type":
+		case "id", "deleted_at":
+			// columns of export are ignored, companies are matched by name
+		default:
+			return nil, fmt.Errorf("unknown csv column %q", h)
+		}
+		columns[i] = h
+	}
+	cr.FieldsPerRecord = len(columns)
+
+	return func() (company, int, error) {
+		record, err := cr.Read()
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return company{}, parseErr.StartLine, rowError{err: err}
+			}
+			return company{}, 0, err
+		}
+		row, _ := cr.FieldPos(0)
+		var cmp company
+		for i, value := range record {
+			switch columns[i] {
+			case "name":
+				cmp.Name = value
+			case "description":
+				cmp.Description = value
+			case "amount_of_employees":
+				cmp.AmountOfEmployees, err = strconv.Atoi(strings.TrimSpace(value))
+			case "registered":
+				cmp.Registered, err = strconv.ParseBool(strings.TrimSpace(value))
+			case "type":
+				cmp.Type = value
+			}
+			if err != nil {
+				return company{}, row, rowError{err: fmt.Errorf("can not parse %s: %w", columns[i], err)}
+			}
+		}
+		return cmp, row, nil
+	}, nil
+}
+
+// ndjsonCompanies returns function reading companies line by line, empty lines are skipped
+func ndjsonCompanies(r io.Reader) func() (company, int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+	line := 0
+	return func() (company, int, error) {
+		for scanner.Scan() {
+			line++
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+			var cmp company
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&cmp); err != nil {
+				return company{}, line, rowError{err: err}
+			}
+			return cmp, line, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return company{}, line, err
+		}
+		return company{}, line, io.EOF
+	}
+}
+
+// importFormat detects format of import by query param format or by content type
+func importFormat(c *gin.Context) string {
+	if format := c.Query("format"); format != "" {
+		return format
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return ImportFormatCSV
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return ImportFormatNDJSON
+	}
+	return mediaType
+}
+
+// ImportCompanies imports companies from CSV or NDJSON body, companies are upserted by name.
+// With query param dry_run=true rows are only validated. Report is returned as JSON or, with
+// query param report=csv, as downloadable CSV of row errors.
+func (s *Server) ImportCompanies(c *gin.Context) {
+	reader, err := NewCompanyReader(c.Request.Body, importFormat(c))
+	if err != nil {
+		s.logger(c).Error("can not read import", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	dryRun := c.Query("dry_run") == "true"
+	result, err := s.companies.Import(c.Request.Context(), reader, dryRun)
+	if err != nil {
+		s.logger(c).Error("can not import companies", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	report := reader.Report()
+	report.DryRun = dryRun
+	report.Created = result.Created
+	report.Updated = result.Updated
+
+	if c.Query("report") == ImportFormatCSV {
+		c.Header("Content-Disposition", `attachment; filename="import-errors.csv"`)
+		c.Header("Content-Type", "text/csv")
+		c.Status(http.StatusOK)
+		err = report.WriteCSV(c.Writer)
+		if err != nil {
+			s.logger(c).Error("can not write import report", zap.Error(err))
+		}
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,257 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type job struct {
+	ID          uuid.UUID         `json:"id"`
+	Type        string            `json:"type"`
+	Status      string            `json:"status"`
+	Params      map[string]string `json:"params"`
+	Progress    int               `json:"progress"`
+	Attempts    int               `json:"attempts"`
+	MaxAttempts int               `json:"max_attempts"`
+	Error       string            `json:"error,omitempty"`
+	ResultURL   string            `json:"result_url,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	FinishedAt  *time.Time        `json:"finished_at,omitempty"`
+}
+
+func domainToJob(j domain.Job) job {
+	dto := job{
+		ID:          j.ID,
+		Type:        string(j.Type),
+		Status:      string(j.Status),
+		Params:      j.Params,
+		Progress:    j.Progress,
+		Attempts:    j.Attempts,
+		MaxAttempts: j.MaxAttempts,
+		Error:       j.Error,
+		CreatedAt:   j.CreatedAt,
+		UpdatedAt:   j.UpdatedAt,
+		FinishedAt:  j.FinishedAt,
+	}
+	if j.Status == domain.JobSucceeded {
+		dto.ResultURL = fmt.Sprintf("/jobs/%s/result", j.ID)
+	}
+	return dto
+}
+
+func jobErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrJobFinished), errors.Is(err, domain.ErrJobNoResult):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// CreateJob queues import or export job. Parameters are the same as of synchronous endpoints:
+// POST /jobs?type=import&format=csv&dry_run=true with file in body or
+// POST /jobs?type=export&format=xlsx&include_deleted=true
+func (s *Server) CreateJob(c *gin.Context) {
+	newJob := domain.Job{
+		Type:   domain.JobType(c.Query("type")),
+		UserID: currentUser(c).ID,
+		Params: make(map[string]string),
+	}
+	var input io.Reader
+	switch newJob.Type {
+	case domain.JobImport:
+		format := importFormat(c)
+		if format != ImportFormatCSV && format != ImportFormatNDJSON {
+			c.JSON(http.StatusBadRequest, errorBody(c, fmt.Sprintf("unknown import format %q", format)))
+			return
+		}
+		newJob.Params["format"] = format
+		newJob.Params["dry_run"] = strconv.FormatBool(c.Query("dry_run") == "true")
+		input = c.Request.Body
+	case domain.JobExport:
+		filter, err := s.companyFilter(c, 0)
+		if err != nil {
+			s.respondError(c, http.StatusForbidden, err)
+			return
+		}
+		format := c.DefaultQuery("format", ExportFormatCSV)
+		if format != ExportFormatCSV && format != ExportFormatNDJSON && format != ExportFormatXLSX {
+			c.JSON(http.StatusBadRequest, errorBody(c, fmt.Sprintf("unknown export format %q", format)))
+			return
+		}
+		filter.Limit, err = exportLimit(filter.Limit, format)
+		if err != nil {
+			s.respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		newJob.Params["format"] = format
+		newJob.Params["limit"] = strconv.Itoa(filter.Limit)
+		newJob.Params["offset"] = strconv.Itoa(filter.Offset)
+		newJob.Params["include_deleted"] = strconv.FormatBool(filter.IncludeDeleted)
+	}
+	created, err := s.jobs.Create(c.Request.Context(), newJob, input)
+	if err != nil {
+		s.logger(c).Error("can not create job", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	c.Header("Location", fmt.Sprintf("/jobs/%s", created.ID))
+	c.JSON(http.StatusAccepted, domainToJob(created))
+}
+
+// GetJob gets status and progress of job by id from path param
+func (s *Server) GetJob(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		s.logger(c).Error("can not parse id", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	j, err := s.jobs.Get(c.Request.Context(), id)
+	if err != nil {
+		s.logger(c).Error("can not get job", zap.Error(err))
+		s.respondError(c, jobErrorStatus(err), err)
+		return
+	}
+	c.JSON(http.StatusOK, domainToJob(j))
+}
+
+// CancelJob cancels queued or running job by id from path param
+func (s *Server) CancelJob(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		s.logger(c).Error("can not parse id", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	j, err := s.jobs.Cancel(c.Request.Context(), id)
+	if err != nil {
+		s.logger(c).Error("can not cancel job", zap.Error(err))
+		s.respondError(c, jobErrorStatus(err), err)
+		return
+	}
+	c.JSON(http.StatusOK, domainToJob(j))
+}
+
+// JobResult downloads result of succeeded job: report of import or exported file
+func (s *Server) JobResult(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		s.logger(c).Error("can not parse id", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	j, err := s.jobs.Get(c.Request.Context(), id)
+	if err == nil && (j.Status != domain.JobSucceeded || j.Result == "") {
+		err = domain.ErrJobNoResult
+	}
+	if err != nil {
+		s.logger(c).Error("can not get job result", zap.Error(err))
+		s.respondError(c, jobErrorStatus(err), err)
+		return
+	}
+	filename := "import-report.json"
+	if j.Type == domain.JobExport {
+		filename = "companies." + j.Params["format"]
+	}
+	s.clearWriteDeadline(c)
+	c.FileAttachment(j.Result, filename)
+}
+
+// ImportJob returns handler of import jobs, report of import is written to result of job
+func ImportJob(companies CompaniesUsecase) func(ctx context.Context, job domain.Job, progress func(done int)) error {
+	return func(ctx context.Context, job domain.Job, progress func(done int)) error {
+		in, err := os.Open(job.Input)
+		if err != nil {
+			return fmt.Errorf("can not open input: %w", err)
+		}
+		defer in.Close()
+		reader, err := NewCompanyReader(in, job.Params["format"])
+		if err != nil {
+			return err
+		}
+		dryRun := job.Params["dry_run"] == "true"
+		result, err := companies.Import(ctx, progressReader{CompanyReader: reader, progress: progress}, dryRun)
+		if err != nil {
+			return err
+		}
+		report := reader.Report()
+		report.DryRun = dryRun
+		report.Created = result.Created
+		report.Updated = result.Updated
+		return writeResult(job.Result, func(w io.Writer) error {
+			return json.NewEncoder(w).Encode(report)
+		})
+	}
+}
+
+// ExportJob returns handler of export jobs, exported file is written to result of job
+func ExportJob(companies CompaniesUsecase) func(ctx context.Context, job domain.Job, progress func(done int)) error {
+	return func(ctx context.Context, job domain.Job, progress func(done int)) error {
+		limit, _ := strconv.Atoi(job.Params["limit"])
+		offset, _ := strconv.Atoi(job.Params["offset"])
+		filter := domain.CompanyFilter{
+			Limit:          limit,
+			Offset:         offset,
+			IncludeDeleted: job.Params["include_deleted"] == "true",
+		}
+		return writeResult(job.Result, func(w io.Writer) error {
+			enc, _, err := newCompanyEncoder(w, job.Params["format"])
+			if err != nil {
+				return err
+			}
+			done := 0
+			err = companies.Export(ctx, filter, func(cmp domain.Company) error {
+				done++
+				progress(done)
+				return enc.Encode(domainToCompany(cmp))
+			})
+			if err != nil {
+				return err
+			}
+			return enc.Close()
+		})
+	}
+}
+
+// progressReader reports number of read rows on every row
+type progressReader struct {
+	*CompanyReader
+	progress func(done int)
+}
+
+func (r progressReader) Next() bool {
+	ok := r.CompanyReader.Next()
+	r.progress(r.Report().Rows)
+	return ok
+}
+
+// writeResult writes result file with write, file is removed if write fails
+func writeResult(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("can not create result: %w", err)
+	}
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+	}
+	return err
+}
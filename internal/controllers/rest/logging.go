@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/logging"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// probeRoutes are polled by orchestrator and scraper, they are logged at debug level
+var probeRoutes = map[string]bool{
+	"/healtz":  true,
+	"/livez":   true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// logger returns logger of request with its id, route, trace and user
+func (s *Server) logger(c *gin.Context) *zap.Logger {
+	return logging.FromContext(c.Request.Context())
+}
+
+// errorBody is body of failed response, id of request is included so that client can report it
+func errorBody(c *gin.Context, msg string) gin.H {
+	return gin.H{"error": msg, "request_id": domain.RequestIDFromContext(c.Request.Context())}
+}
+
+// respondError writes error with status. Storage being unavailable is not fault of request, so that
+// domain.ErrUnavailable is answered with 503 and Retry-After instead of status.
+func (s *Server) respondError(c *gin.Context, status int, err error) {
+	if errors.Is(err, domain.ErrUnavailable) {
+		c.Header("Retry-After", ceilSeconds(s.retryAfter))
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, errorBody(c, err.Error()))
+}
+
+// AccessLog is middleware to log every request once it is served
+func (s *Server) AccessLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := zapcore.InfoLevel
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = zapcore.ErrorLevel
+		case status >= http.StatusBadRequest:
+			level = zapcore.WarnLevel
+		case probeRoutes[c.FullPath()]:
+			level = zapcore.DebugLevel
+		}
+		if ce := s.logger(c).Check(level, "request served"); ce != nil {
+			ce.Write(
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.Request.URL.Path),
+				zap.Int("status", status),
+				zap.Duration("duration", time.Since(start)),
+				zap.Int("size", c.Writer.Size()),
+				zap.String("client_ip", c.ClientIP()),
+				zap.String("user_agent", c.Request.UserAgent()),
+			)
+		}
+	}
+}
+
+// Recovery is middleware to respond with 500 on panic of handler and log it with stack
+func (s *Server) Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, recovered any) {
+		s.logger(c).Error("handler panicked", zap.Any("panic", recovered), zap.Stack("stack"))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, errorBody(c, http.StatusText(http.StatusInternalServerError)))
+	})
+}
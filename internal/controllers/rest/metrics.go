@@ -0,0 +1,124 @@
+package rest
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// sign in methods
+const (
+	signInPassword = "password"
+	signInOIDC     = "oidc"
+)
+
+// unmatchedRoute is route label of requests which match no route, so that unknown paths
+// do not create new series
+const unmatchedRoute = "unmatched"
+
+// httpMetrics are metrics of server
+type httpMetrics struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	signIns  *prometheus.CounterVec
+	limited  *prometheus.CounterVec
+}
+
+func newHTTPMetrics(registry *prometheus.Registry) *httpMetrics {
+	m := &httpMetrics{
+		registry: registry,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of HTTP requests by method and route.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		signIns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "auth_sign_ins_total",
+			Help: "Number of sign in attempts by method and result.",
+		}, []string{"method", "result"}),
+		limited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_rate_limited_total",
+			Help: "Number of HTTP requests rejected by rate limit by method and route.",
+		}, []string{"method", "route"}),
+	}
+	registry.MustRegister(m.requests, m.duration, m.signIns, m.limited)
+	return m
+}
+
+// Metrics is middleware to count requests and measure their latency by route
+func (s *Server) Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Request.Method
+		s.metrics.requests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		s.metrics.duration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// MetricsAuth is middleware to require bearer token of scraper if it is configured
+func (s *Server) MetricsAuth() gin.HandlerFunc {
+	want := []byte("Bearer " + s.metricsToken)
+	return func(c *gin.Context) {
+		if s.metricsToken != "" && subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), want) != 1 {
+			c.JSON(http.StatusUnauthorized, errorBody(c, "metrics require bearer token"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// newMetricsServer creates server of /metrics on separate listener
+func (s *Server) newMetricsServer() *http.Server {
+	e := gin.New()
+	e.Use(s.RequestID(), s.Recovery())
+	e.GET("/metrics", s.MetricsAuth(), s.MetricsHandler())
+	return &http.Server{
+		Addr:              s.metricsAddr,
+		Handler:           e,
+		ReadHeaderTimeout: s.timeouts.ReadHeader,
+		ReadTimeout:       s.timeouts.Read,
+		WriteTimeout:      s.timeouts.Write,
+		IdleTimeout:       s.timeouts.Idle,
+	}
+}
+
+// MetricsHandler serves metrics in Prometheus format
+func (s *Server) MetricsHandler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(s.metrics.registry, promhttp.HandlerOpts{}))
+}
+
+// countSignIn counts sign in attempt if metrics are enabled
+func (s *Server) countSignIn(method string, err error) {
+	if s.metrics == nil {
+		return
+	}
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	s.metrics.signIns.WithLabelValues(method, result).Inc()
+}
+
+// countRateLimited counts request rejected by rate limit if metrics are enabled
+func (s *Server) countRateLimited(c *gin.Context) {
+	if s.metrics == nil {
+		return
+	}
+	s.metrics.limited.WithLabelValues(c.Request.Method, c.FullPath()).Inc()
+}
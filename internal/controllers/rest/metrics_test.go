@@ -0,0 +1,55 @@
+package rest_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Ragnar-BY/companies-handler/internal/controllers/rest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsAccess(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    string
+		token   string
+		headers map[string]string
+		status  int
+	}{
+		{
+			name:   "open",
+			status: http.StatusOK,
+		},
+		{
+			name:    "token",
+			token:   "secret",
+			headers: map[string]string{"Authorization": "Bearer secret"},
+			status:  http.StatusOK,
+		},
+		{
+			name:   "missing token",
+			token:  "secret",
+			status: http.StatusUnauthorized,
+		},
+		{
+			name:    "wrong token",
+			token:   "secret",
+			headers: map[string]string{"Authorization": "Bearer other"},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:   "separate address",
+			addr:   "127.0.0.1:0",
+			status: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestServer(t, stubCompanies{},
+				rest.WithMetrics(prometheus.NewRegistry()), rest.WithMetricsAccess(tt.addr, tt.token))
+			rec := serve(h, http.MethodGet, "/metrics", "", tt.headers)
+			require.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
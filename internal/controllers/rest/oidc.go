@@ -0,0 +1,47 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// OIDCLogin redirects user to identity provider login page
+func (s *Server) OIDCLogin(c *gin.Context) {
+	url, err := s.oidc.LoginURL()
+	if err != nil {
+		s.logger(c).Error("can not start oidc login", zap.Error(err))
+		status := http.StatusInternalServerError
+		if errors.Is(err, domain.ErrOIDCTooManyLogins) {
+			status = http.StatusTooManyRequests
+		}
+		s.respondError(c, status, err)
+		return
+	}
+	c.Redirect(http.StatusFound, url)
+}
+
+// OIDCCallback finishes login with identity provider and returns token
+func (s *Server) OIDCCallback(c *gin.Context) {
+	if providerErr := c.Query("error"); providerErr != "" {
+		s.logger(c).Error("oidc provider returned error", zap.String("error", providerErr))
+		s.countSignIn(signInOIDC, errors.New(providerErr))
+		c.JSON(http.StatusUnauthorized, errorBody(c, providerErr))
+		return
+	}
+	token, err := s.oidc.Callback(c.Request.Context(), c.Query("code"), c.Query("state"))
+	s.countSignIn(signInOIDC, err)
+	if err != nil {
+		s.logger(c).Error("can not sign in with oidc", zap.Error(err))
+		status := http.StatusUnauthorized
+		if errors.Is(err, domain.ErrOIDCEmailTaken) || errors.Is(err, domain.ErrUserExists) {
+			status = http.StatusConflict
+		}
+		s.respondError(c, status, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"token": token})
+}
@@ -0,0 +1,142 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type organization struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name" validate:"required,min=2,max=50"`
+}
+
+type organizationMember struct {
+	UserID int64 `json:"user_id" validate:"required"`
+}
+
+// parseOrganizationID parses organization id from path param
+func (s *Server) parseOrganizationID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		s.logger(c).Error("can not parse organization id", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return 0, false
+	}
+	return id, true
+}
+
+// ListOrganizations selects organizations available to current user
+func (s *Server) ListOrganizations(c *gin.Context) {
+	orgs, err := s.orgs.List(c.Request.Context(), currentUser(c))
+	if err != nil {
+		s.logger(c).Error("can not select organizations", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	result := make([]organization, 0, len(orgs))
+	for _, o := range orgs {
+		result = append(result, organization{ID: o.ID, Name: o.Name})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// SwitchOrganization returns token of current user scoped to organization from path param
+func (s *Server) SwitchOrganization(c *gin.Context) {
+	id, ok := s.parseOrganizationID(c)
+	if !ok {
+		return
+	}
+	token, err := s.orgs.Switch(c.Request.Context(), currentUser(c), id)
+	if err != nil {
+		s.logger(c).Error("can not switch organization", zap.Error(err))
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrNotOrganizationMember) {
+			status = http.StatusForbidden
+		}
+		s.respondError(c, status, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"token": token})
+}
+
+// CreateOrganization creates new organization
+func (s *Server) CreateOrganization(c *gin.Context) {
+	var org organization
+	err := c.ShouldBind(&org)
+	if err != nil {
+		s.logger(c).Error("can not bind organization", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	err = validate.Struct(org)
+	if err != nil {
+		s.logger(c).Error("can not validate organization", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	created, err := s.orgs.Create(c.Request.Context(), org.Name)
+	if err != nil {
+		s.logger(c).Error("can not create organization", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusCreated, organization{ID: created.ID, Name: created.Name})
+}
+
+// AddOrganizationMember adds user to organization
+func (s *Server) AddOrganizationMember(c *gin.Context) {
+	id, ok := s.parseOrganizationID(c)
+	if !ok {
+		return
+	}
+	var m organizationMember
+	err := c.ShouldBind(&m)
+	if err != nil {
+		s.logger(c).Error("can not bind organization member", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	err = validate.Struct(m)
+	if err != nil {
+		s.logger(c).Error("can not validate organization member", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	err = s.orgs.AddMember(c.Request.Context(), id, m.UserID)
+	if err != nil {
+		s.logger(c).Error("can not add organization member", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	c.JSON(http.StatusCreated, "")
+}
+
+// RemoveOrganizationMember removes user from organization
+func (s *Server) RemoveOrganizationMember(c *gin.Context) {
+	id, ok := s.parseOrganizationID(c)
+	if !ok {
+		return
+	}
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		s.logger(c).Error("can not parse user id", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	err = s.orgs.RemoveMember(c.Request.Context(), id, userID)
+	if err != nil {
+		s.logger(c).Error("can not remove organization member", zap.Error(err))
+		status := http.StatusBadRequest
+		if errors.Is(err, domain.ErrNotOrganizationMember) {
+			status = http.StatusNotFound
+		}
+		s.respondError(c, status, err)
+		return
+	}
+	c.JSON(http.StatusOK, "")
+}
@@ -0,0 +1,180 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type profile struct {
+	ID           int64  `json:"id"`
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	Role         string `json:"role"`
+	PendingEmail string `json:"pending_email,omitempty"`
+}
+
+type profileUpdate struct {
+	Username string `json:"username" validate:"omitempty,min=4,max=15"`
+	Email    string `json:"email" validate:"omitempty,email,max=254"`
+}
+
+type passwordChange struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8"`
+}
+
+type emailVerification struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type passwordReset struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8"`
+}
+
+func domainToProfile(u domain.User) profile {
+	return profile{
+		ID:           u.ID,
+		Username:     u.Username,
+		Email:        u.Email,
+		Role:         string(u.Role),
+		PendingEmail: u.PendingEmail,
+	}
+}
+
+// userErrorStatus returns http status for user usecase error
+func userErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrSelfAdminAction), errors.Is(err, domain.ErrInvalidPassword):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrUserExists):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrEmailVerificationFail), errors.Is(err, domain.ErrPasswordResetFail):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// GetProfile gets profile of current user
+func (s *Server) GetProfile(c *gin.Context) {
+	user, err := s.users.Profile(c.Request.Context(), currentUser(c).ID)
+	if err != nil {
+		s.logger(c).Error("can not get profile", zap.Error(err))
+		s.respondError(c, userErrorStatus(err), err)
+		return
+	}
+	c.JSON(http.StatusOK, domainToProfile(*user))
+}
+
+// UpdateProfile changes username and email of current user
+func (s *Server) UpdateProfile(c *gin.Context) {
+	var upd profileUpdate
+	err := c.ShouldBind(&upd)
+	if err != nil {
+		s.logger(c).Error("can not bind profile", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	err = validate.Struct(upd)
+	if err != nil {
+		s.logger(c).Error("can not validate profile", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	user, err := s.users.UpdateProfile(c.Request.Context(), currentUser(c).ID, upd.Username, upd.Email)
+	if err != nil {
+		s.logger(c).Error("can not update profile", zap.Error(err))
+		s.respondError(c, userErrorStatus(err), err)
+		return
+	}
+	c.JSON(http.StatusOK, domainToProfile(*user))
+}
+
+// VerifyEmail applies pending email of current user
+func (s *Server) VerifyEmail(c *gin.Context) {
+	var v emailVerification
+	err := c.ShouldBind(&v)
+	if err != nil {
+		s.logger(c).Error("can not bind email verification", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	err = validate.Struct(v)
+	if err != nil {
+		s.logger(c).Error("can not validate email verification", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	user, err := s.users.VerifyEmail(c.Request.Context(), currentUser(c).ID, v.Token)
+	if err != nil {
+		s.logger(c).Error("can not verify email", zap.Error(err))
+		s.respondError(c, userErrorStatus(err), err)
+		return
+	}
+	c.JSON(http.StatusOK, domainToProfile(*user))
+}
+
+// ChangePassword changes password of current user
+func (s *Server) ChangePassword(c *gin.Context) {
+	var p passwordChange
+	err := c.ShouldBind(&p)
+	if err != nil {
+		s.logger(c).Error("can not bind password change", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	err = validate.Struct(p)
+	if err != nil {
+		s.logger(c).Error("can not validate password change", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	err = s.users.ChangePassword(c.Request.Context(), currentUser(c).ID, p.CurrentPassword, p.NewPassword)
+	if err != nil {
+		s.logger(c).Error("can not change password", zap.Error(err))
+		s.respondError(c, userErrorStatus(err), err)
+		return
+	}
+	c.JSON(http.StatusOK, "")
+}
+
+// ResetPassword sets new password with token sent on forced password reset
+func (s *Server) ResetPassword(c *gin.Context) {
+	var p passwordReset
+	err := c.ShouldBind(&p)
+	if err != nil {
+		s.logger(c).Error("can not bind password reset", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	err = validate.Struct(p)
+	if err != nil {
+		s.logger(c).Error("can not validate password reset", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	err = s.users.ResetPassword(c.Request.Context(), p.Token, p.NewPassword)
+	if err != nil {
+		s.logger(c).Error("can not reset password", zap.Error(err))
+		s.respondError(c, userErrorStatus(err), err)
+		return
+	}
+	c.JSON(http.StatusOK, "")
+}
+
+// DeleteProfile deletes account of current user
+func (s *Server) DeleteProfile(c *gin.Context) {
+	err := s.users.DeleteAccount(c.Request.Context(), currentUser(c).ID)
+	if err != nil {
+		s.logger(c).Error("can not delete account", zap.Error(err))
+		s.respondError(c, userErrorStatus(err), err)
+		return
+	}
+	c.JSON(http.StatusOK, "")
+}
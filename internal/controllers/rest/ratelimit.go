@@ -0,0 +1,156 @@
+package rest
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// Keys requests are counted by
+const (
+	RateLimitByIP   = "ip"
+	RateLimitByUser = "user"
+)
+
+// anyRoute matches any method or route in rate limit policy
+const anyRoute = "*"
+
+// RateLimitPolicy limits rate of requests to route per client
+type RateLimitPolicy struct {
+	// Method and Route select requests, Route is route pattern, e.g. /companies/:id. * matches any method or route.
+	Method string
+	Route  string
+	// Key is what requests are counted by: ip or user. Anonymous requests are counted by ip, so that
+	// unverified headers of client can not select its bucket.
+	Key   string
+	Limit domain.RateLimit
+}
+
+// ParseRateLimitPolicy parses policy "<method> <route> <requests>/<period> <key> [burst=<n>]",
+// e.g. "GET /companies 100/1m ip" or "* * 600/1m user burst=100"
+func ParseRateLimitPolicy(s string) (RateLimitPolicy, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 4 && len(fields) != 5 {
+		return RateLimitPolicy{}, fmt.Errorf("rate limit %q is not <method> <route> <requests>/<period> <key> [burst=<n>]", s)
+	}
+	policy := RateLimitPolicy{Method: strings.ToUpper(fields[0]), Route: fields[1], Key: fields[3]}
+
+	requests, period, ok := strings.Cut(fields[2], "/")
+	if !ok {
+		return RateLimitPolicy{}, fmt.Errorf("rate %q of rate limit %q is not <requests>/<period>", fields[2], s)
+	}
+	var err error
+	if policy.Limit.Requests, err = strconv.Atoi(requests); err != nil || policy.Limit.Requests <= 0 {
+		return RateLimitPolicy{}, fmt.Errorf("requests %q of rate limit %q must be positive number", requests, s)
+	}
+	if policy.Limit.Period, err = time.ParseDuration(period); err != nil || policy.Limit.Period <= 0 {
+		return RateLimitPolicy{}, fmt.Errorf("period %q of rate limit %q must be positive duration", period, s)
+	}
+	switch policy.Key {
+	case RateLimitByIP, RateLimitByUser:
+	default:
+		return RateLimitPolicy{}, fmt.Errorf("key %q of rate limit %q must be ip or user", policy.Key, s)
+	}
+	if len(fields) == 5 {
+		burst, ok := strings.CutPrefix(fields[4], "burst=")
+		if policy.Limit.Burst, err = strconv.Atoi(burst); !ok || err != nil || policy.Limit.Burst <= 0 {
+			return RateLimitPolicy{}, fmt.Errorf("burst %q of rate limit %q must be burst=<positive number>", fields[4], s)
+		}
+	}
+	return policy, nil
+}
+
+// ParseRateLimitPolicies parses policies, empty ones are skipped
+func ParseRateLimitPolicies(policies []string) ([]RateLimitPolicy, error) {
+	parsed := make([]RateLimitPolicy, 0, len(policies))
+	for _, s := range policies {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		policy, err := ParseRateLimitPolicy(s)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, policy)
+	}
+	return parsed, nil
+}
+
+// matches checks if policy applies to request
+func (p RateLimitPolicy) matches(c *gin.Context) bool {
+	return (p.Method == anyRoute || p.Method == c.Request.Method) && (p.Route == anyRoute || p.Route == c.FullPath())
+}
+
+// bucket returns key of bucket of client of request, user is authenticated by token
+func (p RateLimitPolicy) bucket(c *gin.Context) string {
+	client := "ip:" + c.ClientIP()
+	if p.Key == RateLimitByUser {
+		if user, ok := optionalUser(c); ok {
+			client = "user:" + strconv.FormatInt(user.ID, 10)
+		}
+	}
+	return p.Method + " " + p.Route + " " + client
+}
+
+// SetRateLimitPolicies replaces rate limit policies, e.g. when config is reloaded
+func (s *Server) SetRateLimitPolicies(policies []RateLimitPolicy) {
+	s.rateLimitPolicies.Store(&policies)
+}
+
+// rateLimitPolicy returns first policy matching request
+func (s *Server) rateLimitPolicy(c *gin.Context) (RateLimitPolicy, bool) {
+	policies := s.rateLimitPolicies.Load()
+	if policies == nil {
+		return RateLimitPolicy{}, false
+	}
+	for _, policy := range *policies {
+		if policy.matches(c) {
+			return policy, true
+		}
+	}
+	return RateLimitPolicy{}, false
+}
+
+// RateLimit is middleware to limit rate of requests by first policy matching request, it must be used
+// after Auth to count requests by user. Requests over limit fail with 429.
+func (s *Server) RateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy, ok := s.rateLimitPolicy(c)
+		if !ok {
+			c.Next()
+			return
+		}
+		result := s.rateLimits.Allow(c.Request.Context(), policy.bucket(c), policy.Limit)
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", ceilSeconds(result.Reset))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%s;burst=%d",
+			policy.Limit.Requests, ceilSeconds(policy.Limit.Period), policy.Limit.Capacity()))
+		if !result.Allowed {
+			s.countRateLimited(c)
+			c.Header("Retry-After", ceilSeconds(result.RetryAfter))
+			c.JSON(http.StatusTooManyRequests, errorBody(c, "rate limit exceeded"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// rateLimited returns middlewares followed by RateLimit if rate limits are enabled
+func (s *Server) rateLimited(middlewares ...gin.HandlerFunc) []gin.HandlerFunc {
+	if s.rateLimits != nil {
+		middlewares = append(middlewares, s.RateLimit())
+	}
+	return middlewares
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,185 @@
+package rest_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/controllers/rest"
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/ratelimit"
+	"github.com/Ragnar-BY/companies-handler/internal/service"
+	"github.com/Ragnar-BY/companies-handler/internal/usecase"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingRateLimits records keys of buckets requests are counted in
+type recordingRateLimits struct {
+	next rest.RateLimitUsecase
+
+	mu   sync.Mutex
+	keys []string
+}
+
+func newRecordingRateLimits() *recordingRateLimits {
+	return &recordingRateLimits{
+		next: usecase.NewRateLimitUsecase(service.NewRateLimitService(ratelimit.NewMemory())),
+	}
+}
+
+func (r *recordingRateLimits) Allow(ctx context.Context, key string, limit domain.RateLimit) domain.RateLimitResult {
+	r.mu.Lock()
+	r.keys = append(r.keys, key)
+	r.mu.Unlock()
+	return r.next.Allow(ctx, key, limit)
+}
+
+func TestParseRateLimitPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		want   rest.RateLimitPolicy
+		err    string
+	}{
+		{
+			name:   "ip",
+			policy: "get /companies 100/1m ip",
+			want: rest.RateLimitPolicy{Method: http.MethodGet, Route: "/companies", Key: rest.RateLimitByIP,
+				Limit: domain.RateLimit{Requests: 100, Period: time.Minute}},
+		},
+		{
+			name:   "user with burst",
+			policy: "* * 600/1m user burst=100",
+			want: rest.RateLimitPolicy{Method: "*", Route: "*", Key: rest.RateLimitByUser,
+				Limit: domain.RateLimit{Requests: 600, Period: time.Minute, Burst: 100}},
+		},
+		{
+			name:   "missing key",
+			policy: "GET /companies 100/1m",
+			err:    `rate limit "GET /companies 100/1m" is not <method> <route> <requests>/<period> <key> [burst=<n>]`,
+		},
+		{
+			name:   "bad rate",
+			policy: "GET /companies 100 ip",
+			err:    `rate "100" of rate limit "GET /companies 100 ip" is not <requests>/<period>`,
+		},
+		{
+			name:   "bad requests",
+			policy: "GET /companies 0/1m ip",
+			err:    `requests "0" of rate limit "GET /companies 0/1m ip" must be positive number`,
+		},
+		{
+			name:   "bad period",
+			policy: "GET /companies 10/minute ip",
+			err:    `period "minute" of rate limit "GET /companies 10/minute ip" must be positive duration`,
+		},
+		{
+			// keys of unverified headers would let client pick its bucket
+			name:   "api key",
+			policy: "GET /companies 10/1m api_key",
+			err:    `key "api_key" of rate limit "GET /companies 10/1m api_key" must be ip or user`,
+		},
+		{
+			name:   "bad burst",
+			policy: "GET /companies 10/1m ip 20",
+			err:    `burst "20" of rate limit "GET /companies 10/1m ip 20" must be burst=<positive number>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := rest.ParseRateLimitPolicy(tt.policy)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, policy)
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	company := domain.Company{ID: uuid.New()}
+	policies, err := rest.ParseRateLimitPolicies([]string{"GET /companies/:id 2/1m ip"})
+	require.NoError(t, err)
+	h := newTestServer(t, stubCompanies{companies: map[int64]domain.Company{domain.DefaultOrganizationID: company}},
+		rest.WithRateLimit(newRecordingRateLimits(), policies))
+	target := "/companies/" + company.ID.String()
+
+	rec := serve(h, http.MethodGet, target, "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "30", rec.Header().Get("RateLimit-Reset"))
+	require.Equal(t, "2;w=60;burst=2", rec.Header().Get("RateLimit-Policy"))
+
+	rec = serve(h, http.MethodGet, target, "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+
+	rec = serve(h, http.MethodGet, target, "", map[string]string{"X-Request-ID": "test-request"})
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "30", rec.Header().Get("Retry-After"))
+	require.JSONEq(t, `{"error":"rate limit exceeded","request_id":"test-request"}`, rec.Body.String())
+
+	// routes without policy are not limited
+	rec = serve(h, http.MethodGet, "/companies", "", nil)
+	require.Empty(t, rec.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimitBuckets(t *testing.T) {
+	company := domain.Company{ID: uuid.New()}
+	target := "/companies/" + company.ID.String()
+	tests := []struct {
+		name    string
+		policy  string
+		headers map[string]string
+		key     string
+	}{
+		{
+			name:   "ip",
+			policy: "GET /companies/:id 10/1m ip",
+			key:    "GET /companies/:id ip:192.0.2.1",
+		},
+		{
+			name:    "ip of user",
+			policy:  "GET /companies/:id 10/1m ip",
+			headers: map[string]string{"Authorization": "Bearer " + validToken},
+			key:     "GET /companies/:id ip:192.0.2.1",
+		},
+		{
+			name:    "user",
+			policy:  "GET /companies/:id 10/1m user",
+			headers: map[string]string{"Authorization": "Bearer " + validToken},
+			key:     "GET /companies/:id user:1",
+		},
+		{
+			name:   "anonymous user",
+			policy: "GET /companies/:id 10/1m user",
+			key:    "GET /companies/:id ip:192.0.2.1",
+		},
+		{
+			// client can not leave its bucket by sending headers which are not verified
+			name:    "unverified headers",
+			policy:  "* * 10/1m user",
+			headers: map[string]string{"X-API-Key": "random", "X-Forwarded-For": "203.0.113.1"},
+			key:     "* * ip:192.0.2.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies, err := rest.ParseRateLimitPolicies([]string{tt.policy})
+			require.NoError(t, err)
+			limits := newRecordingRateLimits()
+			h := newTestServer(t, stubCompanies{companies: map[int64]domain.Company{domain.DefaultOrganizationID: company}},
+				rest.WithRateLimit(limits, policies))
+
+			rec := serve(h, http.MethodGet, target, "", tt.headers)
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Equal(t, []string{tt.key}, limits.keys)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package rest
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// uploadMediaTypes are media types of imported files, POST bodies of these types are limited
+// by upload limit instead of body limit
+var uploadMediaTypes = map[string]bool{
+	"text/csv":             true,
+	"application/x-ndjson": true,
+	"application/ndjson":   true,
+	"application/jsonl":    true,
+}
+
+// isUpload reports whether request uploads file
+func isUpload(r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return uploadMediaTypes[mediaType]
+}
+
+// SecurityHeaders is middleware to add security headers to responses. Strict-Transport-Security
+// is sent only if server serves TLS, proxies terminating TLS should add it on their own.
+func (s *Server) SecurityHeaders() gin.HandlerFunc {
+	var hsts string
+	if s.certs != nil && s.hstsMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d; includeSubDomains", int(s.hstsMaxAge.Seconds()))
+	}
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "no-referrer")
+		if s.contentSecurityPolicy != "" {
+			h.Set("Content-Security-Policy", s.contentSecurityPolicy)
+		}
+		if hsts != "" {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
+
+// BodyLimit is middleware to limit size of request bodies, requests with larger Content-Length
+// fail with 413 and larger bodies without it fail to be read. Uploads of files are recognized
+// by method and content type, so that JSON bodies of the same routes keep body limit.
+func (s *Server) BodyLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := s.maxBodySize
+		if isUpload(c.Request) {
+			limit = s.maxUploadSize
+		}
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > limit {
+			c.JSON(http.StatusRequestEntityTooLarge, errorBody(c, fmt.Sprintf("request body is larger than %d bytes", limit)))
+			c.Abort()
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// clearWriteDeadline lifts write timeout of server for streamed response, which is limited by size
+// of data rather than by time
+func (s *Server) clearWriteDeadline(c *gin.Context) {
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		s.logger(c).Debug("can not clear write deadline", zap.Error(err))
+	}
+}
@@ -0,0 +1,255 @@
+package rest_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/controllers/rest"
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+const frontendOrigin = "https://app.example.com"
+
+// writeCertificate writes self-signed certificate with common name and its key to files in dir
+func writeCertificate(t *testing.T, dir, commonName string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+// servedCommonName returns common name of certificate served by loader
+func servedCommonName(t *testing.T, certs *rest.CertificateLoader) string {
+	t.Helper()
+	cert, err := certs.GetCertificate(nil)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestCertificateLoader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCertificate(t, dir, "first")
+	certs, err := rest.NewCertificateLoader(certFile, keyFile)
+	require.NoError(t, err)
+	require.Equal(t, "first", servedCommonName(t, certs))
+
+	writeCertificate(t, dir, "renewed")
+	require.NoError(t, certs.Reload())
+	require.Equal(t, "renewed", servedCommonName(t, certs))
+
+	// broken files do not replace loaded certificate
+	require.NoError(t, os.WriteFile(certFile, []byte("broken"), 0o600))
+	require.Error(t, certs.Reload())
+	require.Equal(t, "renewed", servedCommonName(t, certs))
+
+	_, err = rest.NewCertificateLoader(certFile, keyFile)
+	require.Error(t, err)
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	certFile, keyFile := writeCertificate(t, t.TempDir(), "localhost")
+	certs, err := rest.NewCertificateLoader(certFile, keyFile)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		opts []rest.Option
+		hsts string
+	}{
+		{
+			name: "without tls",
+			opts: []rest.Option{rest.WithSecurityHeaders("default-src 'none'", time.Hour)},
+		},
+		{
+			name: "with tls",
+			opts: []rest.Option{rest.WithSecurityHeaders("default-src 'none'", time.Hour), rest.WithTLS(certs)},
+			hsts: "max-age=3600; includeSubDomains",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestServer(t, stubCompanies{}, tt.opts...)
+			rec := serve(h, http.MethodGet, "/livez", "", nil)
+			require.Equal(t, http.StatusOK, rec.Code)
+			require.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+			require.Equal(t, "DENY", rec.Header().Get("X-Frame-Options"))
+			require.Equal(t, "no-referrer", rec.Header().Get("Referrer-Policy"))
+			require.Equal(t, "default-src 'none'", rec.Header().Get("Content-Security-Policy"))
+			require.Equal(t, tt.hsts, rec.Header().Get("Strict-Transport-Security"))
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	policy := rest.CORSPolicy{
+		AllowedOrigins: []string{frontendOrigin},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		ExposedHeaders: []string{"X-Request-ID"},
+		MaxAge:         10 * time.Minute,
+	}
+	preflight := func(origin string) map[string]string {
+		return map[string]string{"Origin": origin, "Access-Control-Request-Method": "POST"}
+	}
+
+	t.Run("preflight of allowed origin", func(t *testing.T) {
+		h := newTestServer(t, stubCompanies{}, rest.WithCORS(policy))
+		rec := serve(h, http.MethodOptions, "/companies", "", preflight(frontendOrigin))
+		require.Equal(t, http.StatusNoContent, rec.Code)
+		require.Equal(t, frontendOrigin, rec.Header().Get("Access-Control-Allow-Origin"))
+		require.Equal(t, "GET, POST", rec.Header().Get("Access-Control-Allow-Methods"))
+		require.Equal(t, "Authorization, Content-Type", rec.Header().Get("Access-Control-Allow-Headers"))
+		require.Equal(t, "600", rec.Header().Get("Access-Control-Max-Age"))
+		require.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+	})
+
+	t.Run("preflight of other origin", func(t *testing.T) {
+		h := newTestServer(t, stubCompanies{}, rest.WithCORS(policy))
+		rec := serve(h, http.MethodOptions, "/companies", "", preflight("https://evil.example.com"))
+		require.Equal(t, http.StatusNoContent, rec.Code)
+		require.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+		require.Empty(t, rec.Header().Get("Access-Control-Allow-Methods"))
+	})
+
+	t.Run("request of allowed origin", func(t *testing.T) {
+		h := newTestServer(t, stubCompanies{}, rest.WithCORS(policy))
+		rec := serve(h, http.MethodGet, "/livez", "", map[string]string{"Origin": frontendOrigin})
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, frontendOrigin, rec.Header().Get("Access-Control-Allow-Origin"))
+		require.Equal(t, "X-Request-ID", rec.Header().Get("Access-Control-Expose-Headers"))
+		require.Contains(t, rec.Header().Values("Vary"), "Origin")
+	})
+
+	t.Run("request of other origin", func(t *testing.T) {
+		h := newTestServer(t, stubCompanies{}, rest.WithCORS(policy))
+		rec := serve(h, http.MethodGet, "/livez", "", map[string]string{"Origin": "https://evil.example.com"})
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("any origin", func(t *testing.T) {
+		anyOrigin := policy
+		anyOrigin.AllowedOrigins = []string{"*"}
+		h := newTestServer(t, stubCompanies{}, rest.WithCORS(anyOrigin))
+		rec := serve(h, http.MethodGet, "/livez", "", map[string]string{"Origin": frontendOrigin})
+		require.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("any origin with credentials", func(t *testing.T) {
+		credentials := policy
+		credentials.AllowedOrigins = []string{"*"}
+		credentials.AllowCredentials = true
+		h := newTestServer(t, stubCompanies{}, rest.WithCORS(credentials))
+		rec := serve(h, http.MethodGet, "/livez", "", map[string]string{"Origin": frontendOrigin})
+		require.Equal(t, frontendOrigin, rec.Header().Get("Access-Control-Allow-Origin"))
+		require.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+	})
+}
+
+// importingCompanies reads imported companies to the end
+type importingCompanies struct {
+	rest.CompaniesUsecase
+}
+
+func (importingCompanies) Import(_ context.Context, src domain.CompanySource, _ bool) (domain.ImportResult, error) {
+	for src.Next() {
+	}
+	return domain.ImportResult{}, src.Err()
+}
+
+func TestBodyLimit(t *testing.T) {
+	const (
+		bodyLimit   = 64
+		uploadLimit = 1024
+	)
+	h := newTestServer(t, importingCompanies{}, rest.WithBodyLimit(bodyLimit, uploadLimit))
+	csvBody := func(size int) string {
+		header := "name,description,amount_of_employees,registered,type\n"
+		return header + strings.Repeat("\n", size-len(header))
+	}
+
+	tests := []struct {
+		name        string
+		target      string
+		contentType string
+		body        string
+		tooLarge    bool
+	}{
+		{
+			name:        "json within body limit",
+			target:      "/companies:batch",
+			contentType: "application/json",
+			body:        `{"operations":[]}`,
+		},
+		{
+			// route of imports does not raise limit of JSON batch
+			name:        "json over body limit",
+			target:      "/companies:batch",
+			contentType: "application/json",
+			body:        `{"operations":[` + strings.Repeat(" ", bodyLimit) + `]}`,
+			tooLarge:    true,
+		},
+		{
+			name:        "upload over body limit",
+			target:      "/companies:import",
+			contentType: "text/csv",
+			body:        csvBody(bodyLimit * 2),
+		},
+		{
+			name:        "upload with parameters of content type",
+			target:      "/companies:import",
+			contentType: "text/csv; charset=utf-8",
+			body:        csvBody(bodyLimit * 2),
+		},
+		{
+			name:        "upload over upload limit",
+			target:      "/companies:import",
+			contentType: "text/csv",
+			body:        csvBody(uploadLimit + 1),
+			tooLarge:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h, http.MethodPost, tt.target, tt.body, map[string]string{
+				"Authorization": "Bearer " + validToken,
+				"Content-Type":  tt.contentType,
+			})
+			if tt.tooLarge {
+				require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code, rec.Body.String())
+			} else {
+				require.NotEqual(t, http.StatusRequestEntityTooLarge, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
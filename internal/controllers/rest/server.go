@@ -2,38 +2,117 @@ package rest
 
 import (
 	"context"
+	"crypto/tls"
+	"expvar"
+	"io"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/logging"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 type CompaniesUsecase interface {
 	Create(ctx context.Context, company domain.Company) (uuid.UUID, error)
 	Get(ctx context.Context, id uuid.UUID) (domain.Company, error)
-	Select(ctx context.Context, limit, offset int) ([]domain.Company, error)
+	Select(ctx context.Context, filter domain.CompanyFilter) ([]domain.Company, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	Restore(ctx context.Context, id uuid.UUID) error
 	Update(ctx context.Context, uuid uuid.UUID, company domain.Company) error
+	GetAsOf(ctx context.Context, id uuid.UUID, asOf time.Time) (domain.Company, error)
+	History(ctx context.Context, id uuid.UUID) ([]domain.CompanyChange, error)
+	Batch(ctx context.Context, ops []domain.CompanyOperation, atomic bool) []domain.CompanyOperationResult
+	Import(ctx context.Context, src domain.CompanySource, dryRun bool) (domain.ImportResult, error)
+	Export(ctx context.Context, filter domain.CompanyFilter, fn func(domain.Company) error) error
 }
 
 type UsersUsecase interface {
 	CreateUser(ctx context.Context, user domain.User) (*domain.User, error)
+	Profile(ctx context.Context, id int64) (*domain.User, error)
+	UpdateProfile(ctx context.Context, id int64, username, email string) (*domain.User, error)
+	VerifyEmail(ctx context.Context, id int64, token string) (*domain.User, error)
+	ChangePassword(ctx context.Context, id int64, currentPassword, newPassword string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	DeleteAccount(ctx context.Context, id int64) error
+}
+
+type AdminUsecase interface {
+	ListUsers(ctx context.Context, actor domain.User, filter domain.UserFilter) ([]domain.User, error)
+	GetUser(ctx context.Context, actor domain.User, id int64) (*domain.User, error)
+	SetRole(ctx context.Context, actor domain.User, id int64, role domain.Role) (*domain.User, error)
+	SetDisabled(ctx context.Context, actor domain.User, id int64, disabled bool) (*domain.User, error)
+	ForcePasswordReset(ctx context.Context, actor domain.User, id int64) error
+	DeleteUser(ctx context.Context, actor domain.User, id int64) error
+}
+
+type OrganizationsUsecase interface {
+	Create(ctx context.Context, name string) (domain.Organization, error)
+	List(ctx context.Context, user domain.User) ([]domain.Organization, error)
+	AddMember(ctx context.Context, organizationID, userID int64) error
+	RemoveMember(ctx context.Context, organizationID, userID int64) error
+	Switch(ctx context.Context, user domain.User, organizationID int64) (string, error)
+}
+
+type JobsUsecase interface {
+	Create(ctx context.Context, job domain.Job, input io.Reader) (domain.Job, error)
+	Get(ctx context.Context, id uuid.UUID) (domain.Job, error)
+	Cancel(ctx context.Context, id uuid.UUID) (domain.Job, error)
+}
+
+type IdempotencyUsecase interface {
+	Start(ctx context.Context, key domain.IdempotencyKey) (domain.IdempotencyKey, bool, error)
+	Complete(ctx context.Context, key domain.IdempotencyKey) error
+	Abort(ctx context.Context, key domain.IdempotencyKey) error
 }
 
 type AuthUsecase interface {
 	SignUp(ctx context.Context, user domain.User) (string, error)
 	SignIn(ctx context.Context, email string, password string) (string, error)
-	ValidateToken(signedToken string) error
+	ValidateToken(ctx context.Context, signedToken string) (domain.User, error)
+}
+
+type RateLimitUsecase interface {
+	Allow(ctx context.Context, key string, limit domain.RateLimit) domain.RateLimitResult
+}
+
+type HealthUsecase interface {
+	Ready() domain.HealthReport
+}
+
+// Availability reports if storage can serve requests
+type Availability interface {
+	Available() error
+}
+
+type OIDCUsecase interface {
+	LoginURL() (string, error)
+	Callback(ctx context.Context, code, state string) (string, error)
 }
 
 // use a single instance of Validate, it caches struct info
 var validate = validator.New()
 
+const (
+	// userKey is key of authenticated user in gin context
+	userKey = "user"
+	// requestIDHeader is id of request
+	requestIDHeader    = "X-Request-ID"
+	maxRequestIDLength = 64
+	// defaultRetryAfter is asked of clients of requests failed by unavailable storage
+	defaultRetryAfter = 5 * time.Second
+	// serviceName is name of server in traces
+	serviceName = "companies-handler"
+)
+
 // Server is REST API server
 type Server struct {
 	srv *http.Server
@@ -41,22 +120,230 @@ type Server struct {
 
 	companies CompaniesUsecase
 	auth      AuthUsecase
+	oidc      OIDCUsecase
+	users     UsersUsecase
+	admin     AdminUsecase
+	orgs      OrganizationsUsecase
+	jobs      JobsUsecase
+
+	idempotency IdempotencyUsecase
+
+	health       HealthUsecase
+	metrics      *httpMetrics
+	metricsAddr  string
+	metricsToken string
+	metricsSrv   *http.Server
+	availability Availability
+	retryAfter   time.Duration
+
+	batchLimit int
+
+	rateLimits        RateLimitUsecase
+	rateLimitPolicies atomic.Pointer[[]RateLimitPolicy]
+	trustedProxies    []string
+	remoteIPHeaders   []string
+
+	certs                 *CertificateLoader
+	cors                  *CORSPolicy
+	contentSecurityPolicy string
+	hstsMaxAge            time.Duration
+	maxBodySize           int64
+	maxUploadSize         int64
+	timeouts              Timeouts
 }
 
-// NewServer creates new server instance
-func NewServer(addr string, log *zap.Logger, companies CompaniesUsecase, auth AuthUsecase) *Server {
-	e := gin.Default()
+// Timeouts are timeouts of connections of server, 0 disables timeout
+type Timeouts struct {
+	ReadHeader time.Duration
+	// Read limits reading of whole request and Write limits handling of request and writing of response,
+	// streamed downloads are not limited by Write
+	Read  time.Duration
+	Write time.Duration
+	// Idle limits waiting for next request on keep-alive connection
+	Idle time.Duration
+}
 
-	srv := &http.Server{
-		Addr:              addr,
-		Handler:           e,
-		ReadHeaderTimeout: 60 * time.Second,
+// Option configures optional features of server
+type Option func(s *Server)
+
+// WithOIDC enables single sign-on with OpenID Connect provider
+func WithOIDC(oidc OIDCUsecase) Option {
+	return func(s *Server) {
+		s.oidc = oidc
+	}
+}
+
+// WithUsers enables profile and account management endpoints
+func WithUsers(users UsersUsecase) Option {
+	return func(s *Server) {
+		s.users = users
+	}
+}
+
+// WithAdmin enables user management endpoints for admins
+func WithAdmin(admin AdminUsecase) Option {
+	return func(s *Server) {
+		s.admin = admin
+	}
+}
+
+// WithOrganizations enables organization management endpoints
+func WithOrganizations(orgs OrganizationsUsecase) Option {
+	return func(s *Server) {
+		s.orgs = orgs
+	}
+}
+
+// WithJobs enables asynchronous jobs endpoints
+func WithJobs(jobs JobsUsecase) Option {
+	return func(s *Server) {
+		s.jobs = jobs
+	}
+}
+
+// WithIdempotency enables Idempotency-Key header on authorized POST and PATCH requests
+func WithIdempotency(idempotency IdempotencyUsecase) Option {
+	return func(s *Server) {
+		s.idempotency = idempotency
+	}
+}
+
+// WithMetrics enables GET /metrics with metrics of server and other metrics of registry
+func WithMetrics(registry *prometheus.Registry) Option {
+	return func(s *Server) {
+		s.metrics = newHTTPMetrics(registry)
+	}
+}
+
+// WithMetricsAccess restricts GET /metrics: it is served on separate listener at addr instead of address
+// of server if addr is set, and requires bearer token if token is set
+func WithMetricsAccess(addr, token string) Option {
+	return func(s *Server) {
+		s.metricsAddr = addr
+		s.metricsToken = token
+	}
+}
+
+// WithHealth enables /readyz with checks of dependencies
+func WithHealth(health HealthUsecase) Option {
+	return func(s *Server) {
+		s.health = health
+	}
+}
+
+// WithAvailability fails requests fast with 503 while storage is unavailable,
+// clients are asked to retry after retryAfter, which is also used for requests failed by unavailable storage
+func WithAvailability(availability Availability, retryAfter time.Duration) Option {
+	return func(s *Server) {
+		s.availability = availability
+		s.retryAfter = retryAfter
+	}
+}
+
+// WithBatchLimit sets maximum number of operations in batch
+func WithBatchLimit(limit int) Option {
+	return func(s *Server) {
+		s.batchLimit = limit
+	}
+}
+
+// WithRateLimit limits rate of requests by policies, first policy matching request is applied
+func WithRateLimit(rateLimits RateLimitUsecase, policies []RateLimitPolicy) Option {
+	return func(s *Server) {
+		s.rateLimits = rateLimits
+		s.SetRateLimitPolicies(policies)
+	}
+}
+
+// WithTrustedProxies sets addresses or CIDRs of proxies whose X-Forwarded-For header is trusted
+// to get IP of client. No proxies are trusted by default.
+func WithTrustedProxies(proxies []string) Option {
+	return func(s *Server) {
+		s.trustedProxies = proxies
+	}
+}
+
+// WithRemoteIPHeaders sets headers of trusted proxies which carry IP of client, e.g. X-Forwarded-For
+func WithRemoteIPHeaders(headers []string) Option {
+	return func(s *Server) {
+		s.remoteIPHeaders = headers
+	}
+}
+
+// WithTLS serves HTTPS with certificate of loader
+func WithTLS(certs *CertificateLoader) Option {
+	return func(s *Server) {
+		s.certs = certs
+	}
+}
+
+// WithCORS allows browser frontends on other origins to call server
+func WithCORS(policy CORSPolicy) Option {
+	return func(s *Server) {
+		s.cors = &policy
+	}
+}
+
+// WithSecurityHeaders sets Content-Security-Policy of responses and max age of Strict-Transport-Security,
+// which is sent only with TLS
+func WithSecurityHeaders(contentSecurityPolicy string, hstsMaxAge time.Duration) Option {
+	return func(s *Server) {
+		s.contentSecurityPolicy = contentSecurityPolicy
+		s.hstsMaxAge = hstsMaxAge
+	}
+}
+
+// WithBodyLimit limits size of request bodies in bytes, uploads of files, i.e. POST requests with
+// content type of import, are limited by uploadSize. 0 disables limit.
+func WithBodyLimit(size, uploadSize int64) Option {
+	return func(s *Server) {
+		s.maxBodySize = size
+		s.maxUploadSize = uploadSize
+	}
+}
+
+// WithTimeouts sets timeouts of connections
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(s *Server) {
+		s.timeouts = timeouts
 	}
+}
+
+// NewServer creates new server instance
+func NewServer(addr string, log *zap.Logger, companies CompaniesUsecase, auth AuthUsecase, opts ...Option) *Server {
+	e := gin.New()
+
 	s := Server{
-		srv:       srv,
 		log:       log,
 		companies: companies,
 		auth:      auth,
+
+		batchLimit: defaultBatchLimit,
+		retryAfter: defaultRetryAfter,
+		timeouts:   Timeouts{ReadHeader: 60 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	s.srv = &http.Server{
+		Addr:              addr,
+		Handler:           e,
+		ReadHeaderTimeout: s.timeouts.ReadHeader,
+		ReadTimeout:       s.timeouts.Read,
+		WriteTimeout:      s.timeouts.Write,
+		IdleTimeout:       s.timeouts.Idle,
+	}
+	if s.certs != nil {
+		s.srv.TLSConfig = &tls.Config{
+			GetCertificate: s.certs.GetCertificate,
+			MinVersion:     tls.VersionTLS12,
+		}
+	}
+	if err := e.SetTrustedProxies(s.trustedProxies); err != nil {
+		log.Error("can not set trusted proxies, no proxies are trusted", zap.Error(err))
+	}
+	if len(s.remoteIPHeaders) > 0 {
+		e.RemoteIPHeaders = s.remoteIPHeaders
 	}
 	s.routes(e)
 	return &s
@@ -64,30 +351,136 @@ func NewServer(addr string, log *zap.Logger, companies CompaniesUsecase, auth Au
 
 // Routes adds routes to server
 func (s *Server) routes(e *gin.Engine) {
+	// span of request is started first, so that it covers other middlewares
+	e.Use(otelgin.Middleware(serviceName))
+	e.Use(s.RequestID(), s.AccessLog(), s.Recovery(), s.SecurityHeaders())
+	// preflight requests are answered before other middlewares, they match no route
+	if s.cors != nil {
+		e.Use(s.CORS())
+	}
+	if s.metrics != nil {
+		e.Use(s.Metrics())
+		if s.metricsAddr == "" {
+			e.GET("/metrics", s.MetricsAuth(), s.MetricsHandler())
+		} else {
+			s.metricsSrv = s.newMetricsServer()
+		}
+	}
 	e.GET("/healtz", s.Healtz)
+	e.GET("/livez", s.Livez)
+	if s.health != nil {
+		e.GET("/readyz", s.Readyz)
+	}
+	if s.availability != nil {
+		e.Use(s.Available())
+	}
+	e.Use(s.BodyLimit())
 
 	// users
-	e.POST("/register", s.RegisterUser)
-	e.POST("/signin", s.SignIn)
+	anonymous := e.Group("/").Use(s.rateLimited()...)
+	{
+		anonymous.POST("/register", s.RegisterUser)
+		anonymous.POST("/signin", s.SignIn)
+		if s.users != nil {
+			anonymous.POST("/password/reset", s.ResetPassword)
+		}
+		if s.oidc != nil {
+			anonymous.GET("/oidc/login", s.OIDCLogin)
+			anonymous.GET("/oidc/callback", s.OIDCCallback)
+		}
+	}
 
-	public := e.Group("/")
+	public := e.Group("/").Use(s.rateLimited(s.Tenant())...)
 	{
 		public.GET("/companies", s.SelectCompanies)
+		public.GET("/companies/export", s.ExportCompanies)
 		public.GET("/companies/:id", s.GetCompany)
 	}
 
-	private := e.Group("/").Use(s.Auth())
+	private := e.Group("/").Use(s.authorized()...)
 	{
 		private.POST("/companies", s.CreateCompany)
+		private.POST("/companies:method", s.CompanyMethod)
 
 		private.PATCH("/companies/:id", s.UpdateCompany)
 		private.DELETE("/companies/:id", s.DeleteCompany)
+		private.POST("/companies/:id/restore", s.RestoreCompany)
+		private.GET("/companies/:id/history", s.CompanyHistory)
+
+		if s.users != nil {
+			private.GET("/me", s.GetProfile)
+			private.PATCH("/me", s.UpdateProfile)
+			private.DELETE("/me", s.DeleteProfile)
+			private.POST("/me/password", s.ChangePassword)
+			private.POST("/me/email/verify", s.VerifyEmail)
+		}
+
+		if s.jobs != nil {
+			private.POST("/jobs", s.CreateJob)
+			private.GET("/jobs/:id", s.GetJob)
+			private.POST("/jobs/:id/cancel", s.CancelJob)
+			private.GET("/jobs/:id/result", s.JobResult)
+		}
+
+		if s.orgs != nil {
+			private.GET("/organizations", s.ListOrganizations)
+			private.POST("/organizations/:id/token", s.SwitchOrganization)
+		}
+	}
+
+	debug := e.Group("/admin/debug").Use(s.authorized(s.Admin())...)
+	{
+		debug.GET("/vars", gin.WrapH(expvar.Handler()))
+	}
+
+	if s.admin != nil {
+		admin := e.Group("/admin").Use(s.authorized(s.Admin())...)
+		{
+			admin.GET("/users", s.ListUsers)
+			admin.GET("/users/:id", s.GetUser)
+			admin.PUT("/users/:id/role", s.SetUserRole)
+			admin.POST("/users/:id/disable", s.DisableUser)
+			admin.POST("/users/:id/enable", s.EnableUser)
+			admin.POST("/users/:id/password-reset", s.ForcePasswordReset)
+			admin.DELETE("/users/:id", s.DeleteUser)
+		}
+	}
+
+	if s.orgs != nil {
+		admin := e.Group("/admin").Use(s.authorized(s.Admin())...)
+		{
+			admin.POST("/organizations", s.CreateOrganization)
+			admin.POST("/organizations/:id/members", s.AddOrganizationMember)
+			admin.DELETE("/organizations/:id/members/:user_id", s.RemoveOrganizationMember)
+		}
 	}
 }
 
-// Run starts server
+// authorized returns middlewares of routes requiring authorization, checks and rate limit are run after Auth
+func (s *Server) authorized(checks ...gin.HandlerFunc) []gin.HandlerFunc {
+	middlewares := s.rateLimited(append([]gin.HandlerFunc{s.Auth()}, checks...)...)
+	if s.idempotency != nil {
+		middlewares = append(middlewares, s.Idempotency())
+	}
+	return middlewares
+}
+
+// Run starts server, it serves HTTPS if TLS is enabled
 func (s *Server) Run() error {
-	err := s.srv.ListenAndServe()
+	var err error
+	if s.metricsSrv != nil {
+		go func() {
+			if err := s.metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				s.log.Error("can not serve metrics", zap.Error(err))
+			}
+		}()
+	}
+	if s.certs != nil {
+		// certificate is given by TLSConfig
+		err = s.srv.ListenAndServeTLS("", "")
+	} else {
+		err = s.srv.ListenAndServe()
+	}
 	if err == http.ErrServerClosed {
 		return nil
 	}
@@ -96,9 +489,20 @@ func (s *Server) Run() error {
 
 // Stop stops server
 func (s *Server) Shutdown(ctx context.Context) error {
+	if s.metricsSrv != nil {
+		if err := s.metricsSrv.Shutdown(ctx); err != nil {
+			s.log.Warn("can not stop metrics server", zap.Error(err))
+		}
+	}
 	return s.srv.Shutdown(ctx)
 }
 
+// Handler returns handler of routes of server, e.g. to serve it without listening
+func (s *Server) Handler() http.Handler {
+	return s.srv.Handler
+}
+
+// Healtz is kept for compatibility, it is the same as Livez
 func (s *Server) Healtz(c *gin.Context) {
 	c.JSON(http.StatusOK, "ok")
 }
@@ -108,22 +512,104 @@ func (s *Server) Auth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "request does not contain an access token"})
+			c.JSON(http.StatusUnauthorized, errorBody(c, "request does not contain an access token"))
 			c.Abort()
 			return
 		}
 		if !strings.HasPrefix(authHeader, "Bearer") {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "token is not bearer"})
+			c.JSON(http.StatusUnauthorized, errorBody(c, "token is not bearer"))
 			c.Abort()
 			return
 		}
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		err := s.auth.ValidateToken(tokenString)
+		user, err := s.auth.ValidateToken(c.Request.Context(), tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			s.respondError(c, http.StatusUnauthorized, err)
+			c.Abort()
+			return
+		}
+		c.Set(userKey, user)
+		ctx := domain.WithActor(c.Request.Context(), user.ID)
+		if user.OrganizationID != 0 {
+			ctx = domain.WithTenant(ctx, user.OrganizationID)
+		}
+		ctx = logging.With(ctx, zap.Int64("user_id", user.ID), zap.Int64("organization_id", user.OrganizationID))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// RequestID is middleware to assign id to request, id from X-Request-ID header is kept.
+// Logger of request context carries id of request, its route and id of its trace.
+func (s *Server) RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+		c.Header(requestIDHeader, requestID)
+		ctx := domain.WithRequestID(c.Request.Context(), requestID)
+		fields := []zap.Field{zap.String("request_id", requestID), zap.String("route", c.FullPath())}
+		if span := trace.SpanContextFromContext(ctx); span.HasTraceID() {
+			fields = append(fields, zap.String("trace_id", span.TraceID().String()))
+		}
+		c.Request = c.Request.WithContext(logging.WithLogger(ctx, s.log.With(fields...)))
+		c.Next()
+	}
+}
+
+// Available is middleware to fail requests with 503 while storage is unavailable
+func (s *Server) Available() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := s.availability.Available(); err != nil {
+			c.Header("Retry-After", ceilSeconds(s.retryAfter))
+			s.respondError(c, http.StatusServiceUnavailable, err)
 			c.Abort()
 			return
 		}
 		c.Next()
 	}
 }
+
+// Tenant is middleware to scope public requests to organization. Requests with token are
+// scoped to organization of token and fail with 401 if token is invalid, anonymous requests
+// are scoped to default organization only
+func (s *Server) Tenant() gin.HandlerFunc {
+	auth := s.Auth()
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			auth(c)
+			return
+		}
+		c.Request = c.Request.WithContext(domain.WithTenant(c.Request.Context(), domain.DefaultOrganizationID))
+		c.Next()
+	}
+}
+
+// Admin is middleware to allow only admins, it must be used after Auth
+func (s *Server) Admin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if currentUser(c).Role != domain.RoleAdmin {
+			c.JSON(http.StatusForbidden, errorBody(c, "admin role is required"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// currentUser returns user authenticated by Auth middleware
+func currentUser(c *gin.Context) domain.User {
+	user, _ := c.MustGet(userKey).(domain.User)
+	return user
+}
+
+// optionalUser returns user if request was authenticated
+func optionalUser(c *gin.Context) (domain.User, bool) {
+	user, ok := c.Get(userKey)
+	if !ok {
+		return domain.User{}, false
+	}
+	u, ok := user.(domain.User)
+	return u, ok
+}
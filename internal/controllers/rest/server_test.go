@@ -0,0 +1,217 @@
+package rest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/controllers/rest"
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	validToken = "valid"
+	orgToken   = "organization"
+)
+
+// stubCompanies returns company of tenant of request, other methods of usecase are not used by tests
+type stubCompanies struct {
+	rest.CompaniesUsecase
+	companies map[int64]domain.Company
+	err       error
+}
+
+func (s stubCompanies) Get(ctx context.Context, id uuid.UUID) (domain.Company, error) {
+	if s.err != nil {
+		return domain.Company{}, s.err
+	}
+	tenant, _ := domain.TenantFromContext(ctx)
+	company, ok := s.companies[tenant]
+	if !ok || company.ID != id {
+		return domain.Company{}, errors.New("company not found")
+	}
+	return company, nil
+}
+
+// stubAuth accepts validToken of user of default organization and orgToken of user of organization 2
+type stubAuth struct {
+	rest.AuthUsecase
+}
+
+func (stubAuth) ValidateToken(ctx context.Context, token string) (domain.User, error) {
+	switch token {
+	case validToken:
+		return domain.User{ID: 1, Role: domain.RoleUser, OrganizationID: domain.DefaultOrganizationID}, nil
+	case orgToken:
+		return domain.User{ID: 2, Role: domain.RoleUser, OrganizationID: 2}, nil
+	}
+	return domain.User{}, errors.New("token is invalid")
+}
+
+func newTestServer(t *testing.T, companies rest.CompaniesUsecase, opts ...rest.Option) http.Handler {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	return rest.NewServer("", zap.NewNop(), companies, stubAuth{}, opts...).Handler()
+}
+
+func serve(h http.Handler, method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest(method, target, http.NoBody)
+	} else {
+		req = httptest.NewRequest(method, target, strings.NewReader(body))
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTenant(t *testing.T) {
+	defaultCompany := domain.Company{ID: uuid.New(), Name: "default"}
+	orgCompany := domain.Company{ID: uuid.New(), Name: "organization"}
+	h := newTestServer(t, stubCompanies{companies: map[int64]domain.Company{
+		domain.DefaultOrganizationID: defaultCompany,
+		2:                            orgCompany,
+	}})
+
+	tests := []struct {
+		name    string
+		id      uuid.UUID
+		headers map[string]string
+		status  int
+	}{
+		{
+			name:   "anonymous",
+			id:     defaultCompany.ID,
+			status: http.StatusOK,
+		},
+		{
+			name:   "anonymous in other organization",
+			id:     orgCompany.ID,
+			status: http.StatusNotFound,
+		},
+		{
+			name:    "anonymous with organization header",
+			id:      orgCompany.ID,
+			headers: map[string]string{"X-Organization-ID": "2"},
+			status:  http.StatusNotFound,
+		},
+		{
+			name:    "token of organization",
+			id:      orgCompany.ID,
+			headers: map[string]string{"Authorization": "Bearer " + orgToken},
+			status:  http.StatusOK,
+		},
+		{
+			name:    "token of other organization",
+			id:      orgCompany.ID,
+			headers: map[string]string{"Authorization": "Bearer " + validToken},
+			status:  http.StatusNotFound,
+		},
+		{
+			// invalid token is not ignored, so that client does not see registry of wrong organization
+			name:    "invalid token",
+			id:      defaultCompany.ID,
+			headers: map[string]string{"Authorization": "Bearer expired"},
+			status:  http.StatusUnauthorized,
+		},
+		{
+			name:    "not bearer",
+			id:      defaultCompany.ID,
+			headers: map[string]string{"Authorization": "Basic dXNlcjpwYXNz"},
+			status:  http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h, http.MethodGet, "/companies/"+tt.id.String(), "", tt.headers)
+			require.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	h := newTestServer(t, stubCompanies{err: fmt.Errorf("can not get company: %w", domain.ErrUnavailable)},
+		rest.WithAvailability(availability{}, 10*time.Second))
+
+	rec := serve(h, http.MethodGet, "/companies/"+uuid.NewString(), "", nil)
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.Equal(t, "10", rec.Header().Get("Retry-After"))
+
+	// errors of other requests keep their status
+	h = newTestServer(t, stubCompanies{err: domain.ErrCompanyNotFound})
+	rec = serve(h, http.MethodGet, "/companies/"+uuid.NewString(), "", nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+	require.Empty(t, rec.Header().Get("Retry-After"))
+}
+
+// availability reports storage as available, so that requests reach handlers
+type availability struct{}
+
+func (availability) Available() error {
+	return nil
+}
+
+// selectingCompanies records filter of listing and selects nothing
+type selectingCompanies struct {
+	rest.CompaniesUsecase
+	filter domain.CompanyFilter
+}
+
+func (c *selectingCompanies) Select(_ context.Context, filter domain.CompanyFilter) ([]domain.Company, error) {
+	c.filter = filter
+	return nil, nil
+}
+
+func TestSelectCompaniesFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		limit  int
+		offset int
+	}{
+		{
+			name:  "default limit",
+			limit: 20,
+		},
+		{
+			name:   "limit and offset",
+			query:  "?limit=50&offset=10",
+			limit:  50,
+			offset: 10,
+		},
+		{
+			name:  "limit over maximum",
+			query: "?limit=1000000",
+			limit: 100,
+		},
+		{
+			name:   "negative offset",
+			query:  "?offset=-5",
+			limit:  20,
+			offset: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			companies := &selectingCompanies{}
+			h := newTestServer(t, companies)
+			rec := serve(h, http.MethodGet, "/companies"+tt.query, "", nil)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			require.Equal(t, tt.limit, companies.filter.Limit)
+			require.Equal(t, tt.offset, companies.filter.Offset)
+		})
+	}
+}
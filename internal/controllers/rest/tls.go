@@ -0,0 +1,39 @@
+package rest
+
+import (
+	"crypto/tls"
+	"fmt"
+	"sync/atomic"
+)
+
+// CertificateLoader keeps TLS certificate loaded from files, renewed certificate is served after Reload
+// without restart
+type CertificateLoader struct {
+	certFile string
+	keyFile  string
+	cert     atomic.Pointer[tls.Certificate]
+}
+
+// NewCertificateLoader loads certificate and its key from PEM files
+func NewCertificateLoader(certFile, keyFile string) (*CertificateLoader, error) {
+	l := &CertificateLoader{certFile: certFile, keyFile: keyFile}
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload loads certificate from files again, previous certificate is kept if it fails
+func (l *CertificateLoader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return fmt.Errorf("can not load certificate: %w", err)
+	}
+	l.cert.Store(&cert)
+	return nil
+}
+
+// GetCertificate returns loaded certificate, it is used as GetCertificate of tls.Config
+func (l *CertificateLoader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return l.cert.Load(), nil
+}
@@ -19,15 +19,15 @@ func (s *Server) RegisterUser(c *gin.Context) {
 	var u user
 	err := c.ShouldBind(&u)
 	if err != nil {
-		s.log.Error("can not bind user", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not bind user", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	err = validate.Struct(&u)
 	if err != nil {
-		s.log.Error("can not validate user", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not validate user", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
@@ -37,15 +37,15 @@ func (s *Server) RegisterUser(c *gin.Context) {
 	}
 	err = newUser.HashPassword(u.Password)
 	if err != nil {
-		s.log.Error("can not hash user password", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not hash user password", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	token, err := s.auth.SignUp(c.Request.Context(), newUser)
 	if err != nil {
-		s.log.Error("can not create new user", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not create new user", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
 		return
 	}
 	c.JSON(http.StatusCreated, gin.H{"token": token})
@@ -56,15 +56,16 @@ func (s *Server) SignIn(c *gin.Context) {
 	var u user
 	err := c.ShouldBind(&u)
 	if err != nil {
-		s.log.Error("can not bind user", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not bind user", zap.Error(err))
+		s.respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	token, err := s.auth.SignIn(c.Request.Context(), u.Email, u.Password)
+	s.countSignIn(signInPassword, err)
 	if err != nil {
-		s.log.Error("can not sign in", zap.Error(err))
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		s.logger(c).Error("can not sign in", zap.Error(err))
+		s.respondError(c, http.StatusUnauthorized, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"token": token})
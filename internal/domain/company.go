@@ -1,6 +1,10 @@
 package domain
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type CompanyType string
 
@@ -19,4 +23,63 @@ type Company struct {
 	AmountOfEmployees int
 	Registered        bool
 	Type              CompanyType
+	// DeletedAt is time of soft deletion, nil for not deleted companies
+	DeletedAt *time.Time
+}
+
+// CompanyFilter is filter for list of companies
+type CompanyFilter struct {
+	Limit  int
+	Offset int
+	// IncludeDeleted includes soft deleted companies
+	IncludeDeleted bool
+}
+
+type CompanyAction string
+
+const (
+	CompanyCreated  CompanyAction = "create"
+	CompanyUpdated  CompanyAction = "update"
+	CompanyDeleted  CompanyAction = "delete"
+	CompanyRestored CompanyAction = "restore"
+)
+
+// CompanyChange is entry of company history
+type CompanyChange struct {
+	ID        int64
+	CompanyID uuid.UUID
+	Action    CompanyAction
+	// Before is state of company before change, nil for created companies
+	Before *Company
+	// After is state of company after change, nil for deleted companies
+	After     *Company
+	ActorID   int64
+	RequestID string
+	CreatedAt time.Time
+}
+
+// CompanyOperation is operation of batch, Action is create, update or delete
+type CompanyOperation struct {
+	Action  CompanyAction
+	ID      uuid.UUID
+	Company Company
+}
+
+// CompanyOperationResult is result of batch operation, ID is id of created or changed company
+type CompanyOperationResult struct {
+	ID  uuid.UUID
+	Err error
+}
+
+// CompanySource is stream of companies, Next returns false when stream is over or failed
+type CompanySource interface {
+	Next() bool
+	Company() Company
+	Err() error
+}
+
+// ImportResult is result of import of companies
+type ImportResult struct {
+	Created int
+	Updated int
 }
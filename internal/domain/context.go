@@ -0,0 +1,43 @@
+package domain
+
+import "context"
+
+type actorKey struct{}
+
+type requestIDKey struct{}
+
+type primaryReadKey struct{}
+
+// WithActor returns context of request made by user
+func WithActor(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, actorKey{}, userID)
+}
+
+// ActorFromContext returns id of user who made request
+func ActorFromContext(ctx context.Context) int64 {
+	id, _ := ctx.Value(actorKey{}).(int64)
+	return id
+}
+
+// WithRequestID returns context of request with id
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns id of request
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithPrimaryRead returns context whose reads are served by primary database instead of replicas,
+// so that they see every committed write
+func WithPrimaryRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryReadKey{}, true)
+}
+
+// PrimaryReadFromContext reports whether reads of context must be served by primary database
+func PrimaryReadFromContext(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryReadKey{}).(bool)
+	return primary
+}
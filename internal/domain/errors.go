@@ -5,4 +5,43 @@ import "errors"
 var (
 	ErrTokenExpired   = errors.New("token is expired")
 	ErrTokenBadClaims = errors.New("can not parse claims")
+
+	ErrUserNotFound          = errors.New("user not found")
+	ErrUserExists            = errors.New("user with the same username or email already exists")
+	ErrEmailVerificationFail = errors.New("email verification token is invalid or expired")
+	ErrPasswordResetFail     = errors.New("password reset token is invalid or expired")
+	ErrUserDisabled          = errors.New("user is disabled")
+	ErrPasswordResetRequired = errors.New("password reset is required")
+	ErrSelfAdminAction       = errors.New("admin can not change own account")
+	ErrInvalidPassword       = errors.New("current password is incorrect")
+
+	ErrCompanyNotFound  = errors.New("company not found")
+	ErrCompanyExists    = errors.New("company with the same name already exists")
+	ErrUnknownOperation = errors.New("unknown batch operation")
+	ErrBatchRolledBack  = errors.New("operation is rolled back because another operation of batch failed")
+
+	ErrNoTenant              = errors.New("request is not scoped to organization")
+	ErrNotOrganizationMember = errors.New("user is not member of organization")
+	ErrOrganizationExists    = errors.New("organization with the same name already exists")
+
+	ErrJobNotFound     = errors.New("job not found")
+	ErrJobFinished     = errors.New("job is finished")
+	ErrJobNoResult     = errors.New("job has no result")
+	ErrUnknownJobType  = errors.New("unknown job type")
+	ErrJobNoInput      = errors.New("job requires input")
+	ErrJobQueueIsEmpty = errors.New("no jobs to run")
+	ErrJobClaimLost    = errors.New("job is claimed by another worker")
+
+	ErrIdempotencyKeyReused     = errors.New("idempotency key is already used with another request")
+	ErrIdempotencyKeyInProgress = errors.New("request with idempotency key is in progress")
+
+	ErrSchemaOutdated = errors.New("database schema is behind migrations")
+	ErrSchemaDirty    = errors.New("last migration of database schema failed")
+	ErrUnavailable    = errors.New("storage is temporarily unavailable")
+
+	ErrOIDCBadState         = errors.New("oidc state is unknown or expired")
+	ErrOIDCMissingEmail     = errors.New("oidc id token does not contain email")
+	ErrOIDCEmailTaken       = errors.New("user with email of identity provider already exists, sign in with password")
+	ErrOIDCEmailNotVerified = errors.New("email of identity provider is not verified")
+	ErrOIDCTooManyLogins    = errors.New("too many oidc logins are in progress, try again later")
 )
@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+// HealthCheckResult is result of check of one dependency, Err is nil if dependency is healthy
+type HealthCheckResult struct {
+	Err      error
+	Duration time.Duration
+}
+
+// HealthReport is result of all checks of dependencies
+type HealthReport struct {
+	Checks    map[string]HealthCheckResult
+	CheckedAt time.Time
+	// ShuttingDown is true once graceful shutdown started, instance is not ready then whatever checks report
+	ShuttingDown bool
+}
+
+// Healthy checks if instance is ready to serve requests
+func (r HealthReport) Healthy() bool {
+	if r.ShuttingDown {
+		return false
+	}
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// IdempotencyKey is stored response of request with Idempotency-Key header
+type IdempotencyKey struct {
+	UserID int64
+	Key    string
+	// Fingerprint is hash of method, path and body of request
+	Fingerprint string
+	// Status is 0 while request is in progress
+	Status      int
+	ContentType string
+	Response    []byte
+	// StartedAt is when request took the key, response is stored only while key is still taken by it
+	StartedAt time.Time
+	ExpiresAt time.Time
+}
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobType is type of asynchronous job
+type JobType string
+
+const (
+	JobImport JobType = "import"
+	JobExport JobType = "export"
+)
+
+// JobStatus is status of job
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+	JobCancelled JobStatus = "cancelled"
+)
+
+// Finished reports whether job will not run anymore
+func (s JobStatus) Finished() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCancelled
+}
+
+// Job is asynchronous job executed by workers
+type Job struct {
+	ID             uuid.UUID
+	OrganizationID int64
+	UserID         int64
+	Type           JobType
+	Status         JobStatus
+	Params         map[string]string
+	// Input is path of input artifact
+	Input string
+	// Result is path of result artifact
+	Result      string
+	Progress    int
+	Attempts    int
+	MaxAttempts int
+	// ClaimToken identifies claim of running job, only worker holding the last claim can update job
+	ClaimToken      uuid.UUID
+	Error           string
+	CancelRequested bool
+	RunAt           time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	FinishedAt      *time.Time
+}
@@ -0,0 +1,25 @@
+package domain
+
+import "context"
+
+// DefaultOrganizationID is id of organization new users join
+const DefaultOrganizationID int64 = 1
+
+// Organization is tenant with isolated registry of companies
+type Organization struct {
+	ID   int64
+	Name string
+}
+
+type tenantKey struct{}
+
+// WithTenant returns context scoped to organization
+func WithTenant(ctx context.Context, organizationID int64) context.Context {
+	return context.WithValue(ctx, tenantKey{}, organizationID)
+}
+
+// TenantFromContext returns organization context is scoped to
+func TenantFromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(tenantKey{}).(int64)
+	return id, ok && id != 0
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimit is token bucket which allows Requests per Period on average and bursts of up to Burst requests
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	// Burst is size of bucket, it is Requests if it is not set
+	Burst int
+}
+
+// Capacity returns number of tokens of full bucket
+func (l RateLimit) Capacity() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// Rate returns number of tokens added to bucket per second
+func (l RateLimit) Rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result describes bucket with tokens left after request was allowed or denied
+func (l RateLimit) Result(tokens float64, allowed bool) RateLimitResult {
+	rate := l.Rate()
+	result := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     l.Capacity(),
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(l.Capacity()) - tokens) / rate),
+	}
+	if !allowed {
+		result.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(s, 0) * float64(time.Second))
+}
+
+// RateLimitResult is result of taking token from bucket of rate limit
+type RateLimitResult struct {
+	Allowed bool
+	// Limit is capacity of bucket and Remaining is number of whole tokens left in it
+	Limit     int
+	Remaining int
+	// Reset is time until bucket is full again
+	Reset time.Duration
+	// RetryAfter is time until next request is allowed, it is 0 if request is allowed
+	RetryAfter time.Duration
+}
@@ -1,6 +1,26 @@
 package domain
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Role is role of user in service
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+// MaxUsernameLength is maximum number of characters of username
+const MaxUsernameLength = 15
+
+// Valid reports whether role is known
+func (r Role) Valid() bool {
+	return r == RoleUser || r == RoleAdmin
+}
 
 // User is user of service
 type User struct {
@@ -8,6 +28,31 @@ type User struct {
 	Username string
 	Email    string
 	Password string
+	Role     Role
+	// OrganizationID is organization of current session, it is taken from token
+	OrganizationID int64
+	// OIDCSubject is subject of user in identity provider, empty for local users
+	OIDCSubject string
+
+	// PendingEmail is new email waiting for verification
+	PendingEmail string
+	// EmailVerificationToken is hash of token sent to pending email
+	EmailVerificationToken     string
+	EmailVerificationExpiresAt time.Time
+
+	// Disabled users can not sign in and their tokens are rejected
+	Disabled bool
+	// PasswordResetToken is hash of token sent to user when admin forced password reset
+	PasswordResetToken     string
+	PasswordResetExpiresAt time.Time
+}
+
+// UserFilter is filter for list of users
+type UserFilter struct {
+	// Query is searched in username and email
+	Query  string
+	Limit  int
+	Offset int
 }
 
 // HashPassword hashs password
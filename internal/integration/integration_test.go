@@ -2,27 +2,33 @@ package integration_test
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"syscall"
 	"testing"
+	"time"
 
 	"github.com/Ragnar-BY/companies-handler/internal/broker"
 	"github.com/Ragnar-BY/companies-handler/internal/config"
 	"github.com/Ragnar-BY/companies-handler/internal/controllers/rest"
 	"github.com/Ragnar-BY/companies-handler/internal/domain"
 	"github.com/Ragnar-BY/companies-handler/internal/repository/postgres"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/repotest"
 	"github.com/Ragnar-BY/companies-handler/internal/service"
 	"github.com/Ragnar-BY/companies-handler/internal/usecase"
+	"github.com/Ragnar-BY/companies-handler/postgres/migrations"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap"
 )
@@ -33,8 +39,11 @@ type e2eTestSuite struct {
 	srvAddr     string
 	dbMigration *migrate.Migrate
 	dbClient    *postgres.PostgresClient
+	dbConn      string
+	pgSettings  postgres.PostgresSettings
 
-	server *rest.Server
+	server  *rest.Server
+	workers *service.JobWorkers
 }
 
 func Test_E2ETestSuite(t *testing.T) {
@@ -46,19 +55,21 @@ func (s *e2eTestSuite) SetupSuite() {
 
 	cfg, err := config.LoadConfig(".env")
 	s.Require().NoError(err)
-	s.srvAddr = cfg.ServerAddress
+	s.srvAddr = cfg.Server.Address
 
 	pgSettings := postgres.PostgresSettings{
-		Addr:     cfg.PostgresAddress,
-		Username: cfg.PostgresUser,
-		Password: cfg.PostgresPassword,
-		Database: cfg.PostgresDB,
+		Addr:     cfg.Postgres.Address,
+		Username: cfg.Postgres.User,
+		Password: cfg.Postgres.Password,
+		Database: cfg.Postgres.DB,
 	}
 
 	dbConn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", pgSettings.Username, pgSettings.Password, pgSettings.Addr, pgSettings.Database)
 	dbClient, err := postgres.NewPostgresClient(pgSettings)
 	s.Require().NoError(err)
 	s.dbClient = dbClient
+	s.dbConn = dbConn
+	s.pgSettings = pgSettings
 
 	msgBroker := broker.NewBroker()
 	eventSrv := service.NewEventSender(msgBroker)
@@ -67,21 +78,49 @@ func (s *e2eTestSuite) SetupSuite() {
 	companyUsecase := usecase.NewCompanyUsecase(companySrv, eventSrv)
 
 	userSrv := service.NewUserService(dbClient)
-	authSrv := service.NewAuthService([]byte(cfg.JWTKey))
-	authUsecase := usecase.NewAuthUsecase(authSrv, userSrv)
-	srv := rest.NewServer(cfg.ServerAddress, logger, companyUsecase, authUsecase)
+	orgSrv := service.NewOrganizationService(dbClient)
+	authSrv := service.NewAuthService([]byte(cfg.Auth.JWTKey))
+	authUsecase := usecase.NewAuthUsecase(authSrv, userSrv, orgSrv)
+	txSrv := service.NewTxService(dbClient)
+	userUsecase := usecase.NewUserUsecase(userSrv, eventSrv, txSrv)
+	adminUsecase := usecase.NewAdminUsecase(userSrv, eventSrv, txSrv)
+	artifacts, err := service.NewArtifactStore(s.T().TempDir())
+	s.Require().NoError(err)
+	s.workers = service.NewJobWorkers(dbClient, artifacts, service.WorkerSettings{
+		Workers:           1,
+		PollInterval:      50 * time.Millisecond,
+		HeartbeatInterval: time.Second,
+		StaleAfter:        time.Minute,
+		RetryBackoff:      time.Second,
+		MaxRetryBackoff:   time.Second,
+	}, logger)
+	s.workers.Handle(domain.JobImport, rest.ImportJob(companyUsecase))
+	s.workers.Handle(domain.JobExport, rest.ExportJob(companyUsecase))
+	jobUsecase := usecase.NewJobUsecase(service.NewJobService(dbClient), artifacts, 1)
+
+	srv := rest.NewServer(cfg.Server.Address, logger, companyUsecase, authUsecase,
+		rest.WithUsers(userUsecase), rest.WithAdmin(adminUsecase),
+		rest.WithOrganizations(usecase.NewOrganizationUsecase(orgSrv, authSrv)),
+		rest.WithJobs(jobUsecase),
+		rest.WithIdempotency(usecase.NewIdempotencyUsecase(service.NewIdempotencyService(dbClient, logger), time.Hour, time.Minute)))
 	s.server = srv
 
-	s.dbMigration, err = migrate.New("file://../../postgres/migrations", dbConn)
+	src, err := iofs.New(migrations.FS, ".")
+	s.Require().NoError(err)
+	s.dbMigration, err = migrate.NewWithSourceInstance("iofs", src, dbConn)
 	s.Require().NoError(err)
 	if err := s.dbMigration.Up(); err != nil && err != migrate.ErrNoChange {
 		s.Require().NoError(err)
 	}
 
 	go srv.Run()
+	s.workers.Start()
 }
 
 func (s *e2eTestSuite) TearDownSuite() {
+	if s.workers != nil {
+		s.NoError(s.workers.Shutdown(context.Background()))
+	}
 	p, err := os.FindProcess(syscall.Getpid())
 	s.Require().NoError(err)
 	err = p.Signal(syscall.SIGINT)
@@ -98,6 +137,65 @@ func (s *e2eTestSuite) TearDownTest() {
 	s.NoError(s.dbMigration.Down())
 }
 
+// signUp registers user with email and returns its token
+func (s *e2eTestSuite) signUp(email string) string {
+	body := fmt.Sprintf(`{"username":"test user","email":%q,"password":"12345678"}`, email)
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, fmt.Sprintf("http://%s/register", s.srvAddr), strings.NewReader(body))
+	s.Require().NoError(err)
+	req.Header.Set("Content-Type", "application/json")
+	client := http.Client{}
+	response, err := client.Do(req)
+	s.Require().NoError(err)
+	defer response.Body.Close()
+	s.Require().Equal(http.StatusCreated, response.StatusCode)
+
+	token := struct {
+		Token string
+	}{}
+	s.Require().NoError(json.NewDecoder(response.Body).Decode(&token))
+	return token.Token
+}
+
+func (s *e2eTestSuite) Test_RepositoryConformance() {
+	repotest.Run(s.T(), func(t *testing.T) repotest.Repository {
+		require.NoError(t, s.dbMigration.Down())
+		require.NoError(t, s.dbMigration.Up())
+		return s.dbClient
+	})
+}
+
+func (s *e2eTestSuite) Test_ConcurrentUserUpdates() {
+	ctx := context.Background()
+	user, err := s.dbClient.CreateUser(ctx, domain.User{Username: "user", Email: "test@test.com", Role: domain.RoleUser})
+	s.Require().NoError(err)
+
+	// every transaction appends to username of user it read, none of updates is lost
+	const updates = 5
+	var wg sync.WaitGroup
+	for i := 0; i < updates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.dbClient.WithinTx(ctx, func(ctx context.Context) error {
+				current, err := s.dbClient.GetUserByID(ctx, user.ID)
+				if err != nil {
+					return err
+				}
+				time.Sleep(50 * time.Millisecond)
+				current.Username += "!"
+				_, err = s.dbClient.UpdateUser(ctx, *current)
+				return err
+			})
+			s.NoError(err)
+		}()
+	}
+	wg.Wait()
+
+	updated, err := s.dbClient.GetUserByID(ctx, user.ID)
+	s.Require().NoError(err)
+	s.Equal("user"+strings.Repeat("!", updates), updated.Username)
+}
+
 func (s *e2eTestSuite) Test_EndToEnd_GetCompany() {
 	company := domain.Company{
 		Name:              "test-company",
@@ -106,7 +204,7 @@ func (s *e2eTestSuite) Test_EndToEnd_GetCompany() {
 		Registered:        true,
 		Type:              domain.Corporations,
 	}
-	ctx := context.Background()
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
 
 	id, err2 := s.dbClient.CreateCompany(ctx, company)
 	s.Require().NoError(err2)
@@ -127,7 +225,7 @@ func (s *e2eTestSuite) Test_EndToEnd_GetCompany() {
 			name:         "wrong id",
 			id:           uuid.Nil,
 			statusCode:   http.StatusNotFound,
-			responseBody: `{"error":"can not get company: sql: no rows in result set"}`,
+			responseBody: `{"error":"can not get company: sql: no rows in result set","request_id":"test-request"}`,
 		},
 	}
 
@@ -137,6 +235,7 @@ func (s *e2eTestSuite) Test_EndToEnd_GetCompany() {
 			s.NoError(err)
 
 			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Request-ID", "test-request")
 
 			client := http.Client{}
 			response, err := client.Do(req)
@@ -164,7 +263,7 @@ func (s *e2eTestSuite) Test_EndToEnd_SelectCompanies() {
 		Registered:        true,
 		Type:              domain.Corporations,
 	}
-	ctx := context.Background()
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
 
 	id, err := s.dbClient.CreateCompany(ctx, company)
 	s.NoError(err)
@@ -218,27 +317,10 @@ func (s *e2eTestSuite) Test_EndToEnd_CreateCompany() {
 		Registered:        true,
 		Type:              domain.Corporations,
 	}
-	ctx := context.Background()
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
 
-	userBody := `{
-		"username":"test user",
-		"email": "test@test.com",
-		"password": "12345678"
-	}`
-	UserReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s/register", s.srvAddr), strings.NewReader(userBody))
-	s.NoError(err)
-	UserReq.Header.Set("Content-Type", "application/json")
+	token := s.signUp("test@test.com")
 	client := http.Client{}
-	userResponse, err := client.Do(UserReq)
-	s.NoError(err)
-	s.Equal(http.StatusCreated, userResponse.StatusCode)
-
-	token := struct {
-		Token string
-	}{}
-	err = json.NewDecoder(userResponse.Body).Decode(&token)
-	s.NoError(err)
-	userResponse.Body.Close()
 
 	testCases := []struct {
 		name          string
@@ -250,7 +332,7 @@ func (s *e2eTestSuite) Test_EndToEnd_CreateCompany() {
 		{
 			name:       "valid test",
 			statusCode: http.StatusCreated,
-			token:      token.Token,
+			token:      token,
 			companyBody: `{
 				"name": "company-create",
 				"description": "some description",
@@ -305,7 +387,7 @@ func (s *e2eTestSuite) Test_EndToEnd_CreateCompany() {
 }
 
 func (s *e2eTestSuite) Test_EndToEnd_DeleteCompany() {
-	ctx := context.Background()
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
 
 	company := domain.Company{
 		Name:              "test-company",
@@ -318,25 +400,8 @@ func (s *e2eTestSuite) Test_EndToEnd_DeleteCompany() {
 	id, err2 := s.dbClient.CreateCompany(ctx, company)
 	s.Require().NoError(err2)
 
-	userBody := `{
-		"username":"test user",
-		"email": "test@test.com",
-		"password": "12345678"
-	}`
-	UserReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s/register", s.srvAddr), strings.NewReader(userBody))
-	s.NoError(err)
-	UserReq.Header.Set("Content-Type", "application/json")
+	token := s.signUp("test@test.com")
 	client := http.Client{}
-	userResponse, err := client.Do(UserReq)
-	s.NoError(err)
-	s.Equal(http.StatusCreated, userResponse.StatusCode)
-
-	token := struct {
-		Token string
-	}{}
-	err = json.NewDecoder(userResponse.Body).Decode(&token)
-	s.NoError(err)
-	userResponse.Body.Close()
 
 	testCases := []struct {
 		name       string
@@ -347,9 +412,21 @@ func (s *e2eTestSuite) Test_EndToEnd_DeleteCompany() {
 		{
 			name:       "valid test",
 			statusCode: http.StatusOK,
-			token:      token.Token,
+			token:      token,
 			id:         id,
 		},
+		{
+			name:       "already deleted",
+			statusCode: http.StatusNotFound,
+			token:      token,
+			id:         id,
+		},
+		{
+			name:       "not existing",
+			statusCode: http.StatusNotFound,
+			token:      token,
+			id:         uuid.New(),
+		},
 		{
 			name:       "unauthorized",
 			statusCode: http.StatusUnauthorized,
@@ -378,3 +455,643 @@ func (s *e2eTestSuite) Test_EndToEnd_DeleteCompany() {
 		})
 	}
 }
+
+func (s *e2eTestSuite) Test_EndToEnd_Profile() {
+	ctx := context.Background()
+
+	token := s.signUp("test@test.com")
+	client := http.Client{}
+
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		statusCode   int
+		responseBody string
+	}{
+		{
+			name:         "get profile",
+			method:       http.MethodGet,
+			path:         "/me",
+			statusCode:   http.StatusOK,
+			responseBody: `{"id":1,"username":"test user","email":"test@test.com","role":"user"}`,
+		},
+		{
+			name:         "wrong current password",
+			method:       http.MethodPost,
+			path:         "/me/password",
+			body:         `{"current_password":"wrong-password","new_password":"87654321"}`,
+			statusCode:   http.StatusForbidden,
+			responseBody: `{"error":"current password is incorrect","request_id":"test-request"}`,
+		},
+		{
+			name:         "change email waits for verification",
+			method:       http.MethodPatch,
+			path:         "/me",
+			body:         `{"email":"new@test.com"}`,
+			statusCode:   http.StatusOK,
+			responseBody: `{"id":1,"username":"test user","email":"test@test.com","role":"user","pending_email":"new@test.com"}`,
+		},
+		{
+			name:         "delete account",
+			method:       http.MethodDelete,
+			path:         "/me",
+			statusCode:   http.StatusOK,
+			responseBody: `""`,
+		},
+		{
+			name:         "deleted account",
+			method:       http.MethodGet,
+			path:         "/me",
+			statusCode:   http.StatusUnauthorized,
+			responseBody: `{"error":"user not found","request_id":"test-request"}`,
+		},
+	}
+
+	for _, tt := range testCases {
+		s.Run(tt.name, func() {
+			req, err := http.NewRequestWithContext(ctx, tt.method, fmt.Sprintf("http://%s%s", s.srvAddr, tt.path), strings.NewReader(tt.body))
+			s.NoError(err)
+
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Request-ID", "test-request")
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+			response, err := client.Do(req)
+			s.NoError(err)
+			s.Equal(tt.statusCode, response.StatusCode)
+
+			byteBody, err := io.ReadAll(response.Body)
+			s.NoError(err)
+
+			s.Equal(tt.responseBody, string(byteBody))
+			response.Body.Close()
+		})
+	}
+}
+
+func (s *e2eTestSuite) Test_EndToEnd_TenantIsolation() {
+	ctx := context.Background()
+
+	org, err := s.dbClient.CreateOrganization(ctx, "business-unit")
+	s.Require().NoError(err)
+
+	company := domain.Company{
+		Name:              "test-company",
+		Description:       "some description",
+		AmountOfEmployees: 123,
+		Registered:        true,
+		Type:              domain.Corporations,
+	}
+	id, err := s.dbClient.CreateCompany(domain.WithTenant(ctx, org.ID), company)
+	s.Require().NoError(err)
+
+	// the same name is allowed in another organization
+	_, err = s.dbClient.CreateCompany(domain.WithTenant(ctx, domain.DefaultOrganizationID), company)
+	s.Require().NoError(err)
+
+	// token is scoped to organization by switching to it
+	token := s.signUp("test@test.com")
+	user, err := s.dbClient.GetUserByEmail(ctx, "test@test.com")
+	s.Require().NoError(err)
+	s.Require().NoError(s.dbClient.AddOrganizationMember(ctx, org.ID, user.ID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s/organizations/%d/token", s.srvAddr, org.ID), http.NoBody)
+	s.Require().NoError(err)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	client := http.Client{}
+	response, err := client.Do(req)
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusOK, response.StatusCode)
+	orgToken := struct {
+		Token string
+	}{}
+	s.Require().NoError(json.NewDecoder(response.Body).Decode(&orgToken))
+	response.Body.Close()
+
+	testCases := []struct {
+		name         string
+		token        string
+		organization string
+		statusCode   int
+	}{
+		{
+			name:       "token of organization",
+			token:      orgToken.Token,
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "token of default organization",
+			token:      token,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "anonymous",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			// organization is selected by token only
+			name:         "anonymous with organization header",
+			organization: fmt.Sprint(org.ID),
+			statusCode:   http.StatusNotFound,
+		},
+		{
+			name:       "invalid token",
+			token:      "invalid",
+			statusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range testCases {
+		s.Run(tt.name, func() {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/companies/%v", s.srvAddr, id), http.NoBody)
+			s.NoError(err)
+
+			req.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tt.token))
+			}
+			if tt.organization != "" {
+				req.Header.Set("X-Organization-ID", tt.organization)
+			}
+
+			response, err := client.Do(req)
+			s.NoError(err)
+			s.Equal(tt.statusCode, response.StatusCode)
+			response.Body.Close()
+		})
+	}
+}
+
+func (s *e2eTestSuite) Test_EndToEnd_CompanyHistory() {
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
+
+	company := domain.Company{
+		Name:              "test-company",
+		Description:       "some description",
+		AmountOfEmployees: 123,
+		Registered:        true,
+		Type:              domain.Corporations,
+	}
+	id, err := s.dbClient.CreateCompany(ctx, company)
+	s.Require().NoError(err)
+	company.AmountOfEmployees = 456
+	err = s.dbClient.UpdateCompany(ctx, id, company)
+	s.Require().NoError(err)
+
+	token := s.signUp("test@test.com")
+	client := http.Client{}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/companies/%v/history", s.srvAddr, id), http.NoBody)
+	s.NoError(err)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	response, err := client.Do(req)
+	s.NoError(err)
+	s.Require().Equal(http.StatusOK, response.StatusCode)
+
+	var history []struct {
+		Action string
+		Before *struct {
+			AmountOfEmployees int `json:"amount_of_employees"`
+		}
+		After *struct {
+			AmountOfEmployees int `json:"amount_of_employees"`
+		}
+	}
+	err = json.NewDecoder(response.Body).Decode(&history)
+	s.NoError(err)
+	response.Body.Close()
+
+	s.Require().Len(history, 2)
+	s.Equal("create", history[0].Action)
+	s.Nil(history[0].Before)
+	s.Equal("update", history[1].Action)
+	s.Equal(123, history[1].Before.AmountOfEmployees)
+	s.Equal(456, history[1].After.AmountOfEmployees)
+}
+
+func (s *e2eTestSuite) Test_CompanyAsOfTimeZone() {
+	// sessions of client are pinned to UTC, default time zone of database does not shift times of history
+	db, err := sql.Open("pgx", s.dbConn)
+	s.Require().NoError(err)
+	defer db.Close()
+	_, err = db.Exec(`DO $$ BEGIN
+		EXECUTE format('ALTER DATABASE %I SET timezone TO ''Asia/Tokyo''', current_database());
+	END $$`)
+	s.Require().NoError(err)
+	defer func() {
+		_, err := db.Exec(`DO $$ BEGIN EXECUTE format('ALTER DATABASE %I RESET timezone', current_database()); END $$`)
+		s.NoError(err)
+	}()
+	client, err := postgres.NewPostgresClient(s.pgSettings)
+	s.Require().NoError(err)
+	defer client.Close()
+
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
+	newYork := time.FixedZone("UTC-5", -5*60*60)
+	beforeCreate := time.Now().In(newYork)
+	time.Sleep(50 * time.Millisecond)
+	company := domain.Company{
+		Name:              "test-company",
+		AmountOfEmployees: 123,
+		Registered:        true,
+		Type:              domain.Corporations,
+	}
+	id, err := client.CreateCompany(ctx, company)
+	s.Require().NoError(err)
+	time.Sleep(50 * time.Millisecond)
+	afterCreate := time.Now().In(newYork)
+	time.Sleep(50 * time.Millisecond)
+	company.AmountOfEmployees = 456
+	s.Require().NoError(client.UpdateCompany(ctx, id, company))
+
+	_, err = client.GetCompanyAsOf(ctx, id, beforeCreate)
+	s.ErrorIs(err, domain.ErrCompanyNotFound)
+	got, err := client.GetCompanyAsOf(ctx, id, afterCreate)
+	s.Require().NoError(err)
+	s.Equal(123, got.AmountOfEmployees)
+	got, err = client.GetCompanyAsOf(ctx, id, time.Now().In(newYork))
+	s.Require().NoError(err)
+	s.Equal(456, got.AmountOfEmployees)
+}
+
+func (s *e2eTestSuite) Test_EndToEnd_RestoreCompany() {
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
+
+	company := domain.Company{
+		Name:              "test-company",
+		Description:       "some description",
+		AmountOfEmployees: 123,
+		Registered:        true,
+		Type:              domain.Corporations,
+	}
+	id, err := s.dbClient.CreateCompany(ctx, company)
+	s.Require().NoError(err)
+	s.Require().NoError(s.dbClient.DeleteCompany(ctx, id))
+
+	token := s.signUp("test@test.com")
+	client := http.Client{}
+
+	testCases := []struct {
+		name       string
+		statusCode int
+	}{
+		{
+			name:       "deleted company",
+			statusCode: http.StatusOK,
+		},
+		{
+			name:       "not deleted company",
+			statusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range testCases {
+		s.Run(tt.name, func() {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s/companies/%v/restore", s.srvAddr, id), http.NoBody)
+			s.NoError(err)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+			response, err := client.Do(req)
+			s.NoError(err)
+			s.Equal(tt.statusCode, response.StatusCode)
+			response.Body.Close()
+		})
+	}
+
+	cmp, err := s.dbClient.GetCompany(ctx, id)
+	s.NoError(err)
+	company.ID = id
+	s.Equal(company, cmp)
+}
+
+func (s *e2eTestSuite) Test_EndToEnd_BatchCompanies() {
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
+
+	token := s.signUp("test@test.com")
+	client := http.Client{}
+
+	testCases := []struct {
+		name       string
+		mode       string
+		statusCode int
+		statuses   []int
+		companies  int
+	}{
+		{
+			name:       "atomic",
+			mode:       "atomic",
+			statusCode: http.StatusMultiStatus,
+			statuses:   []int{http.StatusFailedDependency, http.StatusNotFound},
+			companies:  0,
+		},
+		{
+			name:       "best effort",
+			mode:       "best_effort",
+			statusCode: http.StatusMultiStatus,
+			statuses:   []int{http.StatusCreated, http.StatusNotFound},
+			companies:  1,
+		},
+	}
+
+	for _, tt := range testCases {
+		s.Run(tt.name, func() {
+			body := fmt.Sprintf(`{
+				"mode": "%s",
+				"operations": [
+					{"op": "create", "company": {"name": "batch-company", "amount_of_employees": 1, "registered": true, "type": "NonProfit"}},
+					{"op": "delete", "id": "%v"}
+				]
+			}`, tt.mode, uuid.New())
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s/companies:batch", s.srvAddr), strings.NewReader(body))
+			s.NoError(err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+			response, err := client.Do(req)
+			s.NoError(err)
+			s.Require().Equal(tt.statusCode, response.StatusCode)
+
+			var result struct {
+				Results []struct {
+					Status int
+				}
+			}
+			err = json.NewDecoder(response.Body).Decode(&result)
+			s.NoError(err)
+			response.Body.Close()
+
+			s.Require().Len(result.Results, len(tt.statuses))
+			for i, status := range tt.statuses {
+				s.Equal(status, result.Results[i].Status)
+			}
+
+			companies, err := s.dbClient.SelectCompanies(ctx, domain.CompanyFilter{Limit: 10})
+			s.NoError(err)
+			s.Len(companies, tt.companies)
+		})
+	}
+}
+
+func (s *e2eTestSuite) Test_EndToEnd_ImportCompanies() {
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
+
+	_, err := s.dbClient.CreateCompany(ctx, domain.Company{
+		Name:              "existing",
+		AmountOfEmployees: 1,
+		Registered:        true,
+		Type:              domain.NonProfit,
+	})
+	s.NoError(err)
+
+	token := s.signUp("test@test.com")
+	client := http.Client{}
+
+	body := "name,amount_of_employees,registered,type\n" +
+		"existing,10,true,Cooperative\n" +
+		"imported,5,true,NonProfit\n" +
+		"bad,0,true,Unknown\n"
+
+	testCases := []struct {
+		name      string
+		dryRun    bool
+		created   int
+		updated   int
+		companies int
+	}{
+		{
+			name:      "dry run",
+			dryRun:    true,
+			companies: 1,
+		},
+		{
+			name:      "import",
+			created:   1,
+			updated:   1,
+			companies: 2,
+		},
+	}
+
+	for _, tt := range testCases {
+		s.Run(tt.name, func() {
+			url := fmt.Sprintf("http://%s/companies:import?dry_run=%t", s.srvAddr, tt.dryRun)
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(body))
+			s.NoError(err)
+			req.Header.Set("Content-Type", "text/csv")
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+			response, err := client.Do(req)
+			s.NoError(err)
+			s.Require().Equal(http.StatusOK, response.StatusCode)
+
+			var report rest.ImportReport
+			err = json.NewDecoder(response.Body).Decode(&report)
+			s.NoError(err)
+			response.Body.Close()
+
+			s.Equal(3, report.Rows)
+			s.Equal(2, report.Valid)
+			s.Equal(1, report.Invalid)
+			s.Require().Len(report.Errors, 1)
+			s.Equal(4, report.Errors[0].Row)
+			s.Equal(tt.created, report.Created)
+			s.Equal(tt.updated, report.Updated)
+
+			companies, err := s.dbClient.SelectCompanies(ctx, domain.CompanyFilter{Limit: 10})
+			s.NoError(err)
+			s.Len(companies, tt.companies)
+		})
+	}
+}
+
+func (s *e2eTestSuite) Test_EndToEnd_ExportCompanies() {
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
+	for _, name := range []string{"export-1", "export-2", "export-3"} {
+		_, err := s.dbClient.CreateCompany(ctx, domain.Company{
+			Name:              name,
+			AmountOfEmployees: 1,
+			Registered:        true,
+			Type:              domain.NonProfit,
+		})
+		s.NoError(err)
+	}
+
+	testCases := []struct {
+		name        string
+		query       string
+		contentType string
+		lines       int
+	}{
+		{
+			name:        "csv",
+			query:       "format=csv",
+			contentType: "text/csv",
+			lines:       4,
+		},
+		{
+			name:        "ndjson with limit",
+			query:       "format=ndjson&limit=2",
+			contentType: "application/x-ndjson",
+			lines:       2,
+		},
+	}
+
+	client := http.Client{}
+	for _, tt := range testCases {
+		s.Run(tt.name, func() {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/companies/export?%s", s.srvAddr, tt.query), nil)
+			s.NoError(err)
+
+			response, err := client.Do(req)
+			s.NoError(err)
+			s.Require().Equal(http.StatusOK, response.StatusCode)
+			s.Equal(tt.contentType, response.Header.Get("Content-Type"))
+			s.Contains(response.Header.Get("Content-Disposition"), "attachment")
+
+			body, err := io.ReadAll(response.Body)
+			s.NoError(err)
+			response.Body.Close()
+			s.Len(strings.Split(strings.TrimSpace(string(body)), "\n"), tt.lines)
+		})
+	}
+}
+
+func (s *e2eTestSuite) Test_EndToEnd_ExportJob() {
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
+	_, err := s.dbClient.CreateCompany(ctx, domain.Company{
+		Name:              "job-company",
+		AmountOfEmployees: 1,
+		Registered:        true,
+		Type:              domain.NonProfit,
+	})
+	s.NoError(err)
+
+	token := s.signUp("test@test.com")
+	client := http.Client{}
+
+	type job struct {
+		ID        uuid.UUID
+		Status    string
+		ResultURL string `json:"result_url"`
+	}
+	doJSON := func(method, path string, status int, result any) {
+		req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("http://%s%s", s.srvAddr, path), nil)
+		s.Require().NoError(err)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		response, err := client.Do(req)
+		s.Require().NoError(err)
+		defer response.Body.Close()
+		s.Require().Equal(status, response.StatusCode)
+		s.Require().NoError(json.NewDecoder(response.Body).Decode(result))
+	}
+
+	var created job
+	doJSON(http.MethodPost, "/jobs?type=export&format=ndjson", http.StatusAccepted, &created)
+	s.Equal("queued", created.Status)
+
+	var finished job
+	s.Require().Eventually(func() bool {
+		doJSON(http.MethodGet, fmt.Sprintf("/jobs/%s", created.ID), http.StatusOK, &finished)
+		return finished.Status == "succeeded"
+	}, 10*time.Second, 100*time.Millisecond)
+
+	var exported struct {
+		Name string
+	}
+	doJSON(http.MethodGet, finished.ResultURL, http.StatusOK, &exported)
+	s.Equal("job-company", exported.Name)
+}
+
+func (s *e2eTestSuite) Test_EndToEnd_IdempotencyKey() {
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
+
+	token := s.signUp("test@test.com")
+	client := http.Client{}
+
+	companyBody := `{"name": "idempotent", "amount_of_employees": 1, "registered": true, "type": "NonProfit"}`
+	otherBody := `{"name": "other", "amount_of_employees": 1, "registered": true, "type": "NonProfit"}`
+
+	testCases := []struct {
+		name       string
+		body       string
+		statusCode int
+		replayed   bool
+	}{
+		{
+			name:       "first request",
+			body:       companyBody,
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "retry",
+			body:       companyBody,
+			statusCode: http.StatusCreated,
+			replayed:   true,
+		},
+		{
+			name:       "key reused",
+			body:       otherBody,
+			statusCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	var firstResponse string
+	for _, tt := range testCases {
+		s.Run(tt.name, func() {
+			req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://%s/companies", s.srvAddr), strings.NewReader(tt.body))
+			s.NoError(err)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+			req.Header.Set("Idempotency-Key", "create-idempotent")
+
+			response, err := client.Do(req)
+			s.NoError(err)
+			body, err := io.ReadAll(response.Body)
+			s.NoError(err)
+			response.Body.Close()
+			s.Equal(tt.statusCode, response.StatusCode)
+			s.Equal(tt.replayed, response.Header.Get("Idempotent-Replayed") == "true")
+			if firstResponse == "" {
+				firstResponse = string(body)
+			} else if tt.replayed {
+				s.Equal(firstResponse, string(body))
+			}
+		})
+	}
+
+	companies, err := s.dbClient.SelectCompanies(ctx, domain.CompanyFilter{Limit: 10})
+	s.NoError(err)
+	s.Len(companies, 1)
+}
+
+func (s *e2eTestSuite) Test_IdempotencyKeyLease() {
+	ctx := context.Background()
+	s.signUp("test@test.com")
+	user, err := s.dbClient.GetUserByEmail(ctx, "test@test.com")
+	s.Require().NoError(err)
+	key := domain.IdempotencyKey{UserID: user.ID, Key: "lease", Fingerprint: "fingerprint"}
+
+	crashed, started, err := s.dbClient.StartIdempotencyKey(ctx, key, time.Hour, time.Hour)
+	s.NoError(err)
+	s.True(started)
+
+	_, started, err = s.dbClient.StartIdempotencyKey(ctx, key, time.Hour, time.Hour)
+	s.NoError(err)
+	s.False(started, "key of request in progress is not taken before lease")
+
+	time.Sleep(10 * time.Millisecond)
+	retry, started, err := s.dbClient.StartIdempotencyKey(ctx, key, time.Hour, time.Millisecond)
+	s.NoError(err)
+	s.True(started, "key of request in progress is taken after lease")
+
+	// response of request whose key was taken is not stored
+	crashed.Status = http.StatusCreated
+	s.NoError(s.dbClient.CompleteIdempotencyKey(ctx, crashed))
+	retry.Status = http.StatusOK
+	s.NoError(s.dbClient.CompleteIdempotencyKey(ctx, retry))
+	stored, started, err := s.dbClient.StartIdempotencyKey(ctx, key, time.Hour, time.Millisecond)
+	s.NoError(err)
+	s.False(started)
+	s.Equal(http.StatusOK, stored.Status)
+}
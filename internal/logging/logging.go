@@ -0,0 +1,34 @@
+// Package logging carries logger of request in context, so that log lines of every layer
+// have fields of request which caused them
+package logging
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type loggerKey struct{}
+
+// WithLogger returns context carrying logger
+func WithLogger(ctx context.Context, log *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, log)
+}
+
+// FromContext returns logger of context, global logger is returned if context carries none
+func FromContext(ctx context.Context) *zap.Logger {
+	return FromContextOr(ctx, zap.L())
+}
+
+// FromContextOr returns logger of context or fallback if context carries none
+func FromContextOr(ctx context.Context, fallback *zap.Logger) *zap.Logger {
+	if log, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return log
+	}
+	return fallback
+}
+
+// With returns context carrying logger of ctx with fields added
+func With(ctx context.Context, fields ...zap.Field) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(fields...))
+}
@@ -0,0 +1,25 @@
+package logging_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Ragnar-BY/companies-handler/internal/logging"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFromContext(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	ctx := logging.WithLogger(context.Background(), zap.New(core))
+	ctx = logging.With(ctx, zap.String("request_id", "id"))
+	ctx = logging.With(ctx, zap.Int64("user_id", 1))
+
+	logging.FromContext(ctx).Info("message")
+	require.Equal(t, 1, logs.Len())
+	require.Equal(t, map[string]any{"request_id": "id", "user_id": int64(1)}, logs.All()[0].ContextMap())
+
+	// global logger is used without logger in context
+	require.Same(t, zap.L(), logging.FromContext(context.Background()))
+}
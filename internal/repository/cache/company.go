@@ -0,0 +1,228 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/logging"
+	"github.com/Ragnar-BY/companies-handler/internal/service"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// Stats are counters of cache lookups
+type Stats struct {
+	Hits   uint64
+	Misses uint64
+	// Errors are failures of backend, repository is used on them
+	Errors uint64
+}
+
+type bypassKey struct{}
+
+const (
+	// allGenerationKey is key of generation of companies of all organizations
+	allGenerationKey = "companies:generation"
+	// loadTimeout limits load shared by callers, it is not limited by their deadlines
+	loadTimeout = 30 * time.Second
+)
+
+// detachedContext keeps values of parent context but not its cancellation and deadline
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+func (c detachedContext) Value(key any) any         { return c.parent.Value(key) }
+
+// CompanyCache is company repository caching reads of companies. Cached values are scoped to generation
+// of organization and to generation of all organizations, Invalidate starts new generation, so that all
+// cached companies of organization are dropped at once. Missing generation, e.g. evicted by backend, is
+// started again, so that values of previous generation are not used. Reads in transaction are not cached.
+type CompanyCache struct {
+	service.CompanyRepository
+
+	backend Backend
+	ttl     time.Duration
+	group   singleflight.Group
+	log     *zap.Logger
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	errors atomic.Uint64
+}
+
+// NewCompanyCache creates cache of repository, values are kept in backend for ttl
+func NewCompanyCache(repo service.CompanyRepository, backend Backend, ttl time.Duration, log *zap.Logger) *CompanyCache {
+	return &CompanyCache{
+		CompanyRepository: repo,
+		backend:           backend,
+		ttl:               ttl,
+		log:               log,
+	}
+}
+
+// GetCompany gets company by id
+func (c *CompanyCache) GetCompany(ctx context.Context, id uuid.UUID) (domain.Company, error) {
+	return cached(ctx, c, "get:"+id.String(), func(ctx context.Context) (domain.Company, error) {
+		return c.CompanyRepository.GetCompany(ctx, id)
+	})
+}
+
+// SelectCompanies selects companies by filter
+func (c *CompanyCache) SelectCompanies(ctx context.Context, filter domain.CompanyFilter) ([]domain.Company, error) {
+	name := fmt.Sprintf("select:%d:%d:%t", filter.Limit, filter.Offset, filter.IncludeDeleted)
+	return cached(ctx, c, name, func(ctx context.Context) ([]domain.Company, error) {
+		return c.CompanyRepository.SelectCompanies(ctx, filter)
+	})
+}
+
+// WithinTx runs fn in transaction, reads in fn bypass cache
+func (c *CompanyCache) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return c.CompanyRepository.WithinTx(ctx, func(ctx context.Context) error {
+		return fn(context.WithValue(ctx, bypassKey{}, true))
+	})
+}
+
+// PurgeCompanies permanently deletes companies of all organizations soft deleted before time
+// and drops cached companies of all organizations
+func (c *CompanyCache) PurgeCompanies(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	purger, ok := c.CompanyRepository.(service.CompanyPurger)
+	if !ok {
+		return 0, errors.New("repository can not purge companies")
+	}
+	n, err := purger.PurgeCompanies(ctx, deletedBefore)
+	if n > 0 {
+		if _, genErr := c.newGeneration(ctx, allGenerationKey); genErr != nil {
+			c.errors.Add(1)
+			logging.FromContextOr(ctx, c.log).Error("can not invalidate cache of all organizations", zap.Error(genErr))
+		}
+	}
+	return n, err
+}
+
+// Invalidate drops cached companies of organization of context
+func (c *CompanyCache) Invalidate(ctx context.Context) {
+	tenantID, ok := domain.TenantFromContext(ctx)
+	if !ok {
+		return
+	}
+	if _, err := c.newGeneration(ctx, generationKey(tenantID)); err != nil {
+		c.errors.Add(1)
+		logging.FromContextOr(ctx, c.log).Error("can not invalidate cache", zap.Int64("organization_id", tenantID), zap.Error(err))
+	}
+}
+
+// Stats returns counters of cache lookups
+func (c *CompanyCache) Stats() Stats {
+	return Stats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Errors: c.errors.Load(),
+	}
+}
+
+func generationKey(tenantID int64) string {
+	return fmt.Sprintf("companies:%d:generation", tenantID)
+}
+
+// newGeneration stores new random generation by key
+func (c *CompanyCache) newGeneration(ctx context.Context, key string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	generation := hex.EncodeToString(b)
+	if err := c.backend.Set(ctx, key, []byte(generation), 0); err != nil {
+		return "", err
+	}
+	return generation, nil
+}
+
+// generation returns current generation by key, missing generation is started
+func (c *CompanyCache) generation(ctx context.Context, key string) (string, error) {
+	generation, ok, err := c.backend.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return c.newGeneration(ctx, key)
+	}
+	return string(generation), nil
+}
+
+func (c *CompanyCache) backendError(ctx context.Context, err error) {
+	c.errors.Add(1)
+	logging.FromContextOr(ctx, c.log).Warn("cache backend failed", zap.Error(err))
+}
+
+// cached returns value cached by name or loads it. Concurrent loads of the same value are made once.
+func cached[T any](ctx context.Context, c *CompanyCache, name string, load func(ctx context.Context) (T, error)) (T, error) {
+	tenantID, ok := domain.TenantFromContext(ctx)
+	if !ok || ctx.Value(bypassKey{}) != nil {
+		return load(ctx)
+	}
+	allGeneration, err := c.generation(ctx, allGenerationKey)
+	if err != nil {
+		c.backendError(ctx, err)
+		return load(ctx)
+	}
+	generation, err := c.generation(ctx, generationKey(tenantID))
+	if err != nil {
+		c.backendError(ctx, err)
+		return load(ctx)
+	}
+	key := fmt.Sprintf("companies:%d:%s.%s:%s", tenantID, allGeneration, generation, name)
+
+	data, ok, err := c.backend.Get(ctx, key)
+	if err != nil {
+		c.backendError(ctx, err)
+	}
+	if ok {
+		var value T
+		if err = json.Unmarshal(data, &value); err == nil {
+			c.hits.Add(1)
+			return value, nil
+		}
+	}
+	c.misses.Add(1)
+
+	// load is shared by callers, so that it is not canceled when caller which started it goes away
+	result := c.group.DoChan(key, func() (any, error) {
+		loadCtx, cancel := context.WithTimeout(detachedContext{parent: ctx}, loadTimeout)
+		defer cancel()
+		// cached value is read from primary, so that value of lagging replica is not kept after invalidation
+		value, err := load(domain.WithPrimaryRead(loadCtx))
+		if err != nil {
+			return value, err
+		}
+		data, err := json.Marshal(value)
+		if err == nil {
+			err = c.backend.Set(loadCtx, key, data, c.ttl)
+		}
+		if err != nil {
+			c.backendError(loadCtx, err)
+		}
+		return value, nil
+	})
+	var zero T
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case res := <-result:
+		if res.Err != nil {
+			return zero, res.Err
+		}
+		return res.Val.(T), nil
+	}
+}
@@ -0,0 +1,241 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/cache"
+	"github.com/Ragnar-BY/companies-handler/internal/service"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// countingRepository counts reads of companies
+type countingRepository struct {
+	service.CompanyRepository
+
+	gets    atomic.Int64
+	primary atomic.Int64
+	delay   time.Duration
+}
+
+func (r *countingRepository) GetCompany(ctx context.Context, id uuid.UUID) (domain.Company, error) {
+	r.gets.Add(1)
+	if domain.PrimaryReadFromContext(ctx) {
+		r.primary.Add(1)
+	}
+	time.Sleep(r.delay)
+	if err := ctx.Err(); err != nil {
+		return domain.Company{}, err
+	}
+	return domain.Company{ID: id, Name: "cached"}, nil
+}
+
+func (r *countingRepository) PurgeCompanies(context.Context, time.Time) (int64, error) {
+	return 1, nil
+}
+
+// mapBackend is backend without eviction, values can be deleted to emulate eviction
+type mapBackend struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func (b *mapBackend) Get(_ context.Context, key string) ([]byte, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	value, ok := b.values[key]
+	return value, ok, nil
+}
+
+func (b *mapBackend) Set(_ context.Context, key string, value []byte, _ time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.values[key] = value
+	return nil
+}
+
+func (b *mapBackend) delete(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.values, key)
+}
+
+func (r *countingRepository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func TestCompanyCache(t *testing.T) {
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
+	id := uuid.New()
+
+	testCases := []struct {
+		name   string
+		read   func(c *cache.CompanyCache)
+		gets   int64
+		hits   uint64
+		misses uint64
+	}{
+		{
+			name: "hit after miss",
+			read: func(c *cache.CompanyCache) {
+				_, _ = c.GetCompany(ctx, id)
+				_, _ = c.GetCompany(ctx, id)
+			},
+			gets:   1,
+			hits:   1,
+			misses: 1,
+		},
+		{
+			name: "invalidate",
+			read: func(c *cache.CompanyCache) {
+				_, _ = c.GetCompany(ctx, id)
+				c.Invalidate(ctx)
+				_, _ = c.GetCompany(ctx, id)
+			},
+			gets:   2,
+			misses: 2,
+		},
+		{
+			name: "other organization",
+			read: func(c *cache.CompanyCache) {
+				_, _ = c.GetCompany(ctx, id)
+				_, _ = c.GetCompany(domain.WithTenant(context.Background(), 2), id)
+			},
+			gets:   2,
+			misses: 2,
+		},
+		{
+			name: "purge invalidates all organizations",
+			read: func(c *cache.CompanyCache) {
+				other := domain.WithTenant(context.Background(), 2)
+				_, _ = c.GetCompany(ctx, id)
+				_, _ = c.GetCompany(other, id)
+				_, _ = c.PurgeCompanies(context.Background(), time.Now())
+				_, _ = c.GetCompany(ctx, id)
+				_, _ = c.GetCompany(other, id)
+			},
+			gets:   4,
+			misses: 4,
+		},
+		{
+			name: "transaction bypasses cache",
+			read: func(c *cache.CompanyCache) {
+				_ = c.WithinTx(ctx, func(ctx context.Context) error {
+					_, _ = c.GetCompany(ctx, id)
+					_, _ = c.GetCompany(ctx, id)
+					return nil
+				})
+			},
+			gets: 2,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &countingRepository{}
+			c := cache.NewCompanyCache(repo, cache.NewLRU(10), time.Minute, zap.NewNop())
+			tt.read(c)
+			require.Equal(t, tt.gets, repo.gets.Load())
+			require.Equal(t, cache.Stats{Hits: tt.hits, Misses: tt.misses}, c.Stats())
+		})
+	}
+}
+
+func TestCompanyCache_SingleFlight(t *testing.T) {
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
+	repo := &countingRepository{delay: 50 * time.Millisecond}
+	c := cache.NewCompanyCache(repo, cache.NewLRU(10), time.Minute, zap.NewNop())
+	id := uuid.New()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmp, err := c.GetCompany(ctx, id)
+			require.NoError(t, err)
+			require.Equal(t, id, cmp.ID)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int64(1), repo.gets.Load())
+}
+
+func TestCompanyCache_CanceledCaller(t *testing.T) {
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
+	repo := &countingRepository{delay: 50 * time.Millisecond}
+	c := cache.NewCompanyCache(repo, cache.NewLRU(10), time.Minute, zap.NewNop())
+	id := uuid.New()
+
+	canceledCtx, cancel := context.WithCancel(ctx)
+	canceled := make(chan error, 1)
+	go func() {
+		_, err := c.GetCompany(canceledCtx, id)
+		canceled <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := c.GetCompany(ctx, id)
+		waiting <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	require.ErrorIs(t, <-canceled, context.Canceled)
+	// load started by canceled caller is finished for other callers and cached
+	require.NoError(t, <-waiting)
+	_, err := c.GetCompany(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), repo.gets.Load())
+}
+
+func TestCompanyCache_EvictedGeneration(t *testing.T) {
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
+	repo := &countingRepository{}
+	backend := &mapBackend{values: make(map[string][]byte)}
+	c := cache.NewCompanyCache(repo, backend, time.Minute, zap.NewNop())
+	id := uuid.New()
+
+	_, _ = c.GetCompany(ctx, id)
+	c.Invalidate(ctx)
+	_, _ = c.GetCompany(ctx, id)
+	// values of previous generations are not used again when generation is lost
+	backend.delete("companies:1:generation")
+	_, _ = c.GetCompany(ctx, id)
+	require.Equal(t, int64(3), repo.gets.Load())
+}
+
+func TestCompanyCache_LoadsFromPrimary(t *testing.T) {
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
+	repo := &countingRepository{}
+	c := cache.NewCompanyCache(repo, cache.NewLRU(10), time.Minute, zap.NewNop())
+	_, err := c.GetCompany(ctx, uuid.New())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), repo.primary.Load())
+}
+
+func TestLRU_Evicts(t *testing.T) {
+	ctx := context.Background()
+	lru := cache.NewLRU(2)
+	require.NoError(t, lru.Set(ctx, "a", []byte("1"), 0))
+	require.NoError(t, lru.Set(ctx, "b", []byte("2"), 0))
+	_, ok, _ := lru.Get(ctx, "a")
+	require.True(t, ok)
+	require.NoError(t, lru.Set(ctx, "c", []byte("3"), 0))
+
+	_, ok, _ = lru.Get(ctx, "b")
+	require.False(t, ok, "least recently used value is evicted")
+	_, ok, _ = lru.Get(ctx, "a")
+	require.True(t, ok)
+
+	require.NoError(t, lru.Set(ctx, "d", []byte("4"), time.Nanosecond))
+	time.Sleep(time.Millisecond)
+	_, ok, _ = lru.Get(ctx, "d")
+	require.False(t, ok, "expired value is dropped")
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is backend storing values in Redis, so that cache is shared by instances of server
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+// RedisSettings are settings of Redis backend
+type RedisSettings struct {
+	Addr     string
+	Password string
+	DB       int
+	// Prefix is prepended to all keys
+	Prefix string
+}
+
+// NewRedis creates Redis backend and checks connection
+func NewRedis(ctx context.Context, settings RedisSettings) (*Redis, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     settings.Addr,
+		Password: settings.Password,
+		DB:       settings.DB,
+	})
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return &Redis{client: client, prefix: settings.Prefix}, nil
+}
+
+// Get returns value by key
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set stores value by key
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, r.prefix+key, value, ttl).Err()
+}
+
+// Close closes connection to Redis
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
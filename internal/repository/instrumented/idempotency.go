@@ -0,0 +1,45 @@
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/service"
+)
+
+// IdempotencyRepository is repository of idempotency keys measuring latency of every call
+type IdempotencyRepository struct {
+	next    service.IdempotencyRepository
+	metrics *Metrics
+}
+
+// NewIdempotencyRepository wraps repository of idempotency keys with metrics
+func NewIdempotencyRepository(next service.IdempotencyRepository, metrics *Metrics) *IdempotencyRepository {
+	return &IdempotencyRepository{next: next, metrics: metrics}
+}
+
+func (r *IdempotencyRepository) StartIdempotencyKey(ctx context.Context, key domain.IdempotencyKey, ttl, lease time.Duration) (domain.IdempotencyKey, bool, error) {
+	start := time.Now()
+	stored, started, err := r.next.StartIdempotencyKey(ctx, key, ttl, lease)
+	r.metrics.observe("StartIdempotencyKey", start, err)
+	return stored, started, err
+}
+
+func (r *IdempotencyRepository) CompleteIdempotencyKey(ctx context.Context, key domain.IdempotencyKey) error {
+	return exec(r.metrics, "CompleteIdempotencyKey", func() error {
+		return r.next.CompleteIdempotencyKey(ctx, key)
+	})
+}
+
+func (r *IdempotencyRepository) DeleteIdempotencyKey(ctx context.Context, key domain.IdempotencyKey) error {
+	return exec(r.metrics, "DeleteIdempotencyKey", func() error {
+		return r.next.DeleteIdempotencyKey(ctx, key)
+	})
+}
+
+func (r *IdempotencyRepository) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	return call(r.metrics, "PurgeIdempotencyKeys", func() (int64, error) {
+		return r.next.PurgeIdempotencyKeys(ctx)
+	})
+}
@@ -0,0 +1,55 @@
+// Package instrumented wraps repositories to measure latency of their calls
+package instrumented
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics are metrics of repository calls
+type Metrics struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+// NewMetrics creates metrics of repository calls and registers them in reg
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "repository_query_duration_seconds",
+			Help:    "Latency of repository calls by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "repository_query_errors_total",
+			Help: "Number of failed repository calls by method, including not found errors.",
+		}, []string{"method"}),
+	}
+	reg.MustRegister(m.duration, m.errors)
+	return m
+}
+
+// observe records latency and error of call of method
+func (m *Metrics) observe(method string, start time.Time, err error) {
+	m.duration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(method).Inc()
+	}
+}
+
+// call runs fn and records its latency
+func call[T any](m *Metrics, method string, fn func() (T, error)) (T, error) {
+	start := time.Now()
+	v, err := fn()
+	m.observe(method, start, err)
+	return v, err
+}
+
+// exec runs fn without result and records its latency
+func exec(m *Metrics, method string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	m.observe(method, start, err)
+	return err
+}
@@ -0,0 +1,45 @@
+package instrumented_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/instrumented"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/memory"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/repotest"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		return instrumented.NewStorage(memory.NewRepository(), instrumented.NewMetrics(prometheus.NewRegistry()))
+	})
+}
+
+func TestStorageMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	repo := instrumented.NewStorage(memory.NewRepository(), instrumented.NewMetrics(reg))
+	ctx := domain.WithTenant(context.Background(), domain.DefaultOrganizationID)
+
+	id, err := repo.CreateCompany(ctx, domain.Company{
+		Name: "metrics", AmountOfEmployees: 1, Registered: true, Type: domain.Corporations,
+	})
+	require.NoError(t, err)
+	_, err = repo.GetCompany(ctx, id)
+	require.NoError(t, err)
+	_, err = repo.GetCompany(ctx, uuid.New())
+	require.ErrorIs(t, err, domain.ErrCompanyNotFound)
+
+	// latency is observed for every method, errors are counted by method
+	require.Equal(t, 2, testutil.CollectAndCount(reg, "repository_query_duration_seconds"))
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP repository_query_errors_total Number of failed repository calls by method, including not found errors.
+# TYPE repository_query_errors_total counter
+repository_query_errors_total{method="GetCompany"} 1
+`), "repository_query_errors_total"))
+}
@@ -0,0 +1,63 @@
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/service"
+	"github.com/google/uuid"
+)
+
+// JobRepository is job repository measuring latency of every call
+type JobRepository struct {
+	next    service.JobRepository
+	metrics *Metrics
+}
+
+// NewJobRepository wraps job repository with metrics
+func NewJobRepository(next service.JobRepository, metrics *Metrics) *JobRepository {
+	return &JobRepository{next: next, metrics: metrics}
+}
+
+func (r *JobRepository) CreateJob(ctx context.Context, job domain.Job) (domain.Job, error) {
+	return call(r.metrics, "CreateJob", func() (domain.Job, error) {
+		return r.next.CreateJob(ctx, job)
+	})
+}
+
+func (r *JobRepository) GetJob(ctx context.Context, id uuid.UUID) (domain.Job, error) {
+	return call(r.metrics, "GetJob", func() (domain.Job, error) {
+		return r.next.GetJob(ctx, id)
+	})
+}
+
+func (r *JobRepository) CancelJob(ctx context.Context, id uuid.UUID) (domain.Job, error) {
+	return call(r.metrics, "CancelJob", func() (domain.Job, error) {
+		return r.next.CancelJob(ctx, id)
+	})
+}
+
+func (r *JobRepository) ClaimJob(ctx context.Context, staleAfter time.Duration) (domain.Job, error) {
+	return call(r.metrics, "ClaimJob", func() (domain.Job, error) {
+		return r.next.ClaimJob(ctx, staleAfter)
+	})
+}
+
+func (r *JobRepository) HeartbeatJob(ctx context.Context, job domain.Job, progress int) (bool, error) {
+	return call(r.metrics, "HeartbeatJob", func() (bool, error) {
+		return r.next.HeartbeatJob(ctx, job, progress)
+	})
+}
+
+func (r *JobRepository) FinishJob(ctx context.Context, job domain.Job) error {
+	return exec(r.metrics, "FinishJob", func() error {
+		return r.next.FinishJob(ctx, job)
+	})
+}
+
+func (r *JobRepository) RetryJob(ctx context.Context, job domain.Job, delay time.Duration) error {
+	return exec(r.metrics, "RetryJob", func() error {
+		return r.next.RetryJob(ctx, job, delay)
+	})
+}
@@ -0,0 +1,198 @@
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/service"
+	"github.com/google/uuid"
+)
+
+// Repository is storage backend implementing repositories of companies, users and organizations
+type Repository interface {
+	service.CompanyRepository
+	service.UserRepository
+	service.OrganizationRepository
+	service.CompanyPurger
+	service.CompanyCounter
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+// Storage is storage backend measuring latency of every repository call
+type Storage struct {
+	next    Repository
+	metrics *Metrics
+}
+
+// NewStorage wraps storage backend with metrics
+func NewStorage(next Repository, metrics *Metrics) *Storage {
+	return &Storage{next: next, metrics: metrics}
+}
+
+// Ping pings storage, pings are not measured
+func (r *Storage) Ping(ctx context.Context) error {
+	return r.next.Ping(ctx)
+}
+
+// Close closes storage
+func (r *Storage) Close() error {
+	return r.next.Close()
+}
+
+func (r *Storage) CreateCompany(ctx context.Context, company domain.Company) (uuid.UUID, error) {
+	return call(r.metrics, "CreateCompany", func() (uuid.UUID, error) {
+		return r.next.CreateCompany(ctx, company)
+	})
+}
+
+func (r *Storage) GetCompany(ctx context.Context, id uuid.UUID) (domain.Company, error) {
+	return call(r.metrics, "GetCompany", func() (domain.Company, error) {
+		return r.next.GetCompany(ctx, id)
+	})
+}
+
+func (r *Storage) SelectCompanies(ctx context.Context, filter domain.CompanyFilter) ([]domain.Company, error) {
+	return call(r.metrics, "SelectCompanies", func() ([]domain.Company, error) {
+		return r.next.SelectCompanies(ctx, filter)
+	})
+}
+
+func (r *Storage) DeleteCompany(ctx context.Context, id uuid.UUID) error {
+	return exec(r.metrics, "DeleteCompany", func() error {
+		return r.next.DeleteCompany(ctx, id)
+	})
+}
+
+func (r *Storage) RestoreCompany(ctx context.Context, id uuid.UUID) error {
+	return exec(r.metrics, "RestoreCompany", func() error {
+		return r.next.RestoreCompany(ctx, id)
+	})
+}
+
+func (r *Storage) UpdateCompany(ctx context.Context, uuid uuid.UUID, company domain.Company) error {
+	return exec(r.metrics, "UpdateCompany", func() error {
+		return r.next.UpdateCompany(ctx, uuid, company)
+	})
+}
+
+func (r *Storage) GetCompanyAsOf(ctx context.Context, id uuid.UUID, asOf time.Time) (domain.Company, error) {
+	return call(r.metrics, "GetCompanyAsOf", func() (domain.Company, error) {
+		return r.next.GetCompanyAsOf(ctx, id, asOf)
+	})
+}
+
+func (r *Storage) SelectCompanyHistory(ctx context.Context, id uuid.UUID) ([]domain.CompanyChange, error) {
+	return call(r.metrics, "SelectCompanyHistory", func() ([]domain.CompanyChange, error) {
+		return r.next.SelectCompanyHistory(ctx, id)
+	})
+}
+
+func (r *Storage) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return exec(r.metrics, "WithinTx", func() error {
+		return r.next.WithinTx(ctx, fn)
+	})
+}
+
+func (r *Storage) ImportCompanies(ctx context.Context, src domain.CompanySource) (domain.ImportResult, error) {
+	return call(r.metrics, "ImportCompanies", func() (domain.ImportResult, error) {
+		return r.next.ImportCompanies(ctx, src)
+	})
+}
+
+func (r *Storage) ExportCompanies(ctx context.Context, filter domain.CompanyFilter, fn func(domain.Company) error) error {
+	return exec(r.metrics, "ExportCompanies", func() error {
+		return r.next.ExportCompanies(ctx, filter, fn)
+	})
+}
+
+func (r *Storage) CreateUser(ctx context.Context, u domain.User) (*domain.User, error) {
+	return call(r.metrics, "CreateUser", func() (*domain.User, error) {
+		return r.next.CreateUser(ctx, u)
+	})
+}
+
+func (r *Storage) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	return call(r.metrics, "GetUserByEmail", func() (*domain.User, error) {
+		return r.next.GetUserByEmail(ctx, email)
+	})
+}
+
+func (r *Storage) GetUserByID(ctx context.Context, id int64) (*domain.User, error) {
+	return call(r.metrics, "GetUserByID", func() (*domain.User, error) {
+		return r.next.GetUserByID(ctx, id)
+	})
+}
+
+func (r *Storage) UpdateUser(ctx context.Context, u domain.User) (*domain.User, error) {
+	return call(r.metrics, "UpdateUser", func() (*domain.User, error) {
+		return r.next.UpdateUser(ctx, u)
+	})
+}
+
+func (r *Storage) DeleteUser(ctx context.Context, id int64) error {
+	return exec(r.metrics, "DeleteUser", func() error {
+		return r.next.DeleteUser(ctx, id)
+	})
+}
+
+func (r *Storage) GetUserByPasswordResetToken(ctx context.Context, token string) (*domain.User, error) {
+	return call(r.metrics, "GetUserByPasswordResetToken", func() (*domain.User, error) {
+		return r.next.GetUserByPasswordResetToken(ctx, token)
+	})
+}
+
+func (r *Storage) SelectUsers(ctx context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	return call(r.metrics, "SelectUsers", func() ([]domain.User, error) {
+		return r.next.SelectUsers(ctx, filter)
+	})
+}
+
+func (r *Storage) CreateOrganization(ctx context.Context, name string) (domain.Organization, error) {
+	return call(r.metrics, "CreateOrganization", func() (domain.Organization, error) {
+		return r.next.CreateOrganization(ctx, name)
+	})
+}
+
+func (r *Storage) SelectOrganizations(ctx context.Context) ([]domain.Organization, error) {
+	return call(r.metrics, "SelectOrganizations", func() ([]domain.Organization, error) {
+		return r.next.SelectOrganizations(ctx)
+	})
+}
+
+func (r *Storage) SelectUserOrganizations(ctx context.Context, userID int64) ([]domain.Organization, error) {
+	return call(r.metrics, "SelectUserOrganizations", func() ([]domain.Organization, error) {
+		return r.next.SelectUserOrganizations(ctx, userID)
+	})
+}
+
+func (r *Storage) IsOrganizationMember(ctx context.Context, organizationID, userID int64) (bool, error) {
+	return call(r.metrics, "IsOrganizationMember", func() (bool, error) {
+		return r.next.IsOrganizationMember(ctx, organizationID, userID)
+	})
+}
+
+func (r *Storage) AddOrganizationMember(ctx context.Context, organizationID, userID int64) error {
+	return exec(r.metrics, "AddOrganizationMember", func() error {
+		return r.next.AddOrganizationMember(ctx, organizationID, userID)
+	})
+}
+
+func (r *Storage) RemoveOrganizationMember(ctx context.Context, organizationID, userID int64) error {
+	return exec(r.metrics, "RemoveOrganizationMember", func() error {
+		return r.next.RemoveOrganizationMember(ctx, organizationID, userID)
+	})
+}
+
+func (r *Storage) PurgeCompanies(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return call(r.metrics, "PurgeCompanies", func() (int64, error) {
+		return r.next.PurgeCompanies(ctx, deletedBefore)
+	})
+}
+
+func (r *Storage) CountCompaniesByType(ctx context.Context) (map[domain.CompanyType]int64, error) {
+	return call(r.metrics, "CountCompaniesByType", func() (map[domain.CompanyType]int64, error) {
+		return r.next.CountCompaniesByType(ctx)
+	})
+}
@@ -0,0 +1,356 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/google/uuid"
+)
+
+// snapshot returns state of company stored in history
+func snapshot(cmp company) *domain.Company {
+	s := cmp.Company
+	s.DeletedAt = nil
+	return &s
+}
+
+func copyCompany(cmp *domain.Company) *domain.Company {
+	if cmp == nil {
+		return nil
+	}
+	c := *cmp
+	return &c
+}
+
+// record appends change of company to company history
+func (s *state) record(ctx context.Context, action domain.CompanyAction, before, after *company) {
+	var cmp company
+	change := domain.CompanyChange{
+		Action:    action,
+		ActorID:   domain.ActorFromContext(ctx),
+		RequestID: domain.RequestIDFromContext(ctx),
+		CreatedAt: time.Now(),
+	}
+	if before != nil {
+		cmp = *before
+		change.Before = snapshot(*before)
+	}
+	if after != nil {
+		cmp = *after
+		change.After = snapshot(*after)
+	}
+	s.lastHistoryID++
+	change.ID = s.lastHistoryID
+	change.CompanyID = cmp.ID
+	s.history = append(s.history, historyEntry{CompanyChange: change, OrganizationID: cmp.OrganizationID})
+}
+
+// findByName returns not deleted company of organization with name
+func (s *state) findByName(tenantID int64, name string) (company, bool) {
+	id, ok := s.names[companyName{OrganizationID: tenantID, Name: name}]
+	if !ok {
+		return company{}, false
+	}
+	return s.companies[id], true
+}
+
+// put stores company and keeps index of names of not deleted companies
+func (s *state) put(cmp company) {
+	if old, ok := s.companies[cmp.ID]; ok && old.DeletedAt == nil {
+		delete(s.names, companyName{OrganizationID: old.OrganizationID, Name: old.Name})
+	}
+	s.companies[cmp.ID] = cmp
+	if cmp.DeletedAt == nil {
+		s.names[companyName{OrganizationID: cmp.OrganizationID, Name: cmp.Name}] = cmp.ID
+	}
+}
+
+// findCompany returns company of organization by id, deleted is whether soft deleted company is looked for
+func (s *state) findCompany(tenantID int64, id uuid.UUID, deleted bool) (company, bool) {
+	cmp, ok := s.companies[id]
+	if !ok || cmp.OrganizationID != tenantID || (cmp.DeletedAt != nil) != deleted {
+		return company{}, false
+	}
+	return cmp, true
+}
+
+// selectCompanies returns companies of organization matching filter ordered by creation,
+// limit 0 selects all companies
+func (s *state) selectCompanies(tenantID int64, filter domain.CompanyFilter) []domain.Company {
+	cmps := make([]company, 0)
+	for _, cmp := range s.companies {
+		if cmp.OrganizationID == tenantID && (filter.IncludeDeleted || cmp.DeletedAt == nil) {
+			cmps = append(cmps, cmp)
+		}
+	}
+	sort.Slice(cmps, func(i, j int) bool {
+		if !cmps[i].CreatedAt.Equal(cmps[j].CreatedAt) {
+			return cmps[i].CreatedAt.Before(cmps[j].CreatedAt)
+		}
+		return cmps[i].ID.String() < cmps[j].ID.String()
+	})
+	if filter.Offset >= len(cmps) {
+		return make([]domain.Company, 0)
+	}
+	cmps = cmps[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(cmps) {
+		cmps = cmps[:filter.Limit]
+	}
+	companies := make([]domain.Company, 0, len(cmps))
+	for _, cmp := range cmps {
+		companies = append(companies, cmp.Company)
+	}
+	return companies
+}
+
+// CreateCompany creates new company and records it in company history
+func (r *Repository) CreateCompany(ctx context.Context, cmp domain.Company) (uuid.UUID, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	defer r.lock(ctx)()
+	if _, ok := r.state.findByName(tenantID, cmp.Name); ok {
+		return uuid.Nil, fmt.Errorf("can not create company: %w", domain.ErrCompanyExists)
+	}
+	now := time.Now()
+	created := company{Company: cmp, OrganizationID: tenantID, CreatedAt: now, UpdatedAt: now}
+	created.ID = uuid.New()
+	created.DeletedAt = nil
+	r.state.put(created)
+	r.state.record(ctx, domain.CompanyCreated, nil, &created)
+	return created.ID, nil
+}
+
+// GetCompany gets company by id
+func (r *Repository) GetCompany(ctx context.Context, id uuid.UUID) (domain.Company, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return domain.Company{}, err
+	}
+	defer r.lock(ctx)()
+	cmp, ok := r.state.findCompany(tenantID, id, false)
+	if !ok {
+		return domain.Company{}, fmt.Errorf("can not get company: %w", domain.ErrCompanyNotFound)
+	}
+	return cmp.Company, nil
+}
+
+// SelectCompanies selects companies matching filter ordered by creation, soft deleted companies
+// are selected only if filter includes them
+func (r *Repository) SelectCompanies(ctx context.Context, filter domain.CompanyFilter) ([]domain.Company, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if filter.Limit <= 0 {
+		return make([]domain.Company, 0), nil
+	}
+	defer r.lock(ctx)()
+	return r.state.selectCompanies(tenantID, filter), nil
+}
+
+// DeleteCompany soft deletes company by id and records it in company history
+func (r *Repository) DeleteCompany(ctx context.Context, id uuid.UUID) error {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.lock(ctx)()
+	before, ok := r.state.findCompany(tenantID, id, false)
+	if !ok {
+		return domain.ErrCompanyNotFound
+	}
+	deleted := before
+	now := time.Now()
+	deleted.DeletedAt = &now
+	r.state.put(deleted)
+	r.state.record(ctx, domain.CompanyDeleted, &before, nil)
+	return nil
+}
+
+// RestoreCompany restores soft deleted company by id and records it in company history
+func (r *Repository) RestoreCompany(ctx context.Context, id uuid.UUID) error {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.lock(ctx)()
+	after, ok := r.state.findCompany(tenantID, id, true)
+	if !ok {
+		return domain.ErrCompanyNotFound
+	}
+	if _, ok := r.state.findByName(tenantID, after.Name); ok {
+		return fmt.Errorf("can not restore company: %w", domain.ErrCompanyExists)
+	}
+	after.DeletedAt = nil
+	after.UpdatedAt = time.Now()
+	r.state.put(after)
+	r.state.record(ctx, domain.CompanyRestored, nil, &after)
+	return nil
+}
+
+// UpdateCompany updates company by id and records it in company history
+func (r *Repository) UpdateCompany(ctx context.Context, id uuid.UUID, cmp domain.Company) error {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+	defer r.lock(ctx)()
+	before, ok := r.state.findCompany(tenantID, id, false)
+	if !ok {
+		return domain.ErrCompanyNotFound
+	}
+	if other, ok := r.state.findByName(tenantID, cmp.Name); ok && other.ID != id {
+		return fmt.Errorf("can not update company: %w", domain.ErrCompanyExists)
+	}
+	after := before
+	after.Company = cmp
+	after.ID = id
+	after.DeletedAt = nil
+	after.UpdatedAt = time.Now()
+	r.state.put(after)
+	r.state.record(ctx, domain.CompanyUpdated, &before, &after)
+	return nil
+}
+
+// PurgeCompanies permanently deletes companies of all organizations soft deleted before time
+func (r *Repository) PurgeCompanies(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	defer r.lock(ctx)()
+	var purged int64
+	for id, cmp := range r.state.companies {
+		if cmp.DeletedAt != nil && cmp.DeletedAt.Before(deletedBefore) {
+			delete(r.state.companies, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// CountCompaniesByType counts companies of all organizations which are not deleted by type
+func (r *Repository) CountCompaniesByType(ctx context.Context) (map[domain.CompanyType]int64, error) {
+	defer r.lock(ctx)()
+	counts := make(map[domain.CompanyType]int64)
+	for _, cmp := range r.state.companies {
+		if cmp.DeletedAt == nil {
+			counts[cmp.Type]++
+		}
+	}
+	return counts, nil
+}
+
+// GetCompanyAsOf gets state of company at moment of time from company history
+func (r *Repository) GetCompanyAsOf(ctx context.Context, id uuid.UUID, asOf time.Time) (domain.Company, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return domain.Company{}, err
+	}
+	defer r.lock(ctx)()
+	for i := len(r.state.history) - 1; i >= 0; i-- {
+		e := r.state.history[i]
+		if e.CompanyID != id || e.OrganizationID != tenantID || e.CreatedAt.After(asOf) {
+			continue
+		}
+		if e.After == nil {
+			break
+		}
+		return *e.After, nil
+	}
+	return domain.Company{}, domain.ErrCompanyNotFound
+}
+
+// SelectCompanyHistory selects history of company from oldest to newest change
+func (r *Repository) SelectCompanyHistory(ctx context.Context, id uuid.UUID) ([]domain.CompanyChange, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer r.lock(ctx)()
+	history := make([]domain.CompanyChange, 0)
+	for _, e := range r.state.history {
+		if e.CompanyID == id && e.OrganizationID == tenantID {
+			change := e.CompanyChange
+			change.Before = copyCompany(change.Before)
+			change.After = copyCompany(change.After)
+			history = append(history, change)
+		}
+	}
+	return history, nil
+}
+
+// ImportCompanies creates or updates companies read from source in one transaction.
+// Not deleted companies with the same name are updated, others are created.
+// If name repeats in source, the last company wins. Every change is recorded in company history.
+func (r *Repository) ImportCompanies(ctx context.Context, src domain.CompanySource) (domain.ImportResult, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return domain.ImportResult{}, err
+	}
+	var result domain.ImportResult
+	err = r.WithinTx(ctx, func(ctx context.Context) error {
+		imported := make(map[string]bool)
+		for src.Next() {
+			cmp := src.Company()
+			before, exists := r.state.findByName(tenantID, cmp.Name)
+			if !exists {
+				now := time.Now()
+				created := company{Company: cmp, OrganizationID: tenantID, CreatedAt: now, UpdatedAt: now}
+				created.ID = uuid.New()
+				created.DeletedAt = nil
+				r.state.put(created)
+				r.state.record(ctx, domain.CompanyCreated, nil, &created)
+			} else {
+				after := before
+				after.Company = cmp
+				after.ID = before.ID
+				after.DeletedAt = nil
+				after.UpdatedAt = time.Now()
+				r.state.put(after)
+				r.state.record(ctx, domain.CompanyUpdated, &before, &after)
+			}
+			// company created earlier in the same import is counted once
+			if _, ok := imported[cmp.Name]; !ok {
+				imported[cmp.Name] = exists
+			}
+		}
+		if err := src.Err(); err != nil {
+			return err
+		}
+		for _, existed := range imported {
+			if existed {
+				result.Updated++
+			} else {
+				result.Created++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.ImportResult{}, fmt.Errorf("can not import companies: %w", err)
+	}
+	return result, nil
+}
+
+// ExportCompanies passes companies matching filter ordered by creation to fn. Limit 0 exports all companies.
+// Export stops when fn returns error or context is cancelled.
+func (r *Repository) ExportCompanies(ctx context.Context, filter domain.CompanyFilter, fn func(domain.Company) error) error {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+	unlock := r.lock(ctx)
+	companies := r.state.selectCompanies(tenantID, filter)
+	unlock()
+	for _, cmp := range companies {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("can not export companies: %w", err)
+		}
+		if err := fn(cmp); err != nil {
+			return fmt.Errorf("can not export companies: %w", err)
+		}
+	}
+	return nil
+}
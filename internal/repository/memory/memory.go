@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/google/uuid"
+)
+
+// company is stored company with metadata which is not part of domain
+type company struct {
+	domain.Company
+	OrganizationID int64
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// historyEntry is entry of company history with organization it belongs to
+type historyEntry struct {
+	domain.CompanyChange
+	OrganizationID int64
+}
+
+// companyName is unique key of not deleted company
+type companyName struct {
+	OrganizationID int64
+	Name           string
+}
+
+type member struct {
+	OrganizationID int64
+	UserID         int64
+}
+
+// state is data of repository, it is copied to roll back transactions
+type state struct {
+	companies     map[uuid.UUID]company
+	names         map[companyName]uuid.UUID
+	history       []historyEntry
+	users         map[int64]domain.User
+	organizations map[int64]domain.Organization
+	members       map[member]struct{}
+
+	lastHistoryID      int64
+	lastUserID         int64
+	lastOrganizationID int64
+}
+
+func newState() *state {
+	return &state{
+		companies:     make(map[uuid.UUID]company),
+		names:         make(map[companyName]uuid.UUID),
+		users:         make(map[int64]domain.User),
+		organizations: make(map[int64]domain.Organization),
+		members:       make(map[member]struct{}),
+	}
+}
+
+// clone returns copy of state, companies of history are immutable, so they are shared
+func (s *state) clone() *state {
+	c := &state{
+		companies:          make(map[uuid.UUID]company, len(s.companies)),
+		names:              make(map[companyName]uuid.UUID, len(s.names)),
+		history:            append([]historyEntry(nil), s.history...),
+		users:              make(map[int64]domain.User, len(s.users)),
+		organizations:      make(map[int64]domain.Organization, len(s.organizations)),
+		members:            make(map[member]struct{}, len(s.members)),
+		lastHistoryID:      s.lastHistoryID,
+		lastUserID:         s.lastUserID,
+		lastOrganizationID: s.lastOrganizationID,
+	}
+	for id, cmp := range s.companies {
+		c.companies[id] = cmp
+	}
+	for name, id := range s.names {
+		c.names[name] = id
+	}
+	for id, u := range s.users {
+		c.users[id] = u
+	}
+	for id, org := range s.organizations {
+		c.organizations[id] = org
+	}
+	for m := range s.members {
+		c.members[m] = struct{}{}
+	}
+	return c
+}
+
+type txKey struct{}
+
+// Repository keeps companies, users and organizations in memory. It is safe for concurrent use,
+// transactions are serialized. Data is lost when process exits, so it is meant for tests and demos.
+type Repository struct {
+	mu    sync.Mutex
+	state *state
+}
+
+// NewRepository creates empty repository with default organization
+func NewRepository() *Repository {
+	s := newState()
+	s.organizations[domain.DefaultOrganizationID] = domain.Organization{ID: domain.DefaultOrganizationID, Name: "default"}
+	s.lastOrganizationID = domain.DefaultOrganizationID
+	return &Repository{state: s}
+}
+
+// Ping checks repository, it is always available
+func (r *Repository) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Close closes repository
+func (r *Repository) Close() error {
+	return nil
+}
+
+// WithinTx runs fn in transaction. Repository is locked until fn returns, changes made by fn
+// are discarded if it returns error.
+func (r *Repository) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if r.inTx(ctx) {
+		return fn(ctx)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	snapshot := r.state.clone()
+	err := fn(context.WithValue(ctx, txKey{}, r))
+	if err != nil {
+		r.state = snapshot
+	}
+	return err
+}
+
+func (r *Repository) inTx(ctx context.Context) bool {
+	return ctx.Value(txKey{}) == r
+}
+
+// lock locks repository unless context is in transaction which holds the lock already
+func (r *Repository) lock(ctx context.Context) func() {
+	if r.inTx(ctx) {
+		return func() {}
+	}
+	r.mu.Lock()
+	return r.mu.Unlock
+}
+
+// tenant returns organization every company query must be scoped to
+func tenant(ctx context.Context) (int64, error) {
+	id, ok := domain.TenantFromContext(ctx)
+	if !ok {
+		return 0, domain.ErrNoTenant
+	}
+	return id, nil
+}
@@ -0,0 +1,14 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/Ragnar-BY/companies-handler/internal/repository/memory"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/repotest"
+)
+
+func TestRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		return memory.NewRepository()
+	})
+}
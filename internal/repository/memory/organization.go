@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+)
+
+// CreateOrganization creates new organization
+func (r *Repository) CreateOrganization(ctx context.Context, name string) (domain.Organization, error) {
+	defer r.lock(ctx)()
+	for _, org := range r.state.organizations {
+		if org.Name == name {
+			return domain.Organization{}, fmt.Errorf("can not create organization: %w", domain.ErrOrganizationExists)
+		}
+	}
+	r.state.lastOrganizationID++
+	org := domain.Organization{ID: r.state.lastOrganizationID, Name: name}
+	r.state.organizations[org.ID] = org
+	return org, nil
+}
+
+// SelectOrganizations selects all organizations
+func (r *Repository) SelectOrganizations(ctx context.Context) ([]domain.Organization, error) {
+	defer r.lock(ctx)()
+	return r.state.selectOrganizations(func(domain.Organization) bool { return true }), nil
+}
+
+// SelectUserOrganizations selects organizations user is member of
+func (r *Repository) SelectUserOrganizations(ctx context.Context, userID int64) ([]domain.Organization, error) {
+	defer r.lock(ctx)()
+	return r.state.selectOrganizations(func(org domain.Organization) bool {
+		_, ok := r.state.members[member{OrganizationID: org.ID, UserID: userID}]
+		return ok
+	}), nil
+}
+
+// IsOrganizationMember checks if user is member of organization
+func (r *Repository) IsOrganizationMember(ctx context.Context, organizationID, userID int64) (bool, error) {
+	defer r.lock(ctx)()
+	_, ok := r.state.members[member{OrganizationID: organizationID, UserID: userID}]
+	return ok, nil
+}
+
+// AddOrganizationMember adds user to organization
+func (r *Repository) AddOrganizationMember(ctx context.Context, organizationID, userID int64) error {
+	defer r.lock(ctx)()
+	if _, ok := r.state.organizations[organizationID]; !ok {
+		return fmt.Errorf("can not add organization member: organization %d does not exist", organizationID)
+	}
+	if _, ok := r.state.users[userID]; !ok {
+		return fmt.Errorf("can not add organization member: %w", domain.ErrUserNotFound)
+	}
+	r.state.members[member{OrganizationID: organizationID, UserID: userID}] = struct{}{}
+	return nil
+}
+
+// RemoveOrganizationMember removes user from organization
+func (r *Repository) RemoveOrganizationMember(ctx context.Context, organizationID, userID int64) error {
+	defer r.lock(ctx)()
+	m := member{OrganizationID: organizationID, UserID: userID}
+	if _, ok := r.state.members[m]; !ok {
+		return domain.ErrNotOrganizationMember
+	}
+	delete(r.state.members, m)
+	return nil
+}
+
+// selectOrganizations returns organizations matching filter ordered by id
+func (s *state) selectOrganizations(match func(org domain.Organization) bool) []domain.Organization {
+	orgs := make([]domain.Organization, 0)
+	for _, org := range s.organizations {
+		if match(org) {
+			orgs = append(orgs, org)
+		}
+	}
+	sort.Slice(orgs, func(i, j int) bool {
+		return orgs[i].ID < orgs[j].ID
+	})
+	return orgs
+}
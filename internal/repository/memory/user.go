@@ -0,0 +1,163 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+)
+
+// conflicts checks if another user has the same username, email, oidc subject or password reset token
+func (s *state) conflicts(u domain.User) bool {
+	for _, other := range s.users {
+		if other.ID == u.ID {
+			continue
+		}
+		if other.Username == u.Username || other.Email == u.Email ||
+			(u.OIDCSubject != "" && other.OIDCSubject == u.OIDCSubject) ||
+			(u.PasswordResetToken != "" && other.PasswordResetToken == u.PasswordResetToken) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *state) findUser(match func(u domain.User) bool) (*domain.User, bool) {
+	for _, u := range s.users {
+		if match(u) {
+			return &u, true
+		}
+	}
+	return nil, false
+}
+
+// CreateUser creates new user and adds it to default organization.
+// Users from identity provider are provisioned just in time: if user with the same
+// oidc subject already exists, its email is refreshed from provider. Role is kept, it is mapped
+// from provider groups only for new user and is managed by admins afterwards.
+func (r *Repository) CreateUser(ctx context.Context, u domain.User) (*domain.User, error) {
+	defer r.lock(ctx)()
+	if u.Role == "" {
+		u.Role = domain.RoleUser
+	}
+	u.OrganizationID = 0
+	if u.OIDCSubject != "" {
+		if existing, ok := r.state.findUser(func(other domain.User) bool {
+			return other.OIDCSubject == u.OIDCSubject
+		}); ok {
+			if _, taken := r.state.findUser(func(other domain.User) bool {
+				return other.ID != existing.ID && other.Email == u.Email
+			}); taken {
+				return nil, fmt.Errorf("can not create user: %w", domain.ErrUserExists)
+			}
+			existing.Email = u.Email
+			r.state.users[existing.ID] = *existing
+			return existing, nil
+		}
+	}
+	u.ID = 0
+	if r.state.conflicts(u) {
+		return nil, fmt.Errorf("can not create user: %w", domain.ErrUserExists)
+	}
+	r.state.lastUserID++
+	u.ID = r.state.lastUserID
+	r.state.users[u.ID] = u
+	r.state.members[member{OrganizationID: domain.DefaultOrganizationID, UserID: u.ID}] = struct{}{}
+	return &u, nil
+}
+
+// GetUserByEmail gets user by email
+func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	defer r.lock(ctx)()
+	u, ok := r.state.findUser(func(u domain.User) bool {
+		return u.Email == email
+	})
+	if !ok {
+		return nil, fmt.Errorf("can not get user by email: %w", domain.ErrUserNotFound)
+	}
+	return u, nil
+}
+
+// GetUserByID gets user by id
+func (r *Repository) GetUserByID(ctx context.Context, id int64) (*domain.User, error) {
+	defer r.lock(ctx)()
+	u, ok := r.state.users[id]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	return &u, nil
+}
+
+// GetUserByPasswordResetToken gets user by hash of password reset token
+func (r *Repository) GetUserByPasswordResetToken(ctx context.Context, token string) (*domain.User, error) {
+	defer r.lock(ctx)()
+	u, ok := r.state.findUser(func(u domain.User) bool {
+		return token != "" && u.PasswordResetToken == token
+	})
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	return u, nil
+}
+
+// SelectUsers selects users matching filter ordered by id
+func (r *Repository) SelectUsers(ctx context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	defer r.lock(ctx)()
+	query := strings.ToLower(filter.Query)
+	users := make([]domain.User, 0)
+	for id := int64(1); id <= r.state.lastUserID; id++ {
+		u, ok := r.state.users[id]
+		if !ok {
+			continue
+		}
+		if query != "" && !strings.Contains(strings.ToLower(u.Username), query) &&
+			!strings.Contains(strings.ToLower(u.Email), query) {
+			continue
+		}
+		users = append(users, u)
+	}
+	if filter.Offset >= len(users) {
+		return make([]domain.User, 0), nil
+	}
+	users = users[filter.Offset:]
+	if filter.Limit < len(users) {
+		users = users[:filter.Limit]
+	}
+	return users, nil
+}
+
+// UpdateUser updates user by id
+func (r *Repository) UpdateUser(ctx context.Context, u domain.User) (*domain.User, error) {
+	defer r.lock(ctx)()
+	existing, ok := r.state.users[u.ID]
+	if !ok {
+		return nil, domain.ErrUserNotFound
+	}
+	// oidc subject is set only when user is provisioned
+	u.OIDCSubject = existing.OIDCSubject
+	u.OrganizationID = 0
+	if u.Role == "" {
+		u.Role = domain.RoleUser
+	}
+	if r.state.conflicts(u) {
+		return nil, fmt.Errorf("can not update user: %w", domain.ErrUserExists)
+	}
+	r.state.users[u.ID] = u
+	return &u, nil
+}
+
+// DeleteUser deletes user by id
+func (r *Repository) DeleteUser(ctx context.Context, id int64) error {
+	defer r.lock(ctx)()
+	if _, ok := r.state.users[id]; !ok {
+		return domain.ErrUserNotFound
+	}
+	delete(r.state.users, id)
+	for m := range r.state.members {
+		if m.UserID == id {
+			delete(r.state.members, m)
+		}
+	}
+	return nil
+}
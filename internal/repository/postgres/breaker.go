@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+)
+
+// breakerState is state of circuit breaker
+type breakerState string
+
+const (
+	// breakerClosed lets connections through
+	breakerClosed breakerState = "closed"
+	// breakerOpen fails connections fast until cooldown passes
+	breakerOpen breakerState = "open"
+	// breakerHalfOpen lets one trial connection through after cooldown
+	breakerHalfOpen breakerState = "half-open"
+)
+
+// BreakerStatus is state of circuit breaker of database connections
+type BreakerStatus struct {
+	State    string    `json:"state"`
+	Failures int       `json:"failures"`
+	OpenedAt time.Time `json:"opened_at,omitempty"`
+}
+
+// breaker is circuit breaker of database connections. It opens after threshold of consecutive
+// failed connections, while it is open connections fail fast with domain.ErrUnavailable.
+// After cooldown one trial connection is let through, breaker closes if it succeeds.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		state:     breakerClosed,
+	}
+}
+
+// allow returns error if connection must fail fast
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return domain.ErrUnavailable
+		}
+		b.state = breakerHalfOpen
+		return nil
+	case breakerHalfOpen:
+		// trial connection is in progress
+		return domain.ErrUnavailable
+	}
+	return nil
+}
+
+// record records result of connection let through. Connections canceled by caller do not say
+// anything about database, they only release trial of half-open breaker.
+func (b *breaker) record(err error, canceled bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case err == nil:
+		b.state = breakerClosed
+		b.failures = 0
+	case canceled:
+		if b.state == breakerHalfOpen {
+			b.state = breakerOpen
+		}
+	default:
+		b.failures++
+		if b.state == breakerHalfOpen || b.failures >= b.threshold {
+			b.state = breakerOpen
+			b.openedAt = b.now()
+		}
+	}
+}
+
+// available returns domain.ErrUnavailable if breaker is open and fails connections
+func (b *breaker) available() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerOpen && b.now().Sub(b.openedAt) < b.cooldown {
+		return domain.ErrUnavailable
+	}
+	return nil
+}
+
+func (b *breaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	status := BreakerStatus{State: string(b.state), Failures: b.failures}
+	if b.state != breakerClosed {
+		status.OpenedAt = b.openedAt
+	}
+	return status
+}
+
+// breakerConnector opens connections of pool through circuit breaker
+type breakerConnector struct {
+	driver.Connector
+	breaker *breaker
+}
+
+// Connect opens connection unless breaker is open
+func (c breakerConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	conn, err := c.Connector.Connect(ctx)
+	c.breaker.record(err, err != nil && ctx.Err() != nil)
+	return conn, err
+}
@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := newBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+	errConnect := errors.New("connection refused")
+
+	// breaker opens after threshold of consecutive failures only
+	require.NoError(t, b.allow())
+	b.record(errConnect, false)
+	require.NoError(t, b.allow())
+	b.record(nil, false)
+	require.NoError(t, b.allow())
+	b.record(errConnect, false)
+	require.NoError(t, b.available())
+	require.NoError(t, b.allow())
+	b.record(errConnect, false)
+	require.ErrorIs(t, b.allow(), domain.ErrUnavailable)
+	require.ErrorIs(t, b.available(), domain.ErrUnavailable)
+	require.Equal(t, BreakerStatus{State: "open", Failures: 2, OpenedAt: now}, b.status())
+
+	// after cooldown one trial connection is let through, failed trial opens breaker again
+	now = now.Add(time.Minute)
+	require.NoError(t, b.available())
+	require.NoError(t, b.allow())
+	require.ErrorIs(t, b.allow(), domain.ErrUnavailable)
+	b.record(errConnect, false)
+	require.ErrorIs(t, b.allow(), domain.ErrUnavailable)
+
+	// canceled trial does not reset cooldown
+	now = now.Add(time.Minute)
+	require.NoError(t, b.allow())
+	b.record(context.Canceled, true)
+	require.NoError(t, b.allow())
+
+	// successful trial closes breaker
+	b.record(nil, false)
+	require.NoError(t, b.allow())
+	require.Equal(t, BreakerStatus{State: "closed"}, b.status())
+}
+
+func TestConnectRetries(t *testing.T) {
+	// nothing listens on closed port, so every attempt fails
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	var delays []time.Duration
+	_, err = Connect(ctx, PostgresSettings{
+		Addr:              addr,
+		SSLMode:           "disable",
+		ConnectBackoff:    10 * time.Millisecond,
+		MaxConnectBackoff: 40 * time.Millisecond,
+	}, func(err error, delay time.Duration) {
+		delays = append(delays, delay)
+	})
+	require.Error(t, err)
+	require.GreaterOrEqual(t, len(delays), 4)
+	require.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 40 * time.Millisecond}, delays[:4])
+}
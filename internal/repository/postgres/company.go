@@ -2,22 +2,107 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/Ragnar-BY/companies-handler/internal/domain"
 	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
 type company struct {
-	ID                uuid.UUID `db:"id"`
-	Name              string    `db:"name"`
-	Description       string    `db:"description"`
-	AmountOfEmployees int       `db:"amount_of_employees"`
-	Registered        bool      `db:"registered"`
-	Type              string    `db:"type"`
-	CreatedAt         time.Time `db:"created_at"`
-	UpdatedAt         time.Time `db:"updated_at"`
+	ID                uuid.UUID    `db:"id"`
+	Name              string       `db:"name"`
+	Description       string       `db:"description"`
+	AmountOfEmployees int          `db:"amount_of_employees"`
+	Registered        bool         `db:"registered"`
+	Type              string       `db:"type"`
+	OrganizationID    int64        `db:"organization_id"`
+	CreatedAt         time.Time    `db:"created_at"`
+	UpdatedAt         time.Time    `db:"updated_at"`
+	DeletedAt         sql.NullTime `db:"deleted_at"`
+}
+
+// companyChange is company with metadata of request changing it
+type companyChange struct {
+	company
+	ActorID   sql.NullInt64  `db:"actor_id"`
+	RequestID sql.NullString `db:"request_id"`
+}
+
+// companyHistory is entry of company history
+type companyHistory struct {
+	ID             int64
+	CompanyID      uuid.UUID      `db:"company_id"`
+	OrganizationID int64          `db:"organization_id"`
+	Action         string         `db:"action"`
+	Before         []byte         `db:"before"`
+	After          []byte         `db:"after"`
+	ActorID        sql.NullInt64  `db:"actor_id"`
+	RequestID      sql.NullString `db:"request_id"`
+	CreatedAt      time.Time      `db:"created_at"`
+}
+
+// companySnapshot is company row stored in history
+type companySnapshot struct {
+	ID                uuid.UUID `json:"id"`
+	Name              string    `json:"name"`
+	Description       string    `json:"description"`
+	AmountOfEmployees int       `json:"amount_of_employees"`
+	Registered        bool      `json:"registered"`
+	Type              string    `json:"type"`
+}
+
+func newCompanyChange(ctx context.Context, cmp company) companyChange {
+	actorID := domain.ActorFromContext(ctx)
+	requestID := domain.RequestIDFromContext(ctx)
+	return companyChange{
+		company:   cmp,
+		ActorID:   sql.NullInt64{Int64: actorID, Valid: actorID != 0},
+		RequestID: sql.NullString{String: requestID, Valid: requestID != ""},
+	}
+}
+
+func snapshotToDomain(data []byte) (*domain.Company, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var snapshot companySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	return &domain.Company{
+		ID:                snapshot.ID,
+		Name:              snapshot.Name,
+		Description:       snapshot.Description,
+		AmountOfEmployees: snapshot.AmountOfEmployees,
+		Registered:        snapshot.Registered,
+		Type:              domain.CompanyType(snapshot.Type),
+	}, nil
+}
+
+func (h companyHistory) toDomain() (domain.CompanyChange, error) {
+	before, err := snapshotToDomain(h.Before)
+	if err != nil {
+		return domain.CompanyChange{}, err
+	}
+	after, err := snapshotToDomain(h.After)
+	if err != nil {
+		return domain.CompanyChange{}, err
+	}
+	return domain.CompanyChange{
+		ID:        h.ID,
+		CompanyID: h.CompanyID,
+		Action:    domain.CompanyAction(h.Action),
+		Before:    before,
+		After:     after,
+		ActorID:   h.ActorID.Int64,
+		RequestID: h.RequestID.String,
+		CreatedAt: h.CreatedAt,
+	}, nil
 }
 
 func fromDomain(c domain.Company) company {
@@ -32,7 +117,7 @@ func fromDomain(c domain.Company) company {
 }
 
 func toDomain(c company) domain.Company {
-	return domain.Company{
+	cmp := domain.Company{
 		ID:                c.ID,
 		Name:              c.Name,
 		Description:       c.Description,
@@ -40,38 +125,71 @@ func toDomain(c company) domain.Company {
 		Registered:        c.Registered,
 		Type:              domain.CompanyType(c.Type),
 	}
+	if c.DeletedAt.Valid {
+		cmp.DeletedAt = &c.DeletedAt.Time
+	}
+	return cmp
 }
 
-// CreateCompany created new company in database
+// CreateCompany created new company in database and records it in company history
 func (c *PostgresClient) CreateCompany(ctx context.Context, company domain.Company) (uuid.UUID, error) {
-	cmp := fromDomain(company)
-	var id uuid.UUID
-	stmt, err := c.db.PrepareNamedContext(ctx, `INSERT INTO companies( name,description, amount_of_employees, registered,type) 
-	VALUES (:name, :description, :amount_of_employees, :registered, :type) RETURNING id `)
+	defer c.replicas.wrote()
+	tenantID, err := tenant(ctx)
 	if err != nil {
 		return uuid.Nil, err
 	}
-	err = stmt.GetContext(ctx, &id, cmp)
+	cmp := fromDomain(company)
+	cmp.OrganizationID = tenantID
+	var id uuid.UUID
+	err = namedGet(ctx, c.conn(ctx), &id, `WITH after AS (
+		INSERT INTO companies( name,description, amount_of_employees, registered,type, organization_id) 
+		VALUES (:name, :description, :amount_of_employees, :registered, :type, :organization_id) RETURNING *
+	), history AS (
+		INSERT INTO company_history (company_id, organization_id, action, after, actor_id, request_id)
+		SELECT id, organization_id, 'create', to_jsonb(after), :actor_id, :request_id FROM after
+	)
+	SELECT id FROM after`, newCompanyChange(ctx, cmp))
+	if isUniqueViolation(err) {
+		err = domain.ErrCompanyExists
+	}
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("can not create company: %w", err)
 	}
 	return id, nil
 }
 
-// GetCompany gets company from DB by id
+// GetCompany gets company from DB by id, it is read from replica if replicas are configured
 func (c *PostgresClient) GetCompany(ctx context.Context, id uuid.UUID) (domain.Company, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return domain.Company{}, err
+	}
 	var cmp company
-	err := c.db.GetContext(ctx, &cmp, "SELECT * FROM companies WHERE id=$1", id)
+	err = c.read(ctx, func(q sqlx.ExtContext) error {
+		return sqlx.GetContext(ctx, q, &cmp, "SELECT * FROM companies WHERE id=$1 AND organization_id=$2 AND deleted_at IS NULL", id, tenantID)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		err = domain.ErrCompanyNotFound
+	}
 	if err != nil {
 		return domain.Company{}, fmt.Errorf("can not get company: %w", err)
 	}
 	return toDomain(cmp), nil
 }
 
-// SelectCompanies selects all companies from database
-func (c *PostgresClient) SelectCompanies(ctx context.Context, limit, offset int) ([]domain.Company, error) {
+// SelectCompanies selects companies matching filter from database ordered by creation, soft deleted companies
+// are selected only if filter includes them. Companies are read from replica if replicas are configured.
+func (c *PostgresClient) SelectCompanies(ctx context.Context, filter domain.CompanyFilter) ([]domain.Company, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
 	var cmps []company
-	err := c.db.SelectContext(ctx, &cmps, "SELECT * FROM companies LIMIT $1 OFFSET $2", limit, offset)
+	err = c.read(ctx, func(q sqlx.ExtContext) error {
+		cmps = nil
+		return sqlx.SelectContext(ctx, q, &cmps, `SELECT * FROM companies WHERE organization_id=$1 AND ($2 OR deleted_at IS NULL)
+		ORDER BY created_at, id LIMIT $3 OFFSET $4`, tenantID, filter.IncludeDeleted, filter.Limit, filter.Offset)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("can not select companies: %w", err)
 	}
@@ -82,26 +200,165 @@ func (c *PostgresClient) SelectCompanies(ctx context.Context, limit, offset int)
 	return companies, nil
 }
 
-// DeleteCompany deletes company from DB by id
+// DeleteCompany soft deletes company by id and records it in company history
 func (c *PostgresClient) DeleteCompany(ctx context.Context, id uuid.UUID) error {
-	_, err := c.db.ExecContext(ctx, "DELETE FROM companies WHERE id=$1", id)
+	defer c.replicas.wrote()
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+	change := newCompanyChange(ctx, company{})
+	res, err := c.conn(ctx).ExecContext(ctx, `WITH before AS (
+		SELECT * FROM companies WHERE id=$1 AND organization_id=$2 AND deleted_at IS NULL FOR UPDATE
+	), deleted AS (
+		UPDATE companies SET deleted_at=NOW() WHERE id=$1 AND organization_id=$2 AND deleted_at IS NULL RETURNING id
+	)
+	INSERT INTO company_history (company_id, organization_id, action, before, actor_id, request_id)
+	SELECT before.id, before.organization_id, 'delete', to_jsonb(before), $3, $4 FROM before, deleted`,
+		id, tenantID, change.ActorID, change.RequestID)
 	if err != nil {
 		return fmt.Errorf("can not delete company: %w", err)
 	}
+	return checkCompanyAffected(res)
+}
+
+// RestoreCompany restores soft deleted company by id and records it in company history
+func (c *PostgresClient) RestoreCompany(ctx context.Context, id uuid.UUID) error {
+	defer c.replicas.wrote()
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+	change := newCompanyChange(ctx, company{})
+	res, err := c.conn(ctx).ExecContext(ctx, `WITH after AS (
+		UPDATE companies SET deleted_at=NULL, updated_at=NOW()
+		WHERE id=$1 AND organization_id=$2 AND deleted_at IS NOT NULL RETURNING *
+	)
+	INSERT INTO company_history (company_id, organization_id, action, after, actor_id, request_id)
+	SELECT id, organization_id, 'restore', to_jsonb(after), $3, $4 FROM after`,
+		id, tenantID, change.ActorID, change.RequestID)
+	if isUniqueViolation(err) {
+		err = domain.ErrCompanyExists
+	}
+	if err != nil {
+		return fmt.Errorf("can not restore company: %w", err)
+	}
+	return checkCompanyAffected(res)
+}
+
+// PurgeCompanies permanently deletes companies of all organizations soft deleted before time
+func (c *PostgresClient) PurgeCompanies(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	defer c.replicas.wrote()
+	res, err := c.conn(ctx).ExecContext(ctx, "DELETE FROM companies WHERE deleted_at < $1", deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("can not purge companies: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// CountCompaniesByType counts companies of all organizations which are not deleted by type
+func (c *PostgresClient) CountCompaniesByType(ctx context.Context) (map[domain.CompanyType]int64, error) {
+	var rows []struct {
+		Type  string `db:"type"`
+		Count int64  `db:"count"`
+	}
+	err := c.read(ctx, func(q sqlx.ExtContext) error {
+		rows = nil
+		return sqlx.SelectContext(ctx, q, &rows, "SELECT type, COUNT(*) AS count FROM companies WHERE deleted_at IS NULL GROUP BY type")
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can not count companies: %w", err)
+	}
+	counts := make(map[domain.CompanyType]int64, len(rows))
+	for _, r := range rows {
+		counts[domain.CompanyType(r.Type)] = r.Count
+	}
+	return counts, nil
+}
+
+// checkCompanyAffected returns ErrCompanyNotFound if query changed no company
+func checkCompanyAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrCompanyNotFound
+	}
 	return nil
 }
 
-// UpdateCompany updates company by id
+// UpdateCompany updates company by id and records it in company history
 func (c *PostgresClient) UpdateCompany(ctx context.Context, id uuid.UUID, company domain.Company) error {
+	defer c.replicas.wrote()
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
 	cmp := fromDomain(company)
 	cmp.ID = id
+	cmp.OrganizationID = tenantID
 	cmp.UpdatedAt = time.Now()
-	_, err := c.db.NamedExecContext(ctx,
-		`UPDATE companies SET name=:name, description=:description, amount_of_employees=:amount_of_employees, 
+	res, err := sqlx.NamedExecContext(ctx, c.conn(ctx), `WITH before AS (
+		SELECT * FROM companies WHERE id=:id AND organization_id=:organization_id AND deleted_at IS NULL FOR UPDATE
+	), after AS (
+		UPDATE companies SET name=:name, description=:description, amount_of_employees=:amount_of_employees, 
 		registered=:registered,type=:type, updated_at=:updated_at
-		 WHERE id=:id`, cmp)
+		 WHERE id=:id AND organization_id=:organization_id AND deleted_at IS NULL RETURNING *
+	)
+	INSERT INTO company_history (company_id, organization_id, action, before, after, actor_id, request_id)
+	SELECT after.id, after.organization_id, 'update', to_jsonb(before), to_jsonb(after), :actor_id, :request_id
+	FROM before, after`, newCompanyChange(ctx, cmp))
+	if isUniqueViolation(err) {
+		err = domain.ErrCompanyExists
+	}
 	if err != nil {
 		return fmt.Errorf("can not update company: %w", err)
 	}
-	return nil
+	return checkCompanyAffected(res)
+}
+
+// GetCompanyAsOf gets state of company at moment of time from company history. Times of history
+// are stored in UTC without time zone, so moment is converted to UTC.
+func (c *PostgresClient) GetCompanyAsOf(ctx context.Context, id uuid.UUID, asOf time.Time) (domain.Company, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return domain.Company{}, err
+	}
+	var cmp company
+	err = sqlx.GetContext(ctx, c.conn(ctx), &cmp, `SELECT r.* FROM (
+		SELECT after FROM company_history WHERE company_id=$1 AND organization_id=$2 AND created_at <= $3
+		ORDER BY id DESC LIMIT 1
+	) h, jsonb_populate_record(NULL::companies, h.after) r
+	WHERE h.after IS NOT NULL`, id, tenantID, asOf.UTC())
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Company{}, domain.ErrCompanyNotFound
+	}
+	if err != nil {
+		return domain.Company{}, fmt.Errorf("can not get company as of %v: %w", asOf, err)
+	}
+	return toDomain(cmp), nil
+}
+
+// SelectCompanyHistory selects history of company from oldest to newest change
+func (c *PostgresClient) SelectCompanyHistory(ctx context.Context, id uuid.UUID) ([]domain.CompanyChange, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var entries []companyHistory
+	err = sqlx.SelectContext(ctx, c.conn(ctx), &entries, `SELECT * FROM company_history 
+	WHERE company_id=$1 AND organization_id=$2 ORDER BY id`, id, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("can not select company history: %w", err)
+	}
+	history := make([]domain.CompanyChange, 0, len(entries))
+	for _, e := range entries {
+		change, err := e.toDomain()
+		if err != nil {
+			return nil, fmt.Errorf("can not parse company history: %w", err)
+		}
+		history = append(history, change)
+	}
+	return history, nil
 }
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+// exportFetchSize is number of companies fetched from cursor at once
+const exportFetchSize = 500
+
+// ExportCompanies streams companies matching filter to fn in batches read from server-side cursor,
+// so that companies are never loaded into memory at once. Limit 0 exports all companies.
+// Export stops when fn returns error or context is cancelled. Cursor is read in read-only transaction which
+// is not retried, since fn has already received companies when transaction fails.
+func (c *PostgresClient) ExportCompanies(ctx context.Context, filter domain.CompanyFilter, fn func(domain.Company) error) error {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+	limit := sql.NullInt64{Int64: int64(filter.Limit), Valid: filter.Limit > 0}
+	export := func(ctx context.Context) error {
+		q := c.conn(ctx)
+		_, err := q.ExecContext(ctx, `DECLARE export_companies NO SCROLL CURSOR FOR
+		SELECT * FROM companies WHERE organization_id=$1 AND ($2 OR deleted_at IS NULL)
+		ORDER BY created_at, id LIMIT $3 OFFSET $4`, tenantID, filter.IncludeDeleted, limit, filter.Offset)
+		if err != nil {
+			return err
+		}
+		fetch := fmt.Sprintf("FETCH %d FROM export_companies", exportFetchSize)
+		for {
+			var cmps []company
+			err = sqlx.SelectContext(ctx, q, &cmps, fetch)
+			if err != nil {
+				return err
+			}
+			for _, cmp := range cmps {
+				if err = fn(toDomain(cmp)); err != nil {
+					return err
+				}
+			}
+			if len(cmps) < exportFetchSize {
+				_, err = q.ExecContext(ctx, "CLOSE export_companies")
+				return err
+			}
+		}
+	}
+	if _, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		err = export(ctx)
+	} else {
+		err = c.runTx(ctx, &sql.TxOptions{ReadOnly: true}, export)
+	}
+	if err != nil {
+		return fmt.Errorf("can not export companies: %w", err)
+	}
+	return nil
+}
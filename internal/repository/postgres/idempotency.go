@@ -0,0 +1,103 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type idempotencyKey struct {
+	UserID      int64          `db:"user_id"`
+	Key         string         `db:"key"`
+	Fingerprint string         `db:"fingerprint"`
+	Status      sql.NullInt32  `db:"status"`
+	ContentType sql.NullString `db:"content_type"`
+	Response    []byte         `db:"response"`
+	CreatedAt   time.Time      `db:"created_at"`
+	ExpiresAt   time.Time      `db:"expires_at"`
+}
+
+func idempotencyKeyToDomain(k idempotencyKey) domain.IdempotencyKey {
+	return domain.IdempotencyKey{
+		UserID:      k.UserID,
+		Key:         k.Key,
+		Fingerprint: k.Fingerprint,
+		Status:      int(k.Status.Int32),
+		ContentType: k.ContentType.String,
+		Response:    k.Response,
+		StartedAt:   k.CreatedAt,
+		ExpiresAt:   k.ExpiresAt,
+	}
+}
+
+// startIdempotencyAttempts is number of attempts to start key which is purged between insert and select
+const startIdempotencyAttempts = 3
+
+// StartIdempotencyKey stores key of request in progress, which expires after ttl. If key is already stored
+// and not expired, stored key is returned and started is false. Key of request in progress for longer than
+// lease is taken again, request which took it has crashed or will not be able to store its response.
+func (c *PostgresClient) StartIdempotencyKey(ctx context.Context, key domain.IdempotencyKey, ttl, lease time.Duration) (stored domain.IdempotencyKey, started bool, err error) {
+	for attempt := 1; ; attempt++ {
+		var k idempotencyKey
+		// times are computed by database clock, columns are without time zone
+		err = sqlx.GetContext(ctx, c.conn(ctx), &k, `INSERT INTO idempotency_keys (user_id, key, fingerprint, created_at, expires_at)
+		VALUES ($1, $2, $3, NOW(), NOW() + $4 * INTERVAL '1 millisecond')
+		ON CONFLICT (user_id, key) DO UPDATE SET fingerprint=EXCLUDED.fingerprint, status=NULL, content_type=NULL,
+		response=NULL, created_at=EXCLUDED.created_at, expires_at=EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at < NOW()
+		OR (idempotency_keys.status IS NULL AND idempotency_keys.created_at < NOW() - $5 * INTERVAL '1 millisecond')
+		RETURNING *`, key.UserID, key.Key, key.Fingerprint, ttl.Milliseconds(), lease.Milliseconds())
+		if err == nil {
+			return idempotencyKeyToDomain(k), true, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return domain.IdempotencyKey{}, false, fmt.Errorf("can not start idempotency key: %w", err)
+		}
+		err = sqlx.GetContext(ctx, c.conn(ctx), &k, `SELECT * FROM idempotency_keys WHERE user_id=$1 AND key=$2`,
+			key.UserID, key.Key)
+		// key is deleted after insert conflicted with it, e.g. it is purged or its request failed, insert is tried again
+		if errors.Is(err, sql.ErrNoRows) && attempt < startIdempotencyAttempts {
+			continue
+		}
+		if err != nil {
+			return domain.IdempotencyKey{}, false, fmt.Errorf("can not get idempotency key: %w", err)
+		}
+		return idempotencyKeyToDomain(k), false, nil
+	}
+}
+
+// CompleteIdempotencyKey stores response of request, it is not stored if key was taken by another request
+// after lease of the request expired
+func (c *PostgresClient) CompleteIdempotencyKey(ctx context.Context, key domain.IdempotencyKey) error {
+	_, err := c.conn(ctx).ExecContext(ctx, `UPDATE idempotency_keys SET status=$4, content_type=$5, response=$6
+	WHERE user_id=$1 AND key=$2 AND created_at=$3 AND status IS NULL`,
+		key.UserID, key.Key, key.StartedAt, key.Status, key.ContentType, key.Response)
+	if err != nil {
+		return fmt.Errorf("can not complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// DeleteIdempotencyKey deletes key of request in progress, so that request can be retried
+func (c *PostgresClient) DeleteIdempotencyKey(ctx context.Context, key domain.IdempotencyKey) error {
+	_, err := c.conn(ctx).ExecContext(ctx, `DELETE FROM idempotency_keys
+	WHERE user_id=$1 AND key=$2 AND created_at=$3 AND status IS NULL`, key.UserID, key.Key, key.StartedAt)
+	if err != nil {
+		return fmt.Errorf("can not delete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// PurgeIdempotencyKeys deletes expired keys
+func (c *PostgresClient) PurgeIdempotencyKeys(ctx context.Context) (int64, error) {
+	res, err := c.conn(ctx).ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("can not purge idempotency keys: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+// companyCopySource adapts stream of companies to source of COPY
+type companyCopySource struct {
+	src domain.CompanySource
+	row int
+}
+
+func (s *companyCopySource) Next() bool {
+	if !s.src.Next() {
+		return false
+	}
+	s.row++
+	return true
+}
+
+func (s *companyCopySource) Values() ([]any, error) {
+	c := s.src.Company()
+	return []any{s.row, c.Name, c.Description, c.AmountOfEmployees, c.Registered, string(c.Type)}, nil
+}
+
+func (s *companyCopySource) Err() error {
+	return s.src.Err()
+}
+
+// errImportInTx is returned by import called within transaction
+var errImportInTx = errors.New("import of companies runs in own transaction and can not join transaction of context")
+
+// ImportCompanies streams companies into database with COPY and upserts them by name in one
+// transaction: existing companies with the same name are updated, others are created.
+// If name repeats in source, the last company wins. Every change is recorded in company history.
+// COPY needs pgx connection, so import can not run in transaction of WithinTx, errImportInTx is returned then.
+func (c *PostgresClient) ImportCompanies(ctx context.Context, src domain.CompanySource) (domain.ImportResult, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return domain.ImportResult{}, err
+	}
+	if _, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return domain.ImportResult{}, fmt.Errorf("can not import companies: %w", errImportInTx)
+	}
+	change := newCompanyChange(ctx, company{})
+	defer c.replicas.wrote()
+
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return domain.ImportResult{}, fmt.Errorf("can not import companies: %w", err)
+	}
+	defer conn.Close()
+
+	var result domain.ImportResult
+	err = conn.Raw(func(driverConn any) error {
+		stdConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("driver connection %T is not pgx connection", driverConn)
+		}
+		pgxConn := stdConn.Conn()
+		return pgx.BeginFunc(ctx, pgxConn, func(tx pgx.Tx) error {
+			// copy of large source can take longer than statement timeout of client
+			_, err := tx.Exec(ctx, "SET LOCAL statement_timeout = 0")
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(ctx, `CREATE TEMP TABLE import_companies (
+				row integer NOT NULL,
+				name VARCHAR(15) NOT NULL,
+				description TEXT,
+				amount_of_employees integer NOT NULL,
+				registered boolean NOT NULL,
+				type company_type NOT NULL
+			) ON COMMIT DROP`)
+			if err != nil {
+				return err
+			}
+			_, err = tx.CopyFrom(ctx, pgx.Identifier{"import_companies"},
+				[]string{"row", "name", "description", "amount_of_employees", "registered", "type"},
+				&companyCopySource{src: src})
+			if err != nil {
+				return err
+			}
+
+			actions, err := tx.Query(ctx, `WITH source AS (
+				SELECT DISTINCT ON (name) * FROM import_companies ORDER BY name, row DESC
+			), before AS (
+				SELECT c.* FROM companies c JOIN source s ON c.name = s.name
+				WHERE c.organization_id = $1 AND c.deleted_at IS NULL FOR UPDATE
+			), after AS (
+				INSERT INTO companies (name, description, amount_of_employees, registered, type, organization_id)
+				SELECT name, description, amount_of_employees, registered, type, $1 FROM source
+				ON CONFLICT (organization_id, name) WHERE deleted_at IS NULL DO UPDATE SET
+				description=EXCLUDED.description, amount_of_employees=EXCLUDED.amount_of_employees,
+				registered=EXCLUDED.registered, type=EXCLUDED.type, updated_at=NOW()
+				RETURNING *
+			)
+			INSERT INTO company_history (company_id, organization_id, action, before, after, actor_id, request_id)
+			SELECT a.id, a.organization_id, CASE WHEN b.id IS NULL THEN 'create' ELSE 'update' END,
+			to_jsonb(b), to_jsonb(a), $2, $3
+			FROM after a LEFT JOIN before b ON b.id = a.id
+			RETURNING action`, tenantID, change.ActorID, change.RequestID)
+			if err != nil {
+				return err
+			}
+			defer actions.Close()
+			for actions.Next() {
+				var action string
+				if err := actions.Scan(&action); err != nil {
+					return err
+				}
+				if domain.CompanyAction(action) == domain.CompanyCreated {
+					result.Created++
+				} else {
+					result.Updated++
+				}
+			}
+			return actions.Err()
+		})
+	})
+	if err != nil {
+		return domain.ImportResult{}, fmt.Errorf("can not import companies: %w", err)
+	}
+	return result, nil
+}
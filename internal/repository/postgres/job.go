@@ -0,0 +1,216 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type job struct {
+	ID              uuid.UUID      `db:"id"`
+	OrganizationID  int64          `db:"organization_id"`
+	UserID          sql.NullInt64  `db:"user_id"`
+	Type            string         `db:"type"`
+	Status          string         `db:"status"`
+	Params          []byte         `db:"params"`
+	Input           sql.NullString `db:"input"`
+	Result          sql.NullString `db:"result"`
+	Progress        int            `db:"progress"`
+	Attempts        int            `db:"attempts"`
+	MaxAttempts     int            `db:"max_attempts"`
+	Error           sql.NullString `db:"error"`
+	CancelRequested bool           `db:"cancel_requested"`
+	ClaimToken      uuid.NullUUID  `db:"claim_token"`
+	RunAt           time.Time      `db:"run_at"`
+	CreatedAt       time.Time      `db:"created_at"`
+	UpdatedAt       time.Time      `db:"updated_at"`
+	FinishedAt      sql.NullTime   `db:"finished_at"`
+}
+
+func jobToDomain(j job) (domain.Job, error) {
+	dj := domain.Job{
+		ID:              j.ID,
+		OrganizationID:  j.OrganizationID,
+		UserID:          j.UserID.Int64,
+		Type:            domain.JobType(j.Type),
+		Status:          domain.JobStatus(j.Status),
+		Input:           j.Input.String,
+		Result:          j.Result.String,
+		Progress:        j.Progress,
+		Attempts:        j.Attempts,
+		MaxAttempts:     j.MaxAttempts,
+		Error:           j.Error.String,
+		CancelRequested: j.CancelRequested,
+		ClaimToken:      j.ClaimToken.UUID,
+		RunAt:           j.RunAt,
+		CreatedAt:       j.CreatedAt,
+		UpdatedAt:       j.UpdatedAt,
+	}
+	if j.FinishedAt.Valid {
+		dj.FinishedAt = &j.FinishedAt.Time
+	}
+	if err := json.Unmarshal(j.Params, &dj.Params); err != nil {
+		return domain.Job{}, fmt.Errorf("can not parse job params: %w", err)
+	}
+	return dj, nil
+}
+
+// getJob runs query returning single job, no rows is domain.ErrJobNotFound
+func (c *PostgresClient) getJob(ctx context.Context, query string, args ...any) (domain.Job, error) {
+	var j job
+	err := sqlx.GetContext(ctx, c.conn(ctx), &j, query, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return domain.Job{}, domain.ErrJobNotFound
+	}
+	if err != nil {
+		return domain.Job{}, err
+	}
+	return jobToDomain(j)
+}
+
+// CreateJob creates new queued job in organization of context
+func (c *PostgresClient) CreateJob(ctx context.Context, dj domain.Job) (domain.Job, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return domain.Job{}, err
+	}
+	params, err := json.Marshal(dj.Params)
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("can not create job: %w", err)
+	}
+	userID := sql.NullInt64{Int64: dj.UserID, Valid: dj.UserID != 0}
+	input := sql.NullString{String: dj.Input, Valid: dj.Input != ""}
+	created, err := c.getJob(ctx, `INSERT INTO jobs (organization_id, user_id, type, params, input, max_attempts)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING *`, tenantID, userID, dj.Type, params, input, dj.MaxAttempts)
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("can not create job: %w", err)
+	}
+	return created, nil
+}
+
+// GetJob gets job by id in organization of context
+func (c *PostgresClient) GetJob(ctx context.Context, id uuid.UUID) (domain.Job, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return domain.Job{}, err
+	}
+	j, err := c.getJob(ctx, `SELECT * FROM jobs WHERE id=$1 AND organization_id=$2`, id, tenantID)
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("can not get job: %w", err)
+	}
+	return j, nil
+}
+
+// CancelJob requests cancellation of job in organization of context. Queued job is cancelled at once,
+// running job is cancelled by its worker.
+func (c *PostgresClient) CancelJob(ctx context.Context, id uuid.UUID) (domain.Job, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return domain.Job{}, err
+	}
+	j, err := c.getJob(ctx, `UPDATE jobs SET cancel_requested=true, updated_at=NOW(),
+	status=CASE WHEN status='queued' THEN 'cancelled' ELSE status END,
+	finished_at=CASE WHEN status='queued' THEN NOW() END
+	WHERE id=$1 AND organization_id=$2 AND status IN ('queued', 'running') RETURNING *`, id, tenantID)
+	if errors.Is(err, domain.ErrJobNotFound) {
+		if _, err = c.GetJob(ctx, id); err == nil {
+			err = domain.ErrJobFinished
+		}
+	}
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("can not cancel job: %w", err)
+	}
+	return j, nil
+}
+
+// ClaimJob marks next due job of any organization as running and returns it with new claim token. Running jobs
+// without heartbeat for staleAfter are claimed again, their worker is considered dead, unless they have used
+// all attempts: such jobs are marked as failed by the same statement. Times are compared with clock of
+// database, so that clocks of workers do not matter.
+// It returns domain.ErrJobQueueIsEmpty if there is no job to run.
+func (c *PostgresClient) ClaimJob(ctx context.Context, staleAfter time.Duration) (domain.Job, error) {
+	j, err := c.getJob(ctx, `WITH exhausted AS (
+		UPDATE jobs SET status='failed', claim_token=NULL, error='worker stopped responding, no attempts left',
+		updated_at=NOW(), finished_at=NOW()
+		WHERE status='running' AND updated_at < NOW() - $1 * INTERVAL '1 millisecond' AND attempts >= max_attempts
+	)
+	UPDATE jobs SET status='running', attempts=attempts+1, claim_token=gen_random_uuid(), updated_at=NOW()
+	WHERE id = (
+		SELECT id FROM jobs
+		WHERE (status='queued' AND run_at <= NOW())
+		OR (status='running' AND updated_at < NOW() - $1 * INTERVAL '1 millisecond' AND attempts < max_attempts)
+		ORDER BY run_at
+		FOR UPDATE SKIP LOCKED
+		LIMIT 1
+	) RETURNING *`, staleAfter.Milliseconds())
+	if errors.Is(err, domain.ErrJobNotFound) {
+		return domain.Job{}, domain.ErrJobQueueIsEmpty
+	}
+	if err != nil {
+		return domain.Job{}, fmt.Errorf("can not claim job: %w", err)
+	}
+	return j, nil
+}
+
+// HeartbeatJob stores progress of running job and reports whether its cancellation is requested.
+// It returns domain.ErrJobClaimLost if job was claimed again by another worker.
+func (c *PostgresClient) HeartbeatJob(ctx context.Context, dj domain.Job, progress int) (bool, error) {
+	var cancelRequested bool
+	err := sqlx.GetContext(ctx, c.conn(ctx), &cancelRequested, `UPDATE jobs SET progress=$3, updated_at=NOW()
+	WHERE id=$1 AND claim_token=$2 AND status='running' RETURNING cancel_requested`, dj.ID, dj.ClaimToken, progress)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("can not update job: %w", domain.ErrJobClaimLost)
+	}
+	if err != nil {
+		return false, fmt.Errorf("can not update job: %w", err)
+	}
+	return cancelRequested, nil
+}
+
+// FinishJob stores final status, result, progress and error of running job.
+// It returns domain.ErrJobClaimLost if job was claimed again by another worker.
+func (c *PostgresClient) FinishJob(ctx context.Context, dj domain.Job) error {
+	res, err := c.conn(ctx).ExecContext(ctx, `UPDATE jobs SET status=$3, result=$4, progress=$5, error=$6,
+	updated_at=NOW(), finished_at=NOW() WHERE id=$1 AND claim_token=$2 AND status='running'`, dj.ID, dj.ClaimToken,
+		dj.Status, sql.NullString{String: dj.Result, Valid: dj.Result != ""}, dj.Progress,
+		sql.NullString{String: dj.Error, Valid: dj.Error != ""})
+	if err = claimed(res, err); err != nil {
+		return fmt.Errorf("can not finish job: %w", err)
+	}
+	return nil
+}
+
+// RetryJob queues running job again to run after delay.
+// It returns domain.ErrJobClaimLost if job was claimed again by another worker.
+func (c *PostgresClient) RetryJob(ctx context.Context, dj domain.Job, delay time.Duration) error {
+	res, err := c.conn(ctx).ExecContext(ctx, `UPDATE jobs SET status='queued', attempts=$3, error=$4, progress=0,
+	claim_token=NULL, run_at=NOW() + $5 * INTERVAL '1 millisecond', updated_at=NOW()
+	WHERE id=$1 AND claim_token=$2 AND status='running'`, dj.ID, dj.ClaimToken, dj.Attempts,
+		sql.NullString{String: dj.Error, Valid: dj.Error != ""}, delay.Milliseconds())
+	if err = claimed(res, err); err != nil {
+		return fmt.Errorf("can not retry job: %w", err)
+	}
+	return nil
+}
+
+// claimed checks that update of claimed job changed it, otherwise claim was lost
+func claimed(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrJobClaimLost
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package postgres
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Collectors returns collectors of connection pool stats of primary and replicas,
+// pools are distinguished by db_name label
+func (c *PostgresClient) Collectors() []prometheus.Collector {
+	cs := []prometheus.Collector{collectors.NewDBStatsCollector(c.db.DB, "primary")}
+	if c.replicas != nil {
+		for _, r := range c.replicas.replicas {
+			cs = append(cs, collectors.NewDBStatsCollector(r.db.DB, "replica_"+r.addr))
+		}
+	}
+	return cs
+}
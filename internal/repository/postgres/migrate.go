@@ -0,0 +1,171 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/golang-migrate/migrate/v4"
+	migratepg "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// migrationLockID is key of advisory lock held while migrations are applied. Lock of golang-migrate
+// gives up after timeout, this one makes other instances wait until migrations are applied.
+const migrationLockID int64 = 0x636f6d70616e6965
+
+// MigrationStatus is state of database schema
+type MigrationStatus struct {
+	// Version is applied version, 0 if no migrations are applied
+	Version uint
+	// Dirty is true if migration to Version failed
+	Dirty bool
+	// Latest is version of latest known migration
+	Latest uint
+}
+
+// Migrator applies versioned migrations to database
+type Migrator struct {
+	conn    *sql.Conn
+	migrate *migrate.Migrate
+	latest  uint
+}
+
+// NewMigrator creates migrator of database, migrations are read from fsys.
+// Migrator holds connection of client until it is closed.
+func (c *PostgresClient) NewMigrator(ctx context.Context, fsys fs.FS) (*Migrator, error) {
+	src, err := iofs.New(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("can not read migrations: %w", err)
+	}
+	latest, err := latestVersion(src)
+	if err != nil {
+		return nil, fmt.Errorf("can not read migrations: %w", err)
+	}
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can not connect to database: %w", err)
+	}
+	// migrations and waiting for other instances applying them are not limited by timeouts of client
+	if _, err = conn.ExecContext(ctx, `SELECT set_config('statement_timeout', '0', false), set_config('lock_timeout', '0', false)`); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("can not reset timeouts: %w", err)
+	}
+	driver, err := migratepg.WithConnection(ctx, conn, &migratepg.Config{})
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("can not create migration driver: %w", err)
+	}
+	m, err := migrate.NewWithInstance("iofs", src, "postgres", driver)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("can not create migrator: %w", err)
+	}
+	return &Migrator{conn: conn, migrate: m, latest: latest}, nil
+}
+
+// latestVersion returns version of last migration of source
+func latestVersion(src source.Driver) (uint, error) {
+	version, err := src.First()
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	for err == nil {
+		var next uint
+		next, err = src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		version = next
+	}
+	return 0, err
+}
+
+// Up applies all migrations which are not applied yet. Concurrent calls of instances sharing
+// database are serialized, so that migrations are applied once.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func() error {
+		err := m.migrate.Up()
+		if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			return fmt.Errorf("can not apply migrations: %w", err)
+		}
+		return nil
+	})
+}
+
+// Down reverts steps last applied migrations, it waits for migrations applied by other instances
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.locked(ctx, func() error {
+		if err := m.migrate.Steps(-steps); err != nil {
+			return fmt.Errorf("can not revert migrations: %w", err)
+		}
+		return nil
+	})
+}
+
+// Force sets version of schema without applying migrations, it is used to clean dirty state
+// after failed migration is fixed manually. It waits for migrations applied by other instances.
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	return m.locked(ctx, func() error {
+		if err := m.migrate.Force(version); err != nil {
+			return fmt.Errorf("can not force version: %w", err)
+		}
+		return nil
+	})
+}
+
+// locked runs fn holding migration lock
+func (m *Migrator) locked(ctx context.Context, fn func() error) error {
+	if _, err := m.conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("can not lock migrations: %w", err)
+	}
+	defer func() {
+		_, _ = m.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+	}()
+	return fn()
+}
+
+// Status returns applied and latest versions of schema
+func (m *Migrator) Status() (MigrationStatus, error) {
+	version, dirty, err := m.migrate.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return MigrationStatus{}, fmt.Errorf("can not get schema version: %w", err)
+	}
+	return MigrationStatus{Version: version, Dirty: dirty, Latest: m.latest}, nil
+}
+
+// Check returns error if schema is dirty or behind latest migration
+func (m *Migrator) Check() error {
+	status, err := m.Status()
+	if err != nil {
+		return err
+	}
+	if status.Dirty {
+		return fmt.Errorf("%w: version %d", domain.ErrSchemaDirty, status.Version)
+	}
+	if status.Version < status.Latest {
+		return fmt.Errorf("%w: version %d, latest %d", domain.ErrSchemaOutdated, status.Version, status.Latest)
+	}
+	return nil
+}
+
+// Close closes migrator and releases its connection. Timeouts of connection are restored
+// before it is returned to pool.
+func (m *Migrator) Close() error {
+	_, resetErr := m.conn.ExecContext(context.Background(), "RESET statement_timeout; RESET lock_timeout")
+	if resetErr != nil {
+		// connection without timeouts must not be reused
+		_ = m.conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+	srcErr, dbErr := m.migrate.Close()
+	if srcErr != nil {
+		return srcErr
+	}
+	return dbErr
+}
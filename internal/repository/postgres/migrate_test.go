@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Ragnar-BY/companies-handler/postgres/migrations"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestVersion(t *testing.T) {
+	testCases := []struct {
+		name   string
+		files  fstest.MapFS
+		latest uint
+	}{
+		{
+			name:   "no migrations",
+			files:  fstest.MapFS{"README": {}},
+			latest: 0,
+		},
+		{
+			name: "versions with gaps",
+			files: fstest.MapFS{
+				"001_a.up.sql":   {},
+				"001_a.down.sql": {},
+				"003_b.up.sql":   {},
+				"010_c.up.sql":   {},
+			},
+			latest: 10,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := iofs.New(tt.files, ".")
+			require.NoError(t, err)
+			latest, err := latestVersion(src)
+			require.NoError(t, err)
+			require.Equal(t, tt.latest, latest)
+		})
+	}
+}
+
+// TestEmbeddedMigrations checks that every embedded migration can be reverted
+func TestEmbeddedMigrations(t *testing.T) {
+	ups, err := fs.Glob(migrations.FS, "*.up.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, ups)
+	for _, up := range ups {
+		down := strings.TrimSuffix(up, ".up.sql") + ".down.sql"
+		_, err := fs.Stat(migrations.FS, down)
+		require.NoError(t, err, "migration %s has no down migration", up)
+	}
+
+	src, err := iofs.New(migrations.FS, ".")
+	require.NoError(t, err)
+	latest, err := latestVersion(src)
+	require.NoError(t, err)
+	require.Equal(t, uint(len(ups)), latest)
+}
@@ -1,43 +1,236 @@
 package postgres
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
 )
 
-// PostgresSettings are settings for postgreSQL
+// PostgresSettings are settings for postgreSQL. Zero values leave defaults of driver.
 type PostgresSettings struct {
 	Addr     string
 	Database string
 	Username string
 	Password string
+
+	// SSLMode is TLS mode of connections: disable, allow, prefer, require, verify-ca or verify-full
+	SSLMode string
+	// SSLRootCert is file of CA certificates to verify server with, SSLCert and SSLKey are files
+	// of client certificate
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+
+	ApplicationName string
+	ConnectTimeout  time.Duration
+	// StatementTimeout aborts queries running longer, LockTimeout aborts queries waiting for lock longer
+	StatementTimeout time.Duration
+	LockTimeout      time.Duration
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// Replicas are addresses of read replicas, they are connected with the same credentials.
+	// Reads of companies are routed to healthy replicas, everything else goes to primary.
+	Replicas []string
+	// ReplicaCheckInterval is how often health and lag of replicas are checked
+	ReplicaCheckInterval time.Duration
+	// ReplicaMaxLag is replication lag replica is considered unhealthy after, 0 ignores lag
+	ReplicaMaxLag time.Duration
+	// PrimaryReadAfterWrite is how long reads are served by primary after write of client, so that
+	// client reads its own writes although replicas lag. It should exceed lag of healthy replicas.
+	PrimaryReadAfterWrite time.Duration
+
+	// BreakerThreshold is number of consecutive failed connections circuit breaker opens after,
+	// 0 disables breaker. Open breaker fails connections with domain.ErrUnavailable for BreakerCooldown.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// ConnectBackoff is delay before second attempt of Connect, it doubles up to MaxConnectBackoff
+	ConnectBackoff    time.Duration
+	MaxConnectBackoff time.Duration
+}
+
+// dsn returns connection string of server on addr
+func (s PostgresSettings) dsn(addr string) string {
+	params := url.Values{}
+	setParam := func(key, value string) {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	setParam("sslmode", s.SSLMode)
+	setParam("sslrootcert", s.SSLRootCert)
+	setParam("sslcert", s.SSLCert)
+	setParam("sslkey", s.SSLKey)
+	setParam("application_name", s.ApplicationName)
+	if s.ConnectTimeout > 0 {
+		params.Set("connect_timeout", strconv.Itoa(int(math.Ceil(s.ConnectTimeout.Seconds()))))
+	}
+	// unknown parameters are sent to server as run-time parameters of every connection.
+	// Time zone is pinned, since columns without time zone store times of NOW() in time zone of session
+	params.Set("timezone", "UTC")
+	if s.StatementTimeout > 0 {
+		params.Set("statement_timeout", strconv.FormatInt(s.StatementTimeout.Milliseconds(), 10))
+	}
+	if s.LockTimeout > 0 {
+		params.Set("lock_timeout", strconv.FormatInt(s.LockTimeout.Milliseconds(), 10))
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(s.Username, s.Password),
+		Host:     addr,
+		Path:     "/" + s.Database,
+		RawQuery: params.Encode(),
+	}
+	return u.String()
+}
+
+// configurePool applies limits of connection pool to db
+func (s PostgresSettings) configurePool(db *sqlx.DB) {
+	if s.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(s.MaxOpenConns)
+	}
+	if s.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(s.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(s.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(s.ConnMaxIdleTime)
+}
+
+// connector creates connector to server at addr, queries of its connections are traced
+func (s PostgresSettings) connector(addr string) (driver.Connector, error) {
+	connConfig, err := pgx.ParseConfig(s.dsn(addr))
+	if err != nil {
+		return nil, err
+	}
+	connConfig.Tracer = queryTracer{}
+	return stdlib.GetConnector(*connConfig), nil
 }
 
 // PostgresClient is client for postgreSQL
 type PostgresClient struct {
-	db *sqlx.DB
+	db       *sqlx.DB
+	replicas *replicaSet
+	breaker  *breaker
 }
 
-// NewPostgresClient connects to postgresSQL and return instance of client
+// NewPostgresClient connects to postgresSQL and return instance of client.
+// Replicas are not required to be available, they are used once they pass health check.
 func NewPostgresClient(settings PostgresSettings) (*PostgresClient, error) {
-	conn := fmt.Sprintf("postgres://%s:%s@%s/%s", settings.Username, settings.Password, settings.Addr, settings.Database)
-	db, err := sqlx.Connect("pgx", conn)
+	connector, err := settings.connector(settings.Addr)
 	if err != nil {
 		return nil, err
 	}
+	var b *breaker
+	if settings.BreakerThreshold > 0 {
+		b = newBreaker(settings.BreakerThreshold, settings.BreakerCooldown)
+		connector = breakerConnector{Connector: connector, breaker: b}
+	}
+	db := sqlx.NewDb(sql.OpenDB(connector), "pgx")
+	if err = db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	settings.configurePool(db)
+	replicas, err := newReplicaSet(settings)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
 	return &PostgresClient{
-		db: db,
+		db:       db,
+		replicas: replicas,
+		breaker:  b,
 	}, nil
 }
 
+// defaultConnectBackoff is used if delay between attempts to connect is not set
+const defaultConnectBackoff = 500 * time.Millisecond
+
+// Connect connects to database like NewPostgresClient, failed attempts are repeated with exponential
+// backoff until context is done, so that client can be started before database. onRetry is called
+// with error of every failed attempt and delay before next one.
+func Connect(ctx context.Context, settings PostgresSettings, onRetry func(err error, delay time.Duration)) (*PostgresClient, error) {
+	delay := settings.ConnectBackoff
+	if delay <= 0 {
+		delay = defaultConnectBackoff
+	}
+	for {
+		client, err := NewPostgresClient(settings)
+		if err == nil {
+			return client, nil
+		}
+		onRetry(err, delay)
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("can not connect to database: %w", err)
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if settings.MaxConnectBackoff > 0 && delay > settings.MaxConnectBackoff {
+			delay = settings.MaxConnectBackoff
+		}
+	}
+}
+
+// Available returns domain.ErrUnavailable while circuit breaker is open after database became
+// unavailable. Breaker closes by itself once connection to database succeeds again.
+func (c *PostgresClient) Available() error {
+	if c.breaker == nil {
+		return nil
+	}
+	return c.breaker.available()
+}
+
+// BreakerStatus returns state of circuit breaker, it is closed if breaker is disabled
+func (c *PostgresClient) BreakerStatus() BreakerStatus {
+	if c.breaker == nil {
+		return BreakerStatus{State: string(breakerClosed)}
+	}
+	return c.breaker.status()
+}
+
 // Ping pings database
-func (c *PostgresClient) Ping() error {
-	return c.db.Ping()
+func (c *PostgresClient) Ping(ctx context.Context) error {
+	return c.db.PingContext(ctx)
 }
 
-// Close closes database
+// Close closes database and its replicas
 func (c *PostgresClient) Close() error {
+	c.replicas.close()
 	return c.db.Close()
 }
+
+// tenant returns organization every company query must be scoped to
+func tenant(ctx context.Context) (int64, error) {
+	id, ok := domain.TenantFromContext(ctx)
+	if !ok {
+		return 0, domain.ErrNoTenant
+	}
+	return id, nil
+}
+
+// uniqueViolation is SQLSTATE of violated unique constraint
+const uniqueViolation = "23505"
+
+// isUniqueViolation checks if query failed because of unique constraint
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettingsDSN(t *testing.T) {
+	settings := PostgresSettings{
+		Database:         "companies",
+		Username:         "user",
+		Password:         "p@ss/word",
+		SSLMode:          "disable",
+		ApplicationName:  "companies-handler",
+		ConnectTimeout:   1500 * time.Millisecond,
+		StatementTimeout: 30 * time.Second,
+		LockTimeout:      5 * time.Second,
+	}
+	cfg, err := pgconn.ParseConfig(settings.dsn("replica:6432"))
+	require.NoError(t, err)
+	require.Equal(t, "replica", cfg.Host)
+	require.Equal(t, uint16(6432), cfg.Port)
+	require.Equal(t, "companies", cfg.Database)
+	require.Equal(t, "user", cfg.User)
+	require.Equal(t, "p@ss/word", cfg.Password)
+	require.Nil(t, cfg.TLSConfig)
+	require.Equal(t, 2*time.Second, cfg.ConnectTimeout)
+	require.Equal(t, map[string]string{
+		"application_name":  "companies-handler",
+		"timezone":          "UTC",
+		"statement_timeout": "30000",
+		"lock_timeout":      "5000",
+	}, cfg.RuntimeParams)
+
+	cfg, err = pgconn.ParseConfig(PostgresSettings{SSLMode: "require"}.dsn("localhost"))
+	require.NoError(t, err)
+	require.NotNil(t, cfg.TLSConfig)
+	require.Equal(t, map[string]string{"timezone": "UTC"}, cfg.RuntimeParams)
+}
+
+func TestReplicaSetPick(t *testing.T) {
+	var set *replicaSet
+	require.Nil(t, set.pick())
+
+	set = &replicaSet{replicas: []*replica{{addr: "a"}, {addr: "b"}, {addr: "c"}}}
+	require.Nil(t, set.pick())
+
+	set.replicas[0].healthy.Store(true)
+	set.replicas[2].healthy.Store(true)
+	picked := map[string]int{}
+	for i := 0; i < 10; i++ {
+		picked[set.pick().addr]++
+	}
+	require.Equal(t, map[string]int{"a": 5, "c": 5}, picked)
+}
@@ -0,0 +1,189 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+)
+
+// defaultReplicaCheckInterval is used if interval of health checks is not set
+const defaultReplicaCheckInterval = 5 * time.Second
+
+// replica is read replica of database
+type replica struct {
+	addr    string
+	db      *sqlx.DB
+	healthy atomic.Bool
+}
+
+// replicaSet routes reads to healthy replicas in turn and checks their health in background
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint64
+	maxLag   time.Duration
+	// reads are served by primary until primaryUntil (unix nanoseconds) after write
+	primaryAfterWrite time.Duration
+	primaryUntil      atomic.Int64
+	stop              chan struct{}
+	wg                sync.WaitGroup
+}
+
+// newReplicaSet opens replicas of settings, it returns nil if there are no replicas
+func newReplicaSet(settings PostgresSettings) (*replicaSet, error) {
+	if len(settings.Replicas) == 0 {
+		return nil, nil
+	}
+	s := &replicaSet{
+		maxLag:            settings.ReplicaMaxLag,
+		primaryAfterWrite: settings.PrimaryReadAfterWrite,
+		stop:              make(chan struct{}),
+	}
+	for _, addr := range settings.Replicas {
+		connector, err := settings.connector(addr)
+		if err != nil {
+			s.closeReplicas()
+			return nil, err
+		}
+		db := sqlx.NewDb(sql.OpenDB(connector), "pgx")
+		settings.configurePool(db)
+		s.replicas = append(s.replicas, &replica{addr: addr, db: db})
+	}
+	interval := settings.ReplicaCheckInterval
+	if interval <= 0 {
+		interval = defaultReplicaCheckInterval
+	}
+	s.wg.Add(1)
+	go s.run(interval)
+	return s, nil
+}
+
+// run checks replicas every interval until set is closed
+func (s *replicaSet) run(interval time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, r := range s.replicas {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			r.healthy.Store(s.check(ctx, r) == nil)
+			cancel()
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check returns error if replica is unavailable, does not stream WAL from primary or lags behind primary
+// more than allowed
+func (s *replicaSet) check(ctx context.Context, r *replica) error {
+	// lag is 0 if replica replayed everything it received, so that idle primary does not make it stale.
+	// Replica which lost connection to primary replayed everything too, so WAL receiver must be streaming,
+	// its status is visible to privileged users only, row of receiver exists only while it runs.
+	var state struct {
+		Streaming bool    `db:"streaming"`
+		Lag       float64 `db:"lag"`
+	}
+	err := r.db.GetContext(ctx, &state, `SELECT
+		EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE status IS NULL OR status = 'streaming') AS streaming,
+		COALESCE(CASE
+			WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp()) END, 0) AS lag`)
+	if err != nil {
+		return err
+	}
+	if !state.Streaming {
+		return errReplicaNotStreaming
+	}
+	if s.maxLag > 0 && state.Lag > s.maxLag.Seconds() {
+		return errReplicaLag
+	}
+	return nil
+}
+
+var (
+	errReplicaLag          = errors.New("replica lags behind primary")
+	errReplicaNotStreaming = errors.New("replica does not stream from primary")
+)
+
+// wrote routes reads to primary for a while after write, until replicas replay it
+func (s *replicaSet) wrote() {
+	if s == nil || s.primaryAfterWrite <= 0 {
+		return
+	}
+	until := time.Now().Add(s.primaryAfterWrite).UnixNano()
+	for {
+		current := s.primaryUntil.Load()
+		if current >= until || s.primaryUntil.CompareAndSwap(current, until) {
+			return
+		}
+	}
+}
+
+// pick returns next healthy replica or nil if there is none or reads are served by primary after write
+func (s *replicaSet) pick() *replica {
+	if s == nil || time.Now().UnixNano() < s.primaryUntil.Load() {
+		return nil
+	}
+	healthy := make([]*replica, 0, len(s.replicas))
+	for _, r := range s.replicas {
+		if r.healthy.Load() {
+			healthy = append(healthy, r)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	return healthy[s.next.Add(1)%uint64(len(healthy))]
+}
+
+// close stops health checks and closes replicas
+func (s *replicaSet) close() {
+	if s == nil {
+		return
+	}
+	close(s.stop)
+	s.wg.Wait()
+	s.closeReplicas()
+}
+
+func (s *replicaSet) closeReplicas() {
+	for _, r := range s.replicas {
+		_ = r.db.Close()
+	}
+}
+
+// read runs read-only query on healthy replica. Query runs on primary if there is no healthy replica,
+// it failed on replica or context has transaction, so that transaction sees its own changes.
+// Query also runs on primary for a while after write of client and if context requires primary read.
+func (c *PostgresClient) read(ctx context.Context, query func(q sqlx.ExtContext) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return query(tx)
+	}
+	if domain.PrimaryReadFromContext(ctx) {
+		return query(c.db)
+	}
+	r := c.replicas.pick()
+	if r == nil {
+		return query(c.db)
+	}
+	err := query(r.db)
+	if err == nil || errors.Is(err, sql.ErrNoRows) || ctx.Err() != nil {
+		return err
+	}
+	// errors of server, e.g. query canceled on conflict with recovery, do not mean replica is down
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		r.healthy.Store(false)
+	}
+	return query(c.db)
+}
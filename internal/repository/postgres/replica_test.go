@@ -0,0 +1,26 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicaSetPrimaryAfterWrite(t *testing.T) {
+	healthy := &replica{addr: "replica"}
+	healthy.healthy.Store(true)
+	s := &replicaSet{replicas: []*replica{healthy}, primaryAfterWrite: 50 * time.Millisecond}
+	require.Equal(t, healthy, s.pick())
+
+	// reads go to primary until replica replays write
+	s.wrote()
+	require.Nil(t, s.pick())
+	require.Eventually(t, func() bool {
+		return s.pick() == healthy
+	}, time.Second, 10*time.Millisecond)
+
+	var none *replicaSet
+	none.wrote()
+	require.Nil(t, none.pick())
+}
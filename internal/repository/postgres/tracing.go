@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/Ragnar-BY/companies-handler/internal/repository/postgres")
+
+// queryTracer traces every query and copy of connection with span, spans are children of span of context of query
+type queryTracer struct{}
+
+// startSpan starts client span of database call
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) context.Context {
+	ctx, _ = tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs, semconv.DBSystemPostgreSQL)...),
+	)
+	return ctx
+}
+
+// endSpan records error of database call and ends its span started in ctx
+func endSpan(ctx context.Context, err error) {
+	span := trace.SpanFromContext(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// TraceQueryStart starts span of query
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return startSpan(ctx, "postgres.query", semconv.DBStatement(data.SQL))
+}
+
+// TraceQueryEnd ends span of query
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	endSpan(ctx, data.Err)
+}
+
+// TraceCopyFromStart starts span of copy
+func (queryTracer) TraceCopyFromStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromStartData) context.Context {
+	return startSpan(ctx, "postgres.copy", semconv.DBSQLTable(data.TableName.Sanitize()))
+}
+
+// TraceCopyFromEnd ends span of copy
+func (queryTracer) TraceCopyFromEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceCopyFromEndData) {
+	endSpan(ctx, data.Err)
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/logging"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"go.uber.org/zap"
+)
+
+type txKey struct{}
+
+const (
+	// serializationFailure and deadlockDetected are SQLSTATE of transactions which can succeed if retried
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+
+	// txAttempts is maximum number of attempts to run transaction
+	txAttempts = 3
+	// txRetryDelay is delay before second attempt, it doubles with every next attempt
+	txRetryDelay = 10 * time.Millisecond
+)
+
+// WithinTx runs fn in transaction. Repository methods called with context passed to fn
+// use the transaction, it is committed if fn returns nil and rolled back otherwise.
+// Nested calls join outer transaction. Transaction failed on serialization failure or
+// deadlock is run again, so fn must not have side effects outside of database.
+func (c *PostgresClient) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return fn(ctx)
+	}
+	return retryTx(ctx, txAttempts, txRetryDelay, func() error {
+		return c.runTx(ctx, nil, fn)
+	})
+}
+
+// retryTx runs attempt until it succeeds, fails with error which is not retryable or attempts are exhausted
+func retryTx(ctx context.Context, attempts int, delay time.Duration, attempt func() error) error {
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			if ctx.Err() != nil {
+				return err
+			}
+			logging.FromContext(ctx).Info("transaction conflicted with concurrent one, retrying",
+				zap.Int("attempt", i+1), zap.Duration("delay", delay), zap.Error(err))
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+		err = attempt()
+		if !isRetryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// isRetryable checks if transaction failed because of conflict with concurrent transaction
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == serializationFailure || pgErr.Code == deadlockDetected)
+}
+
+// runTx runs fn in new transaction with options opts, nil opts are default options
+func (c *PostgresClient) runTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error {
+	tx, err := c.db.BeginTxx(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("can not begin transaction: %w", err)
+	}
+	err = fn(context.WithValue(ctx, txKey{}, tx))
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("can not commit transaction: %w", err)
+	}
+	return nil
+}
+
+// conn returns transaction from context or database if context has no transaction
+func (c *PostgresClient) conn(ctx context.Context) sqlx.ExtContext {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return tx
+	}
+	return c.db
+}
+
+// forUpdate returns locking clause of rows read to be updated. Rows selected in transaction are locked
+// until it ends, so that concurrent read-modify-write of the same row waits instead of losing update.
+// Outside of transaction it is empty.
+func forUpdate(ctx context.Context) string {
+	if _, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return " FOR UPDATE"
+	}
+	return ""
+}
+
+// namedGet is sqlx.GetContext for query with named parameters
+func namedGet(ctx context.Context, q sqlx.ExtContext, dest any, query string, arg any) error {
+	query, args, err := sqlx.Named(query, arg)
+	if err != nil {
+		return err
+	}
+	return sqlx.GetContext(ctx, q, dest, q.Rebind(query), args...)
+}
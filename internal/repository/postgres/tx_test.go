@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryTx(t *testing.T) {
+	errOther := errors.New("other")
+	serialization := fmt.Errorf("can not commit transaction: %w", &pgconn.PgError{Code: serializationFailure})
+	deadlock := &pgconn.PgError{Code: deadlockDetected}
+
+	testCases := []struct {
+		name     string
+		errs     []error
+		err      error
+		attempts int
+	}{
+		{
+			name:     "success",
+			errs:     []error{nil},
+			attempts: 1,
+		},
+		{
+			name:     "not retryable error",
+			errs:     []error{errOther},
+			err:      errOther,
+			attempts: 1,
+		},
+		{
+			name:     "success after conflicts",
+			errs:     []error{serialization, deadlock, nil},
+			attempts: 3,
+		},
+		{
+			name:     "attempts exhausted",
+			errs:     []error{deadlock, serialization, deadlock, nil},
+			err:      deadlock,
+			attempts: 3,
+		},
+		{
+			name:     "unique violation is not retried",
+			errs:     []error{&pgconn.PgError{Code: uniqueViolation}},
+			err:      &pgconn.PgError{Code: uniqueViolation},
+			attempts: 1,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := retryTx(context.Background(), 3, 0, func() error {
+				err := tt.errs[attempts]
+				attempts++
+				return err
+			})
+			require.Equal(t, tt.err, err)
+			require.Equal(t, tt.attempts, attempts)
+		})
+	}
+}
+
+func TestRetryTxCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts := 0
+	err := retryTx(ctx, 3, 0, func() error {
+		attempts++
+		return &pgconn.PgError{Code: serializationFailure}
+	})
+	require.True(t, isRetryable(err))
+	require.Equal(t, 1, attempts)
+}
@@ -2,61 +2,195 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/jmoiron/sqlx"
 )
 
 type user struct {
-	ID        int64
-	Username  string
-	Email     string
-	Password  string
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+	ID          int64
+	Username    string
+	Email       string
+	Password    string
+	Role        string
+	OIDCSubject sql.NullString `db:"oidc_subject"`
+	CreatedAt   time.Time      `db:"created_at"`
+	UpdatedAt   time.Time      `db:"updated_at"`
+
+	PendingEmail               sql.NullString `db:"pending_email"`
+	EmailVerificationToken     sql.NullString `db:"email_verification_token"`
+	EmailVerificationExpiresAt sql.NullTime   `db:"email_verification_expires_at"`
+
+	Disabled               bool
+	PasswordResetToken     sql.NullString `db:"password_reset_token"`
+	PasswordResetExpiresAt sql.NullTime   `db:"password_reset_expires_at"`
 }
 
 func userFromDomain(u domain.User) user {
+	role := u.Role
+	if role == "" {
+		role = domain.RoleUser
+	}
 	return user{
-		Username: u.Username,
-		Email:    u.Email,
-		Password: u.Password,
+		ID:          u.ID,
+		Username:    u.Username,
+		Email:       u.Email,
+		Password:    u.Password,
+		Role:        string(role),
+		OIDCSubject: sql.NullString{String: u.OIDCSubject, Valid: u.OIDCSubject != ""},
+
+		PendingEmail:               sql.NullString{String: u.PendingEmail, Valid: u.PendingEmail != ""},
+		EmailVerificationToken:     sql.NullString{String: u.EmailVerificationToken, Valid: u.EmailVerificationToken != ""},
+		EmailVerificationExpiresAt: sql.NullTime{Time: u.EmailVerificationExpiresAt, Valid: !u.EmailVerificationExpiresAt.IsZero()},
+
+		Disabled:               u.Disabled,
+		PasswordResetToken:     sql.NullString{String: u.PasswordResetToken, Valid: u.PasswordResetToken != ""},
+		PasswordResetExpiresAt: sql.NullTime{Time: u.PasswordResetExpiresAt, Valid: !u.PasswordResetExpiresAt.IsZero()},
 	}
 }
 
 func (u user) userToDomain() domain.User {
 	return domain.User{
-		ID:       u.ID,
-		Username: u.Username,
-		Email:    u.Email,
-		Password: u.Password,
+		ID:          u.ID,
+		Username:    u.Username,
+		Email:       u.Email,
+		Password:    u.Password,
+		Role:        domain.Role(u.Role),
+		OIDCSubject: u.OIDCSubject.String,
+
+		PendingEmail:               u.PendingEmail.String,
+		EmailVerificationToken:     u.EmailVerificationToken.String,
+		EmailVerificationExpiresAt: u.EmailVerificationExpiresAt.Time,
+
+		Disabled:               u.Disabled,
+		PasswordResetToken:     u.PasswordResetToken.String,
+		PasswordResetExpiresAt: u.PasswordResetExpiresAt.Time,
 	}
 }
 
-// CreateUser creates new user
+// CreateUser creates new user and adds it to default organization.
+// Users from identity provider are provisioned just in time: if user with the same
+// oidc subject already exists, its email is refreshed from provider. Role is kept, it is mapped
+// from provider groups only for new user and is managed by admins afterwards.
 func (c *PostgresClient) CreateUser(ctx context.Context, u domain.User) (*domain.User, error) {
 	createUser := userFromDomain(u)
-	stmt, err := c.db.PrepareNamedContext(ctx, `INSERT INTO users (username,email, password) 
-	VALUES (:username, :email, :password) RETURNING *`)
-	if err != nil {
-		return nil, err
-	}
 	var newUser user
-	err = stmt.GetContext(ctx, &newUser, createUser)
+	err := namedGet(ctx, c.conn(ctx), &newUser, `WITH u AS (
+		INSERT INTO users (username,email, password, role, oidc_subject) 
+		VALUES (:username, :email, :password, :role, :oidc_subject)
+		ON CONFLICT (oidc_subject) DO UPDATE SET email=EXCLUDED.email, updated_at=NOW()
+		RETURNING *
+	), m AS (
+		INSERT INTO organization_members (organization_id, user_id) SELECT 1, id FROM u
+		ON CONFLICT DO NOTHING
+	)
+	SELECT * FROM u`, createUser)
 	domainUser := newUser.userToDomain()
+	if isUniqueViolation(err) {
+		err = domain.ErrUserExists
+	}
 	if err != nil {
 		return nil, fmt.Errorf("can not create user: %w", err)
 	}
 	return &domainUser, nil
 }
 
+// GetUserByEmail gets user by email
 func (c *PostgresClient) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
 	var u user
-	err := c.db.GetContext(ctx, &u, "SELECT * FROM users WHERE email=$1", email)
+	err := sqlx.GetContext(ctx, c.conn(ctx), &u, "SELECT * FROM users WHERE email=$1", email)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = domain.ErrUserNotFound
+	}
 	if err != nil {
 		return nil, fmt.Errorf("can not get user by email: %w", err)
 	}
 	domainUser := u.userToDomain()
 	return &domainUser, nil
 }
+
+// GetUserByID gets user by id, in transaction user is locked until it ends
+func (c *PostgresClient) GetUserByID(ctx context.Context, id int64) (*domain.User, error) {
+	var u user
+	err := sqlx.GetContext(ctx, c.conn(ctx), &u, "SELECT * FROM users WHERE id=$1"+forUpdate(ctx), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can not get user by id: %w", err)
+	}
+	domainUser := u.userToDomain()
+	return &domainUser, nil
+}
+
+// GetUserByPasswordResetToken gets user by hash of password reset token, in transaction user is locked until it ends
+func (c *PostgresClient) GetUserByPasswordResetToken(ctx context.Context, token string) (*domain.User, error) {
+	var u user
+	err := sqlx.GetContext(ctx, c.conn(ctx), &u, "SELECT * FROM users WHERE password_reset_token=$1"+forUpdate(ctx), token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can not get user by password reset token: %w", err)
+	}
+	domainUser := u.userToDomain()
+	return &domainUser, nil
+}
+
+// SelectUsers selects users matching filter ordered by id
+func (c *PostgresClient) SelectUsers(ctx context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	var users []user
+	err := sqlx.SelectContext(ctx, c.conn(ctx), &users, `SELECT * FROM users 
+	WHERE $1 = '' OR username ILIKE '%' || $1 || '%' OR email ILIKE '%' || $1 || '%'
+	ORDER BY id LIMIT $2 OFFSET $3`, filter.Query, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("can not select users: %w", err)
+	}
+	result := make([]domain.User, 0, len(users))
+	for _, u := range users {
+		result = append(result, u.userToDomain())
+	}
+	return result, nil
+}
+
+// UpdateUser updates user by id
+func (c *PostgresClient) UpdateUser(ctx context.Context, u domain.User) (*domain.User, error) {
+	updateUser := userFromDomain(u)
+	var updated user
+	err := namedGet(ctx, c.conn(ctx), &updated, `UPDATE users SET username=:username, email=:email, password=:password,
+	role=:role, pending_email=:pending_email, email_verification_token=:email_verification_token,
+	email_verification_expires_at=:email_verification_expires_at, disabled=:disabled,
+	password_reset_token=:password_reset_token, password_reset_expires_at=:password_reset_expires_at, updated_at=NOW()
+	WHERE id=:id RETURNING *`, updateUser)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrUserNotFound
+	}
+	if isUniqueViolation(err) {
+		err = domain.ErrUserExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can not update user: %w", err)
+	}
+	domainUser := updated.userToDomain()
+	return &domainUser, nil
+}
+
+// DeleteUser deletes user by id
+func (c *PostgresClient) DeleteUser(ctx context.Context, id int64) error {
+	res, err := c.conn(ctx).ExecContext(ctx, "DELETE FROM users WHERE id=$1", id)
+	if err != nil {
+		return fmt.Errorf("can not delete user: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("can not delete user: %w", err)
+	}
+	if n == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
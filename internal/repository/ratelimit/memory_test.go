@@ -0,0 +1,48 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryTake(t *testing.T) {
+	ctx := context.Background()
+	store := ratelimit.NewMemory()
+	limit := domain.RateLimit{Requests: 2, Period: time.Second, Burst: 3}
+	now := time.Now()
+
+	// burst is allowed at once
+	for i := 2; i >= 0; i-- {
+		result, err := store.Take(ctx, "key", limit, now)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+		require.Equal(t, 3, result.Limit)
+		require.Equal(t, i, result.Remaining)
+	}
+	result, err := store.Take(ctx, "key", limit, now)
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	require.Equal(t, 1500*time.Millisecond, result.Reset)
+
+	// other keys have their own buckets
+	result, err = store.Take(ctx, "other", limit, now)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+
+	// bucket is refilled at rate of limit
+	result, err = store.Take(ctx, "key", limit, now.Add(500*time.Millisecond))
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, 0, result.Remaining)
+
+	// full buckets are dropped
+	_, err = store.Take(ctx, "new", limit, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, store.Len())
+}
@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript refills bucket stored in hash and takes token from it atomically. Bucket expires once
+// it is full again, as missing bucket is the same as full one.
+var takeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call("HMGET", KEYS[1], "tokens", "updated_at")
+local tokens = tonumber(state[1]) or capacity
+local updated_at = tonumber(state[2]) or now
+if now > updated_at then
+	tokens = tokens + (now - updated_at) * rate
+else
+	now = updated_at
+end
+tokens = math.min(tokens, capacity)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "updated_at", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil((capacity - tokens) / rate) + 1)
+return {allowed, tostring(tokens)}
+`)
+
+// Redis keeps token buckets in Redis, so that requests are limited across instances of server
+type Redis struct {
+	client *redis.Client
+	prefix string
+}
+
+// RedisSettings are settings of Redis store
+type RedisSettings struct {
+	Addr     string
+	Password string
+	DB       int
+	// Prefix is prepended to all keys
+	Prefix string
+}
+
+// NewRedis creates Redis store and checks connection
+func NewRedis(ctx context.Context, settings RedisSettings) (*Redis, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     settings.Addr,
+		Password: settings.Password,
+		DB:       settings.DB,
+	})
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return &Redis{client: client, prefix: settings.Prefix}, nil
+}
+
+// Take takes token from bucket of key refilled as of now. Time of bucket is given by server, so that clocks
+// of instances must be in sync.
+func (r *Redis) Take(ctx context.Context, key string, limit domain.RateLimit, now time.Time) (domain.RateLimitResult, error) {
+	// rate is per millisecond as time is stored in milliseconds
+	rate := limit.Rate() / 1000
+	res, err := takeScript.Run(ctx, r.client, []string{r.prefix + key}, limit.Capacity(), rate, now.UnixMilli()).Slice()
+	if err != nil {
+		return domain.RateLimitResult{}, fmt.Errorf("can not take token: %w", err)
+	}
+	if len(res) != 2 {
+		return domain.RateLimitResult{}, fmt.Errorf("unexpected result of script: %v", res)
+	}
+	allowed, _ := res[0].(int64)
+	tokensStr, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return domain.RateLimitResult{}, fmt.Errorf("can not parse tokens: %w", err)
+	}
+	return limit.Result(tokens, allowed == 1), nil
+}
+
+// Close closes connection to Redis
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
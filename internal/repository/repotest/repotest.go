@@ -0,0 +1,679 @@
+// Package repotest is conformance test suite of repositories. Every storage backend must pass it,
+// so that services behave the same whichever backend is configured.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/service"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// Repository is storage backend implementing all repositories
+type Repository interface {
+	service.CompanyRepository
+	service.UserRepository
+	service.OrganizationRepository
+	service.CompanyPurger
+	service.CompanyCounter
+}
+
+// Run runs conformance tests, newRepository must return empty repository for every test
+func Run(t *testing.T, newRepository func(t *testing.T) Repository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo Repository)
+	}{
+		{"Company/CreateAndGet", testCreateAndGetCompany},
+		{"Company/NoTenant", testCompanyNoTenant},
+		{"Company/UniqueName", testCompanyUniqueName},
+		{"Company/TenantIsolation", testCompanyTenantIsolation},
+		{"Company/Update", testUpdateCompany},
+		{"Company/DeleteAndRestore", testDeleteAndRestoreCompany},
+		{"Company/Select", testSelectCompanies},
+		{"Company/History", testCompanyHistory},
+		{"Company/AsOf", testCompanyAsOf},
+		{"Company/TxRollback", testTxRollback},
+		{"Tx/RollbackAcrossRepositories", testTxRollbackAcrossRepositories},
+		{"Company/ConcurrentCreate", testConcurrentCreateCompany},
+		{"Company/Import", testImportCompanies},
+		{"Company/Export", testExportCompanies},
+		{"Company/Purge", testPurgeCompanies},
+		{"Company/CountByType", testCountCompaniesByType},
+		{"User/CreateAndGet", testCreateAndGetUser},
+		{"User/Unique", testUserUnique},
+		{"User/OIDCProvisioning", testOIDCProvisioning},
+		{"User/Update", testUpdateUser},
+		{"User/Select", testSelectUsers},
+		{"User/Delete", testDeleteUser},
+		{"Organization/Create", testCreateOrganization},
+		{"Organization/Members", testOrganizationMembers},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepository(t))
+		})
+	}
+}
+
+func tenantCtx(organizationID int64) context.Context {
+	return domain.WithTenant(context.Background(), organizationID)
+}
+
+func newCompany(name string) domain.Company {
+	return domain.Company{
+		Name:              name,
+		Description:       "description of " + name,
+		AmountOfEmployees: 10,
+		Registered:        true,
+		Type:              domain.Corporations,
+	}
+}
+
+func createCompany(t *testing.T, ctx context.Context, repo Repository, name string) domain.Company {
+	cmp := newCompany(name)
+	id, err := repo.CreateCompany(ctx, cmp)
+	require.NoError(t, err)
+	require.NotEqual(t, uuid.Nil, id)
+	cmp.ID = id
+	return cmp
+}
+
+func newUser(name string) domain.User {
+	return domain.User{
+		Username: name,
+		Email:    name + "@example.com",
+		Password: "hash of " + name,
+	}
+}
+
+func createUser(t *testing.T, repo Repository, name string) *domain.User {
+	u, err := repo.CreateUser(context.Background(), newUser(name))
+	require.NoError(t, err)
+	return u
+}
+
+func testCreateAndGetCompany(t *testing.T, repo Repository) {
+	ctx := tenantCtx(domain.DefaultOrganizationID)
+	cmp := createCompany(t, ctx, repo, "acme")
+
+	got, err := repo.GetCompany(ctx, cmp.ID)
+	require.NoError(t, err)
+	require.Equal(t, cmp, got)
+
+	_, err = repo.GetCompany(ctx, uuid.New())
+	require.ErrorIs(t, err, domain.ErrCompanyNotFound)
+}
+
+func testCompanyNoTenant(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	_, err := repo.CreateCompany(ctx, newCompany("acme"))
+	require.ErrorIs(t, err, domain.ErrNoTenant)
+	_, err = repo.GetCompany(ctx, uuid.New())
+	require.ErrorIs(t, err, domain.ErrNoTenant)
+	_, err = repo.SelectCompanies(ctx, domain.CompanyFilter{Limit: 10})
+	require.ErrorIs(t, err, domain.ErrNoTenant)
+	require.ErrorIs(t, repo.DeleteCompany(ctx, uuid.New()), domain.ErrNoTenant)
+}
+
+func testCompanyUniqueName(t *testing.T, repo Repository) {
+	org, err := repo.CreateOrganization(context.Background(), "other")
+	require.NoError(t, err)
+	ctx := tenantCtx(domain.DefaultOrganizationID)
+	first := createCompany(t, ctx, repo, "acme")
+
+	_, err = repo.CreateCompany(ctx, newCompany("acme"))
+	require.ErrorIs(t, err, domain.ErrCompanyExists)
+
+	// names are unique within organization
+	createCompany(t, tenantCtx(org.ID), repo, "acme")
+
+	// names of deleted companies can be reused, but deleted company can not be restored then
+	require.NoError(t, repo.DeleteCompany(ctx, first.ID))
+	createCompany(t, ctx, repo, "acme")
+	require.ErrorIs(t, repo.RestoreCompany(ctx, first.ID), domain.ErrCompanyExists)
+}
+
+func testCompanyTenantIsolation(t *testing.T, repo Repository) {
+	org, err := repo.CreateOrganization(context.Background(), "other")
+	require.NoError(t, err)
+	ctx := tenantCtx(domain.DefaultOrganizationID)
+	otherCtx := tenantCtx(org.ID)
+	cmp := createCompany(t, ctx, repo, "acme")
+
+	_, err = repo.GetCompany(otherCtx, cmp.ID)
+	require.ErrorIs(t, err, domain.ErrCompanyNotFound)
+	require.ErrorIs(t, repo.UpdateCompany(otherCtx, cmp.ID, newCompany("hijacked")), domain.ErrCompanyNotFound)
+	require.ErrorIs(t, repo.DeleteCompany(otherCtx, cmp.ID), domain.ErrCompanyNotFound)
+
+	companies, err := repo.SelectCompanies(otherCtx, domain.CompanyFilter{Limit: 10, IncludeDeleted: true})
+	require.NoError(t, err)
+	require.Empty(t, companies)
+	history, err := repo.SelectCompanyHistory(otherCtx, cmp.ID)
+	require.NoError(t, err)
+	require.Empty(t, history)
+}
+
+func testUpdateCompany(t *testing.T, repo Repository) {
+	ctx := tenantCtx(domain.DefaultOrganizationID)
+	cmp := createCompany(t, ctx, repo, "acme")
+	createCompany(t, ctx, repo, "globex")
+
+	updated := domain.Company{
+		Name:              "acme corp",
+		Description:       "new description",
+		AmountOfEmployees: 20,
+		Registered:        false,
+		Type:              domain.NonProfit,
+	}
+	require.NoError(t, repo.UpdateCompany(ctx, cmp.ID, updated))
+	got, err := repo.GetCompany(ctx, cmp.ID)
+	require.NoError(t, err)
+	updated.ID = cmp.ID
+	require.Equal(t, updated, got)
+
+	// company keeps its own name
+	require.NoError(t, repo.UpdateCompany(ctx, cmp.ID, updated))
+	require.ErrorIs(t, repo.UpdateCompany(ctx, cmp.ID, newCompany("globex")), domain.ErrCompanyExists)
+	require.ErrorIs(t, repo.UpdateCompany(ctx, uuid.New(), updated), domain.ErrCompanyNotFound)
+}
+
+func testDeleteAndRestoreCompany(t *testing.T, repo Repository) {
+	ctx := tenantCtx(domain.DefaultOrganizationID)
+	cmp := createCompany(t, ctx, repo, "acme")
+
+	require.ErrorIs(t, repo.RestoreCompany(ctx, cmp.ID), domain.ErrCompanyNotFound)
+	require.NoError(t, repo.DeleteCompany(ctx, cmp.ID))
+	require.ErrorIs(t, repo.DeleteCompany(ctx, cmp.ID), domain.ErrCompanyNotFound)
+	_, err := repo.GetCompany(ctx, cmp.ID)
+	require.ErrorIs(t, err, domain.ErrCompanyNotFound)
+	require.ErrorIs(t, repo.UpdateCompany(ctx, cmp.ID, newCompany("acme")), domain.ErrCompanyNotFound)
+
+	companies, err := repo.SelectCompanies(ctx, domain.CompanyFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, companies)
+	companies, err = repo.SelectCompanies(ctx, domain.CompanyFilter{Limit: 10, IncludeDeleted: true})
+	require.NoError(t, err)
+	require.Len(t, companies, 1)
+	require.NotNil(t, companies[0].DeletedAt)
+
+	require.NoError(t, repo.RestoreCompany(ctx, cmp.ID))
+	got, err := repo.GetCompany(ctx, cmp.ID)
+	require.NoError(t, err)
+	require.Equal(t, cmp, got)
+	require.ErrorIs(t, repo.DeleteCompany(ctx, uuid.New()), domain.ErrCompanyNotFound)
+}
+
+func testSelectCompanies(t *testing.T, repo Repository) {
+	ctx := tenantCtx(domain.DefaultOrganizationID)
+	var created []domain.Company
+	for i := 0; i < 5; i++ {
+		created = append(created, createCompany(t, ctx, repo, fmt.Sprintf("company-%d", i)))
+	}
+
+	var selected []domain.Company
+	for offset := 0; offset < 6; offset += 2 {
+		page, err := repo.SelectCompanies(ctx, domain.CompanyFilter{Limit: 2, Offset: offset})
+		require.NoError(t, err)
+		selected = append(selected, page...)
+	}
+	require.Equal(t, created, selected)
+
+	page, err := repo.SelectCompanies(ctx, domain.CompanyFilter{Limit: 10, Offset: 10})
+	require.NoError(t, err)
+	require.NotNil(t, page)
+	require.Empty(t, page)
+}
+
+func testCompanyHistory(t *testing.T, repo Repository) {
+	ctx := domain.WithRequestID(domain.WithActor(tenantCtx(domain.DefaultOrganizationID), 42), "request-1")
+	cmp := createCompany(t, ctx, repo, "acme")
+	updated := newCompany("acme corp")
+	require.NoError(t, repo.UpdateCompany(ctx, cmp.ID, updated))
+	require.NoError(t, repo.DeleteCompany(ctx, cmp.ID))
+	require.NoError(t, repo.RestoreCompany(ctx, cmp.ID))
+	updated.ID = cmp.ID
+
+	history, err := repo.SelectCompanyHistory(ctx, cmp.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 4)
+	actions := []domain.CompanyAction{domain.CompanyCreated, domain.CompanyUpdated, domain.CompanyDeleted, domain.CompanyRestored}
+	befores := []*domain.Company{nil, &cmp, &updated, nil}
+	afters := []*domain.Company{&cmp, &updated, nil, &updated}
+	for i, change := range history {
+		require.Equal(t, cmp.ID, change.CompanyID)
+		require.Equal(t, actions[i], change.Action)
+		require.Equal(t, befores[i], change.Before, "before of %s", change.Action)
+		require.Equal(t, afters[i], change.After, "after of %s", change.Action)
+		require.Equal(t, int64(42), change.ActorID)
+		require.Equal(t, "request-1", change.RequestID)
+		if i > 0 {
+			require.Greater(t, change.ID, history[i-1].ID)
+		}
+	}
+
+	history, err = repo.SelectCompanyHistory(ctx, uuid.New())
+	require.NoError(t, err)
+	require.Empty(t, history)
+}
+
+func testCompanyAsOf(t *testing.T, repo Repository) {
+	ctx := tenantCtx(domain.DefaultOrganizationID)
+	tick := func() time.Time {
+		time.Sleep(20 * time.Millisecond)
+		now := time.Now()
+		time.Sleep(20 * time.Millisecond)
+		return now
+	}
+
+	beforeCreate := tick()
+	cmp := createCompany(t, ctx, repo, "acme")
+	afterCreate := tick()
+	updated := newCompany("acme corp")
+	require.NoError(t, repo.UpdateCompany(ctx, cmp.ID, updated))
+	afterUpdate := tick()
+	require.NoError(t, repo.DeleteCompany(ctx, cmp.ID))
+	afterDelete := tick()
+	updated.ID = cmp.ID
+
+	_, err := repo.GetCompanyAsOf(ctx, cmp.ID, beforeCreate)
+	require.ErrorIs(t, err, domain.ErrCompanyNotFound)
+	got, err := repo.GetCompanyAsOf(ctx, cmp.ID, afterCreate)
+	require.NoError(t, err)
+	require.Equal(t, cmp, got)
+	got, err = repo.GetCompanyAsOf(ctx, cmp.ID, afterUpdate)
+	require.NoError(t, err)
+	require.Equal(t, updated, got)
+	_, err = repo.GetCompanyAsOf(ctx, cmp.ID, afterDelete)
+	require.ErrorIs(t, err, domain.ErrCompanyNotFound)
+
+	// moment is the same in any time zone
+	ahead := time.FixedZone("UTC+14", 14*60*60)
+	behind := time.FixedZone("UTC-12", -12*60*60)
+	_, err = repo.GetCompanyAsOf(ctx, cmp.ID, beforeCreate.In(ahead))
+	require.ErrorIs(t, err, domain.ErrCompanyNotFound)
+	got, err = repo.GetCompanyAsOf(ctx, cmp.ID, afterCreate.In(ahead))
+	require.NoError(t, err)
+	require.Equal(t, cmp, got)
+	got, err = repo.GetCompanyAsOf(ctx, cmp.ID, afterUpdate.In(behind))
+	require.NoError(t, err)
+	require.Equal(t, updated, got)
+}
+
+func testTxRollback(t *testing.T, repo Repository) {
+	ctx := tenantCtx(domain.DefaultOrganizationID)
+	errRollback := errors.New("rollback")
+	var id uuid.UUID
+	err := repo.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		id, err = repo.CreateCompany(ctx, newCompany("acme"))
+		require.NoError(t, err)
+		// changes are visible inside transaction
+		_, err = repo.GetCompany(ctx, id)
+		require.NoError(t, err)
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+	_, err = repo.GetCompany(ctx, id)
+	require.ErrorIs(t, err, domain.ErrCompanyNotFound)
+
+	err = repo.WithinTx(ctx, func(ctx context.Context) error {
+		id, err = repo.CreateCompany(ctx, newCompany("acme"))
+		return err
+	})
+	require.NoError(t, err)
+	_, err = repo.GetCompany(ctx, id)
+	require.NoError(t, err)
+}
+
+func testTxRollbackAcrossRepositories(t *testing.T, repo Repository) {
+	ctx := tenantCtx(domain.DefaultOrganizationID)
+	existing := createUser(t, repo, "alice")
+	errRollback := errors.New("rollback")
+	var userID, orgID int64
+	var companyID uuid.UUID
+	err := repo.WithinTx(ctx, func(ctx context.Context) error {
+		u := newUser("bob")
+		created, err := repo.CreateUser(ctx, u)
+		require.NoError(t, err)
+		userID = created.ID
+		org, err := repo.CreateOrganization(ctx, "acme")
+		require.NoError(t, err)
+		orgID = org.ID
+		require.NoError(t, repo.AddOrganizationMember(ctx, orgID, existing.ID))
+		existing.Role = domain.RoleAdmin
+		_, err = repo.UpdateUser(ctx, *existing)
+		require.NoError(t, err)
+		companyID, err = repo.CreateCompany(ctx, newCompany("acme"))
+		require.NoError(t, err)
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+
+	_, err = repo.GetUserByID(ctx, userID)
+	require.ErrorIs(t, err, domain.ErrUserNotFound)
+	orgs, err := repo.SelectOrganizations(ctx)
+	require.NoError(t, err)
+	require.Len(t, orgs, 1)
+	member, err := repo.IsOrganizationMember(ctx, orgID, existing.ID)
+	require.NoError(t, err)
+	require.False(t, member)
+	got, err := repo.GetUserByID(ctx, existing.ID)
+	require.NoError(t, err)
+	require.Equal(t, domain.RoleUser, got.Role)
+	_, err = repo.GetCompany(ctx, companyID)
+	require.ErrorIs(t, err, domain.ErrCompanyNotFound)
+}
+
+func testConcurrentCreateCompany(t *testing.T, repo Repository) {
+	ctx := tenantCtx(domain.DefaultOrganizationID)
+	const attempts = 10
+	errs := make([]error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = repo.CreateCompany(ctx, newCompany("acme"))
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		require.ErrorIs(t, err, domain.ErrCompanyExists)
+	}
+	require.Equal(t, 1, created)
+}
+
+// sliceSource is source of companies from slice
+type sliceSource struct {
+	companies []domain.Company
+	current   int
+}
+
+func (s *sliceSource) Next() bool {
+	s.current++
+	return s.current <= len(s.companies)
+}
+
+func (s *sliceSource) Company() domain.Company {
+	return s.companies[s.current-1]
+}
+
+func (s *sliceSource) Err() error {
+	return nil
+}
+
+func testImportCompanies(t *testing.T, repo Repository) {
+	ctx := tenantCtx(domain.DefaultOrganizationID)
+	existing := createCompany(t, ctx, repo, "acme")
+
+	changed := newCompany("acme")
+	changed.AmountOfEmployees = 500
+	first := newCompany("globex")
+	last := newCompany("globex")
+	last.Description = "last one wins"
+	result, err := repo.ImportCompanies(ctx, &sliceSource{companies: []domain.Company{changed, first, last}})
+	require.NoError(t, err)
+	require.Equal(t, domain.ImportResult{Created: 1, Updated: 1}, result)
+
+	got, err := repo.GetCompany(ctx, existing.ID)
+	require.NoError(t, err)
+	require.Equal(t, 500, got.AmountOfEmployees)
+	companies, err := repo.SelectCompanies(ctx, domain.CompanyFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, companies, 2)
+	require.Equal(t, "last one wins", companies[1].Description)
+}
+
+func testExportCompanies(t *testing.T, repo Repository) {
+	ctx := tenantCtx(domain.DefaultOrganizationID)
+	var created []domain.Company
+	for i := 0; i < 3; i++ {
+		created = append(created, createCompany(t, ctx, repo, fmt.Sprintf("company-%d", i)))
+	}
+	require.NoError(t, repo.DeleteCompany(ctx, created[2].ID))
+
+	var exported []domain.Company
+	err := repo.ExportCompanies(ctx, domain.CompanyFilter{}, func(cmp domain.Company) error {
+		exported = append(exported, cmp)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, created[:2], exported)
+
+	count := 0
+	err = repo.ExportCompanies(ctx, domain.CompanyFilter{Offset: 1, IncludeDeleted: true}, func(domain.Company) error {
+		count++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	errStop := errors.New("stop")
+	err = repo.ExportCompanies(ctx, domain.CompanyFilter{}, func(domain.Company) error {
+		return errStop
+	})
+	require.ErrorIs(t, err, errStop)
+}
+
+func testPurgeCompanies(t *testing.T, repo Repository) {
+	ctx := tenantCtx(domain.DefaultOrganizationID)
+	kept := createCompany(t, ctx, repo, "kept")
+	purged := createCompany(t, ctx, repo, "purged")
+	require.NoError(t, repo.DeleteCompany(ctx, purged.ID))
+
+	n, err := repo.PurgeCompanies(context.Background(), time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, n)
+	n, err = repo.PurgeCompanies(context.Background(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	companies, err := repo.SelectCompanies(ctx, domain.CompanyFilter{Limit: 10, IncludeDeleted: true})
+	require.NoError(t, err)
+	require.Equal(t, []domain.Company{kept}, companies)
+}
+
+func testCountCompaniesByType(t *testing.T, repo Repository) {
+	counts, err := repo.CountCompaniesByType(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, counts)
+
+	ctx := tenantCtx(domain.DefaultOrganizationID)
+	createCompany(t, ctx, repo, "acme")
+	deleted := createCompany(t, ctx, repo, "deleted")
+	require.NoError(t, repo.DeleteCompany(ctx, deleted.ID))
+	nonProfit := newCompany("charity")
+	nonProfit.Type = domain.NonProfit
+	_, err = repo.CreateCompany(ctx, nonProfit)
+	require.NoError(t, err)
+	org, err := repo.CreateOrganization(context.Background(), "other")
+	require.NoError(t, err)
+	createCompany(t, tenantCtx(org.ID), repo, "acme")
+
+	// companies of all organizations are counted
+	counts, err = repo.CountCompaniesByType(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, map[domain.CompanyType]int64{domain.Corporations: 2, domain.NonProfit: 1}, counts)
+}
+
+func testCreateAndGetUser(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	u := createUser(t, repo, "alice")
+	require.NotZero(t, u.ID)
+	require.Equal(t, "alice", u.Username)
+	require.Equal(t, domain.RoleUser, u.Role)
+
+	got, err := repo.GetUserByID(ctx, u.ID)
+	require.NoError(t, err)
+	require.Equal(t, u, got)
+	got, err = repo.GetUserByEmail(ctx, u.Email)
+	require.NoError(t, err)
+	require.Equal(t, u, got)
+
+	// new users join default organization
+	member, err := repo.IsOrganizationMember(ctx, domain.DefaultOrganizationID, u.ID)
+	require.NoError(t, err)
+	require.True(t, member)
+
+	_, err = repo.GetUserByID(ctx, u.ID+100)
+	require.ErrorIs(t, err, domain.ErrUserNotFound)
+	_, err = repo.GetUserByEmail(ctx, "nobody@example.com")
+	require.ErrorIs(t, err, domain.ErrUserNotFound)
+	_, err = repo.GetUserByPasswordResetToken(ctx, "unknown")
+	require.ErrorIs(t, err, domain.ErrUserNotFound)
+}
+
+func testUserUnique(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	createUser(t, repo, "alice")
+
+	sameEmail := newUser("bob")
+	sameEmail.Email = "alice@example.com"
+	_, err := repo.CreateUser(ctx, sameEmail)
+	require.ErrorIs(t, err, domain.ErrUserExists)
+
+	sameUsername := newUser("alice")
+	sameUsername.Email = "other@example.com"
+	_, err = repo.CreateUser(ctx, sameUsername)
+	require.ErrorIs(t, err, domain.ErrUserExists)
+}
+
+func testOIDCProvisioning(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	u := newUser("alice")
+	u.OIDCSubject = "subject-1"
+	first, err := repo.CreateUser(ctx, u)
+	require.NoError(t, err)
+	first.Role = domain.RoleAdmin
+	_, err = repo.UpdateUser(ctx, *first)
+	require.NoError(t, err)
+
+	u.Email = "alice@new.example.com"
+	u.Role = domain.RoleUser
+	second, err := repo.CreateUser(ctx, u)
+	require.NoError(t, err)
+	require.Equal(t, first.ID, second.ID)
+	require.Equal(t, "alice@new.example.com", second.Email)
+	require.Equal(t, domain.RoleAdmin, second.Role, "role granted by admin is kept")
+	require.Equal(t, "subject-1", second.OIDCSubject)
+
+	orgs, err := repo.SelectUserOrganizations(ctx, first.ID)
+	require.NoError(t, err)
+	require.Len(t, orgs, 1)
+}
+
+func testUpdateUser(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	u := createUser(t, repo, "alice")
+	createUser(t, repo, "bob")
+
+	expiresAt := time.Now().Add(time.Hour)
+	u.Email = "alice@new.example.com"
+	u.Role = domain.RoleAdmin
+	u.Disabled = true
+	u.PasswordResetToken = "token-hash"
+	u.PasswordResetExpiresAt = expiresAt
+	updated, err := repo.UpdateUser(ctx, *u)
+	require.NoError(t, err)
+	require.Equal(t, u.Email, updated.Email)
+	require.Equal(t, domain.RoleAdmin, updated.Role)
+	require.True(t, updated.Disabled)
+	require.WithinDuration(t, expiresAt, updated.PasswordResetExpiresAt, time.Millisecond)
+
+	got, err := repo.GetUserByPasswordResetToken(ctx, "token-hash")
+	require.NoError(t, err)
+	require.Equal(t, u.ID, got.ID)
+
+	u.Email = "bob@example.com"
+	_, err = repo.UpdateUser(ctx, *u)
+	require.ErrorIs(t, err, domain.ErrUserExists)
+	u.ID += 100
+	_, err = repo.UpdateUser(ctx, *u)
+	require.ErrorIs(t, err, domain.ErrUserNotFound)
+}
+
+func testSelectUsers(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	alice := createUser(t, repo, "alice")
+	bob := createUser(t, repo, "bob")
+	carol := createUser(t, repo, "carol")
+
+	users, err := repo.SelectUsers(ctx, domain.UserFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []domain.User{*alice, *bob, *carol}, users)
+
+	users, err = repo.SelectUsers(ctx, domain.UserFilter{Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	require.Equal(t, []domain.User{*bob}, users)
+
+	users, err = repo.SelectUsers(ctx, domain.UserFilter{Query: "CAR", Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, []domain.User{*carol}, users)
+}
+
+func testDeleteUser(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	u := createUser(t, repo, "alice")
+
+	require.NoError(t, repo.DeleteUser(ctx, u.ID))
+	_, err := repo.GetUserByID(ctx, u.ID)
+	require.ErrorIs(t, err, domain.ErrUserNotFound)
+	orgs, err := repo.SelectUserOrganizations(ctx, u.ID)
+	require.NoError(t, err)
+	require.Empty(t, orgs)
+	require.ErrorIs(t, repo.DeleteUser(ctx, u.ID), domain.ErrUserNotFound)
+}
+
+func testCreateOrganization(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	org, err := repo.CreateOrganization(ctx, "other")
+	require.NoError(t, err)
+	require.Equal(t, "other", org.Name)
+
+	_, err = repo.CreateOrganization(ctx, "other")
+	require.ErrorIs(t, err, domain.ErrOrganizationExists)
+
+	orgs, err := repo.SelectOrganizations(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []domain.Organization{{ID: domain.DefaultOrganizationID, Name: "default"}, org}, orgs)
+}
+
+func testOrganizationMembers(t *testing.T, repo Repository) {
+	ctx := context.Background()
+	org, err := repo.CreateOrganization(ctx, "other")
+	require.NoError(t, err)
+	u := createUser(t, repo, "alice")
+
+	member, err := repo.IsOrganizationMember(ctx, org.ID, u.ID)
+	require.NoError(t, err)
+	require.False(t, member)
+
+	require.NoError(t, repo.AddOrganizationMember(ctx, org.ID, u.ID))
+	require.NoError(t, repo.AddOrganizationMember(ctx, org.ID, u.ID))
+	member, err = repo.IsOrganizationMember(ctx, org.ID, u.ID)
+	require.NoError(t, err)
+	require.True(t, member)
+	orgs, err := repo.SelectUserOrganizations(ctx, u.ID)
+	require.NoError(t, err)
+	require.Len(t, orgs, 2)
+
+	require.NoError(t, repo.RemoveOrganizationMember(ctx, org.ID, u.ID))
+	require.ErrorIs(t, repo.RemoveOrganizationMember(ctx, org.ID, u.ID), domain.ErrNotOrganizationMember)
+	orgs, err = repo.SelectUserOrganizations(ctx, u.ID)
+	require.NoError(t, err)
+	require.Equal(t, []domain.Organization{{ID: domain.DefaultOrganizationID, Name: "default"}}, orgs)
+}
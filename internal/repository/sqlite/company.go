@@ -0,0 +1,492 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+type company struct {
+	ID                uuid.UUID     `db:"id"`
+	Name              string        `db:"name"`
+	Description       string        `db:"description"`
+	AmountOfEmployees int           `db:"amount_of_employees"`
+	Registered        bool          `db:"registered"`
+	Type              string        `db:"type"`
+	OrganizationID    int64         `db:"organization_id"`
+	CreatedAt         int64         `db:"created_at"`
+	UpdatedAt         int64         `db:"updated_at"`
+	DeletedAt         sql.NullInt64 `db:"deleted_at"`
+}
+
+// companyHistory is entry of company history
+type companyHistory struct {
+	ID             int64          `db:"id"`
+	CompanyID      uuid.UUID      `db:"company_id"`
+	OrganizationID int64          `db:"organization_id"`
+	Action         string         `db:"action"`
+	Before         sql.NullString `db:"before"`
+	After          sql.NullString `db:"after"`
+	ActorID        sql.NullInt64  `db:"actor_id"`
+	RequestID      sql.NullString `db:"request_id"`
+	CreatedAt      int64          `db:"created_at"`
+}
+
+// companySnapshot is company row stored in history
+type companySnapshot struct {
+	ID                uuid.UUID `json:"id"`
+	Name              string    `json:"name"`
+	Description       string    `json:"description"`
+	AmountOfEmployees int       `json:"amount_of_employees"`
+	Registered        bool      `json:"registered"`
+	Type              string    `json:"type"`
+}
+
+func fromDomain(c domain.Company) company {
+	return company{
+		ID:                c.ID,
+		Name:              c.Name,
+		Description:       c.Description,
+		AmountOfEmployees: c.AmountOfEmployees,
+		Registered:        c.Registered,
+		Type:              string(c.Type),
+	}
+}
+
+func toDomain(c company) domain.Company {
+	cmp := domain.Company{
+		ID:                c.ID,
+		Name:              c.Name,
+		Description:       c.Description,
+		AmountOfEmployees: c.AmountOfEmployees,
+		Registered:        c.Registered,
+		Type:              domain.CompanyType(c.Type),
+	}
+	if c.DeletedAt.Valid {
+		deletedAt := fromNanos(c.DeletedAt.Int64)
+		cmp.DeletedAt = &deletedAt
+	}
+	return cmp
+}
+
+func snapshotOf(c *company) (sql.NullString, error) {
+	if c == nil {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(companySnapshot{
+		ID:                c.ID,
+		Name:              c.Name,
+		Description:       c.Description,
+		AmountOfEmployees: c.AmountOfEmployees,
+		Registered:        c.Registered,
+		Type:              c.Type,
+	})
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func snapshotToDomain(data sql.NullString) (*domain.Company, error) {
+	if !data.Valid {
+		return nil, nil
+	}
+	var snapshot companySnapshot
+	if err := json.Unmarshal([]byte(data.String), &snapshot); err != nil {
+		return nil, err
+	}
+	return &domain.Company{
+		ID:                snapshot.ID,
+		Name:              snapshot.Name,
+		Description:       snapshot.Description,
+		AmountOfEmployees: snapshot.AmountOfEmployees,
+		Registered:        snapshot.Registered,
+		Type:              domain.CompanyType(snapshot.Type),
+	}, nil
+}
+
+func (h companyHistory) toDomain() (domain.CompanyChange, error) {
+	before, err := snapshotToDomain(h.Before)
+	if err != nil {
+		return domain.CompanyChange{}, err
+	}
+	after, err := snapshotToDomain(h.After)
+	if err != nil {
+		return domain.CompanyChange{}, err
+	}
+	return domain.CompanyChange{
+		ID:        h.ID,
+		CompanyID: h.CompanyID,
+		Action:    domain.CompanyAction(h.Action),
+		Before:    before,
+		After:     after,
+		ActorID:   h.ActorID.Int64,
+		RequestID: h.RequestID.String,
+		CreatedAt: fromNanos(h.CreatedAt),
+	}, nil
+}
+
+// record appends change of company to company history
+func (c *SQLiteClient) record(ctx context.Context, action domain.CompanyAction, before, after *company) error {
+	entry := companyHistory{Action: string(action), CreatedAt: nanos(time.Now())}
+	for _, cmp := range []*company{before, after} {
+		if cmp != nil {
+			entry.CompanyID = cmp.ID
+			entry.OrganizationID = cmp.OrganizationID
+		}
+	}
+	var err error
+	if entry.Before, err = snapshotOf(before); err != nil {
+		return err
+	}
+	if entry.After, err = snapshotOf(after); err != nil {
+		return err
+	}
+	actorID := domain.ActorFromContext(ctx)
+	requestID := domain.RequestIDFromContext(ctx)
+	entry.ActorID = sql.NullInt64{Int64: actorID, Valid: actorID != 0}
+	entry.RequestID = sql.NullString{String: requestID, Valid: requestID != ""}
+	_, err = sqlx.NamedExecContext(ctx, c.conn(ctx), `INSERT INTO company_history
+	(company_id, organization_id, action, before, after, actor_id, request_id, created_at)
+	VALUES (:company_id, :organization_id, :action, :before, :after, :actor_id, :request_id, :created_at)`, entry)
+	return err
+}
+
+// getCompany gets company of organization by id, deleted is whether soft deleted company is looked for
+func (c *SQLiteClient) getCompany(ctx context.Context, tenantID int64, id uuid.UUID, deleted bool) (company, error) {
+	var cmp company
+	err := sqlx.GetContext(ctx, c.conn(ctx), &cmp, `SELECT * FROM companies
+	WHERE id=? AND organization_id=? AND (deleted_at IS NOT NULL) = ?`, id, tenantID, deleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return company{}, domain.ErrCompanyNotFound
+	}
+	return cmp, err
+}
+
+// insertCompany inserts company and records it in company history
+func (c *SQLiteClient) insertCompany(ctx context.Context, cmp company) error {
+	now := nanos(time.Now())
+	cmp.CreatedAt, cmp.UpdatedAt = now, now
+	_, err := sqlx.NamedExecContext(ctx, c.conn(ctx), `INSERT INTO companies
+	(id, name, description, amount_of_employees, registered, type, organization_id, created_at, updated_at)
+	VALUES (:id, :name, :description, :amount_of_employees, :registered, :type, :organization_id, :created_at, :updated_at)`, cmp)
+	if isUniqueViolation(err) {
+		return domain.ErrCompanyExists
+	}
+	if err != nil {
+		return err
+	}
+	return c.record(ctx, domain.CompanyCreated, nil, &cmp)
+}
+
+// updateCompany updates not deleted company and records it in company history
+func (c *SQLiteClient) updateCompany(ctx context.Context, before company, cmp company) error {
+	after := before
+	after.Name = cmp.Name
+	after.Description = cmp.Description
+	after.AmountOfEmployees = cmp.AmountOfEmployees
+	after.Registered = cmp.Registered
+	after.Type = cmp.Type
+	after.UpdatedAt = nanos(time.Now())
+	_, err := sqlx.NamedExecContext(ctx, c.conn(ctx), `UPDATE companies SET name=:name, description=:description,
+	amount_of_employees=:amount_of_employees, registered=:registered, type=:type, updated_at=:updated_at
+	WHERE id=:id`, after)
+	if isUniqueViolation(err) {
+		return domain.ErrCompanyExists
+	}
+	if err != nil {
+		return err
+	}
+	return c.record(ctx, domain.CompanyUpdated, &before, &after)
+}
+
+// CreateCompany created new company in database and records it in company history
+func (c *SQLiteClient) CreateCompany(ctx context.Context, company domain.Company) (uuid.UUID, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	cmp := fromDomain(company)
+	cmp.ID = uuid.New()
+	cmp.OrganizationID = tenantID
+	err = c.WithinTx(ctx, func(ctx context.Context) error {
+		return c.insertCompany(ctx, cmp)
+	})
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("can not create company: %w", err)
+	}
+	return cmp.ID, nil
+}
+
+// GetCompany gets company from DB by id
+func (c *SQLiteClient) GetCompany(ctx context.Context, id uuid.UUID) (domain.Company, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return domain.Company{}, err
+	}
+	cmp, err := c.getCompany(ctx, tenantID, id, false)
+	if err != nil {
+		return domain.Company{}, fmt.Errorf("can not get company: %w", err)
+	}
+	return toDomain(cmp), nil
+}
+
+// SelectCompanies selects companies matching filter from database ordered by creation, soft deleted companies
+// are selected only if filter includes them
+func (c *SQLiteClient) SelectCompanies(ctx context.Context, filter domain.CompanyFilter) ([]domain.Company, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var cmps []company
+	err = sqlx.SelectContext(ctx, c.conn(ctx), &cmps, `SELECT * FROM companies WHERE organization_id=? AND (? OR deleted_at IS NULL)
+	ORDER BY created_at, id LIMIT ? OFFSET ?`, tenantID, filter.IncludeDeleted, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("can not select companies: %w", err)
+	}
+	companies := make([]domain.Company, 0)
+	for _, cmp := range cmps {
+		companies = append(companies, toDomain(cmp))
+	}
+	return companies, nil
+}
+
+// DeleteCompany soft deletes company by id and records it in company history
+func (c *SQLiteClient) DeleteCompany(ctx context.Context, id uuid.UUID) error {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+	err = c.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := c.getCompany(ctx, tenantID, id, false)
+		if err != nil {
+			return err
+		}
+		_, err = c.conn(ctx).ExecContext(ctx, "UPDATE companies SET deleted_at=? WHERE id=?", nanos(time.Now()), id)
+		if err != nil {
+			return err
+		}
+		return c.record(ctx, domain.CompanyDeleted, &before, nil)
+	})
+	if errors.Is(err, domain.ErrCompanyNotFound) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("can not delete company: %w", err)
+	}
+	return nil
+}
+
+// RestoreCompany restores soft deleted company by id and records it in company history
+func (c *SQLiteClient) RestoreCompany(ctx context.Context, id uuid.UUID) error {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+	err = c.WithinTx(ctx, func(ctx context.Context) error {
+		after, err := c.getCompany(ctx, tenantID, id, true)
+		if err != nil {
+			return err
+		}
+		after.DeletedAt = sql.NullInt64{}
+		after.UpdatedAt = nanos(time.Now())
+		_, err = c.conn(ctx).ExecContext(ctx, "UPDATE companies SET deleted_at=NULL, updated_at=? WHERE id=?", after.UpdatedAt, id)
+		if isUniqueViolation(err) {
+			return domain.ErrCompanyExists
+		}
+		if err != nil {
+			return err
+		}
+		return c.record(ctx, domain.CompanyRestored, nil, &after)
+	})
+	if errors.Is(err, domain.ErrCompanyNotFound) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("can not restore company: %w", err)
+	}
+	return nil
+}
+
+// PurgeCompanies permanently deletes companies of all organizations soft deleted before time
+func (c *SQLiteClient) PurgeCompanies(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	res, err := c.conn(ctx).ExecContext(ctx, "DELETE FROM companies WHERE deleted_at < ?", nanos(deletedBefore))
+	if err != nil {
+		return 0, fmt.Errorf("can not purge companies: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// CountCompaniesByType counts companies of all organizations which are not deleted by type
+func (c *SQLiteClient) CountCompaniesByType(ctx context.Context) (map[domain.CompanyType]int64, error) {
+	var rows []struct {
+		Type  string `db:"type"`
+		Count int64  `db:"count"`
+	}
+	err := sqlx.SelectContext(ctx, c.conn(ctx), &rows, "SELECT type, COUNT(*) AS count FROM companies WHERE deleted_at IS NULL GROUP BY type")
+	if err != nil {
+		return nil, fmt.Errorf("can not count companies: %w", err)
+	}
+	counts := make(map[domain.CompanyType]int64, len(rows))
+	for _, r := range rows {
+		counts[domain.CompanyType(r.Type)] = r.Count
+	}
+	return counts, nil
+}
+
+// UpdateCompany updates company by id and records it in company history
+func (c *SQLiteClient) UpdateCompany(ctx context.Context, id uuid.UUID, company domain.Company) error {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+	err = c.WithinTx(ctx, func(ctx context.Context) error {
+		before, err := c.getCompany(ctx, tenantID, id, false)
+		if err != nil {
+			return err
+		}
+		return c.updateCompany(ctx, before, fromDomain(company))
+	})
+	if errors.Is(err, domain.ErrCompanyNotFound) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("can not update company: %w", err)
+	}
+	return nil
+}
+
+// GetCompanyAsOf gets state of company at moment of time from company history
+func (c *SQLiteClient) GetCompanyAsOf(ctx context.Context, id uuid.UUID, asOf time.Time) (domain.Company, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return domain.Company{}, err
+	}
+	var after sql.NullString
+	err = sqlx.GetContext(ctx, c.conn(ctx), &after, `SELECT after FROM company_history
+	WHERE company_id=? AND organization_id=? AND created_at <= ? ORDER BY id DESC LIMIT 1`, id, tenantID, nanos(asOf))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !after.Valid) {
+		return domain.Company{}, domain.ErrCompanyNotFound
+	}
+	if err != nil {
+		return domain.Company{}, fmt.Errorf("can not get company as of %v: %w", asOf, err)
+	}
+	cmp, err := snapshotToDomain(after)
+	if err != nil {
+		return domain.Company{}, fmt.Errorf("can not get company as of %v: %w", asOf, err)
+	}
+	return *cmp, nil
+}
+
+// SelectCompanyHistory selects history of company from oldest to newest change
+func (c *SQLiteClient) SelectCompanyHistory(ctx context.Context, id uuid.UUID) ([]domain.CompanyChange, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var entries []companyHistory
+	err = sqlx.SelectContext(ctx, c.conn(ctx), &entries, `SELECT * FROM company_history
+	WHERE company_id=? AND organization_id=? ORDER BY id`, id, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("can not select company history: %w", err)
+	}
+	history := make([]domain.CompanyChange, 0, len(entries))
+	for _, e := range entries {
+		change, err := e.toDomain()
+		if err != nil {
+			return nil, fmt.Errorf("can not parse company history: %w", err)
+		}
+		history = append(history, change)
+	}
+	return history, nil
+}
+
+// ImportCompanies creates or updates companies read from source in one transaction.
+// Not deleted companies with the same name are updated, others are created.
+// If name repeats in source, the last company wins. Every change is recorded in company history.
+func (c *SQLiteClient) ImportCompanies(ctx context.Context, src domain.CompanySource) (domain.ImportResult, error) {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return domain.ImportResult{}, err
+	}
+	var result domain.ImportResult
+	err = c.WithinTx(ctx, func(ctx context.Context) error {
+		// imported tells whether company with name existed before import
+		imported := make(map[string]bool)
+		for src.Next() {
+			cmp := fromDomain(src.Company())
+			cmp.OrganizationID = tenantID
+			var before company
+			err := sqlx.GetContext(ctx, c.conn(ctx), &before, `SELECT * FROM companies
+			WHERE organization_id=? AND name=? AND deleted_at IS NULL`, tenantID, cmp.Name)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				cmp.ID = uuid.New()
+				err = c.insertCompany(ctx, cmp)
+			case err == nil:
+				err = c.updateCompany(ctx, before, cmp)
+			}
+			if err != nil {
+				return err
+			}
+			if _, ok := imported[cmp.Name]; !ok {
+				imported[cmp.Name] = before.ID != uuid.Nil
+			}
+		}
+		if err := src.Err(); err != nil {
+			return err
+		}
+		for _, existed := range imported {
+			if existed {
+				result.Updated++
+			} else {
+				result.Created++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return domain.ImportResult{}, fmt.Errorf("can not import companies: %w", err)
+	}
+	return result, nil
+}
+
+// ExportCompanies streams companies matching filter to fn row by row. Limit 0 exports all companies.
+// Export stops when fn returns error or context is cancelled.
+func (c *SQLiteClient) ExportCompanies(ctx context.Context, filter domain.CompanyFilter, fn func(domain.Company) error) error {
+	tenantID, err := tenant(ctx)
+	if err != nil {
+		return err
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		// negative limit means no limit in SQLite
+		limit = -1
+	}
+	rows, err := c.conn(ctx).QueryxContext(ctx, `SELECT * FROM companies WHERE organization_id=? AND (? OR deleted_at IS NULL)
+	ORDER BY created_at, id LIMIT ? OFFSET ?`, tenantID, filter.IncludeDeleted, limit, filter.Offset)
+	if err != nil {
+		return fmt.Errorf("can not export companies: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var cmp company
+		if err = rows.StructScan(&cmp); err != nil {
+			return fmt.Errorf("can not export companies: %w", err)
+		}
+		if err = fn(toDomain(cmp)); err != nil {
+			return fmt.Errorf("can not export companies: %w", err)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("can not export companies: %w", err)
+	}
+	return nil
+}
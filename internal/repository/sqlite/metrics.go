@@ -0,0 +1,11 @@
+package sqlite
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Collectors returns collector of connection pool stats of database
+func (c *SQLiteClient) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{collectors.NewDBStatsCollector(c.db.DB, "sqlite")}
+}
@@ -0,0 +1,104 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type organization struct {
+	ID        int64  `db:"id"`
+	Name      string `db:"name"`
+	CreatedAt int64  `db:"created_at"`
+}
+
+func (o organization) toDomain() domain.Organization {
+	return domain.Organization{
+		ID:   o.ID,
+		Name: o.Name,
+	}
+}
+
+// CreateOrganization creates new organization
+func (c *SQLiteClient) CreateOrganization(ctx context.Context, name string) (domain.Organization, error) {
+	var org organization
+	err := sqlx.GetContext(ctx, c.conn(ctx), &org, "INSERT INTO organizations (name, created_at) VALUES (?, ?) RETURNING *",
+		name, nanos(time.Now()))
+	if isUniqueViolation(err) {
+		err = domain.ErrOrganizationExists
+	}
+	if err != nil {
+		return domain.Organization{}, fmt.Errorf("can not create organization: %w", err)
+	}
+	return org.toDomain(), nil
+}
+
+// SelectOrganizations selects all organizations
+func (c *SQLiteClient) SelectOrganizations(ctx context.Context) ([]domain.Organization, error) {
+	var orgs []organization
+	err := sqlx.SelectContext(ctx, c.conn(ctx), &orgs, "SELECT * FROM organizations ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("can not select organizations: %w", err)
+	}
+	return organizationsToDomain(orgs), nil
+}
+
+// SelectUserOrganizations selects organizations user is member of
+func (c *SQLiteClient) SelectUserOrganizations(ctx context.Context, userID int64) ([]domain.Organization, error) {
+	var orgs []organization
+	err := sqlx.SelectContext(ctx, c.conn(ctx), &orgs, `SELECT o.* FROM organizations o
+	JOIN organization_members m ON m.organization_id = o.id WHERE m.user_id=? ORDER BY o.id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("can not select user organizations: %w", err)
+	}
+	return organizationsToDomain(orgs), nil
+}
+
+// IsOrganizationMember checks if user is member of organization
+func (c *SQLiteClient) IsOrganizationMember(ctx context.Context, organizationID, userID int64) (bool, error) {
+	var exists bool
+	err := sqlx.GetContext(ctx, c.conn(ctx), &exists, `SELECT EXISTS (SELECT 1 FROM organization_members
+	WHERE organization_id=? AND user_id=?)`, organizationID, userID)
+	if err != nil {
+		return false, fmt.Errorf("can not check organization member: %w", err)
+	}
+	return exists, nil
+}
+
+// AddOrganizationMember adds user to organization
+func (c *SQLiteClient) AddOrganizationMember(ctx context.Context, organizationID, userID int64) error {
+	_, err := c.conn(ctx).ExecContext(ctx, `INSERT OR IGNORE INTO organization_members (organization_id, user_id, created_at)
+	VALUES (?, ?, ?)`, organizationID, userID, nanos(time.Now()))
+	if err != nil {
+		return fmt.Errorf("can not add organization member: %w", err)
+	}
+	return nil
+}
+
+// RemoveOrganizationMember removes user from organization
+func (c *SQLiteClient) RemoveOrganizationMember(ctx context.Context, organizationID, userID int64) error {
+	res, err := c.conn(ctx).ExecContext(ctx, "DELETE FROM organization_members WHERE organization_id=? AND user_id=?",
+		organizationID, userID)
+	if err != nil {
+		return fmt.Errorf("can not remove organization member: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("can not remove organization member: %w", err)
+	}
+	if n == 0 {
+		return domain.ErrNotOrganizationMember
+	}
+	return nil
+}
+
+func organizationsToDomain(orgs []organization) []domain.Organization {
+	result := make([]domain.Organization, 0, len(orgs))
+	for _, o := range orgs {
+		result = append(result, o.toDomain())
+	}
+	return result
+}
@@ -0,0 +1,77 @@
+CREATE TABLE IF NOT EXISTS organizations (
+    id INTEGER PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+-- default organization new users join
+INSERT OR IGNORE INTO organizations (id, name, created_at) VALUES (1, 'default', 0);
+
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY,
+    username TEXT UNIQUE NOT NULL,
+    email TEXT UNIQUE NOT NULL,
+    password TEXT NOT NULL,
+    role TEXT NOT NULL DEFAULT 'user',
+    oidc_subject TEXT UNIQUE,
+    pending_email TEXT,
+    email_verification_token TEXT,
+    email_verification_expires_at INTEGER,
+    disabled INTEGER NOT NULL DEFAULT 0,
+    password_reset_token TEXT UNIQUE,
+    password_reset_expires_at INTEGER,
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at INTEGER NOT NULL,
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE TABLE IF NOT EXISTS companies (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL CHECK (length(name) <= 15),
+    description TEXT NOT NULL DEFAULT '',
+    amount_of_employees INTEGER NOT NULL,
+    registered INTEGER NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('Corporations', 'NonProfit', 'Cooperative', 'Sole Proprietorship')),
+    organization_id INTEGER NOT NULL REFERENCES organizations(id),
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL,
+    deleted_at INTEGER
+);
+
+-- names of deleted companies can be reused
+CREATE UNIQUE INDEX IF NOT EXISTS companies_organization_id_name_key ON companies (organization_id, name) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS companies_created_at_idx ON companies (organization_id, created_at, id);
+
+CREATE INDEX IF NOT EXISTS companies_deleted_at_idx ON companies (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS company_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    company_id TEXT NOT NULL,
+    organization_id INTEGER NOT NULL,
+    action TEXT NOT NULL,
+    before TEXT,
+    after TEXT,
+    actor_id INTEGER,
+    request_id TEXT,
+    created_at INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS company_history_company_id_idx ON company_history (company_id, id);
+
+-- history is append-only
+CREATE TRIGGER IF NOT EXISTS company_history_no_update BEFORE UPDATE ON company_history
+BEGIN
+    SELECT RAISE(ABORT, 'company_history is append-only');
+END;
+
+CREATE TRIGGER IF NOT EXISTS company_history_no_delete BEFORE DELETE ON company_history
+BEGIN
+    SELECT RAISE(ABORT, 'company_history is append-only');
+END;
@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/jmoiron/sqlx"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// driverName is name of pure Go SQLite driver
+const driverName = "sqlite"
+
+// schema creates tables if they do not exist. Times are stored as unix nanoseconds.
+//
+//go:embed schema.sql
+var schema string
+
+func init() {
+	sqlx.BindDriver(driverName, sqlx.QUESTION)
+}
+
+// SQLiteClient is client for SQLite database
+type SQLiteClient struct {
+	db *sqlx.DB
+}
+
+// NewSQLiteClient opens SQLite database from file at path, ":memory:" opens database in memory.
+// Schema is created if database is empty.
+func NewSQLiteClient(path string) (*SQLiteClient, error) {
+	db, err := sqlx.Connect(driverName, fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", path))
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer at a time, so queries are serialized on single connection.
+	// It also keeps database in memory alive while client is open.
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxIdleTime(0)
+	db.SetConnMaxLifetime(0)
+	if _, err = db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("can not create schema: %w", err)
+	}
+	return &SQLiteClient{db: db}, nil
+}
+
+// Ping pings database
+func (c *SQLiteClient) Ping(ctx context.Context) error {
+	return c.db.PingContext(ctx)
+}
+
+// Close closes database
+func (c *SQLiteClient) Close() error {
+	return c.db.Close()
+}
+
+// tenant returns organization every company query must be scoped to
+func tenant(ctx context.Context) (int64, error) {
+	id, ok := domain.TenantFromContext(ctx)
+	if !ok {
+		return 0, domain.ErrNoTenant
+	}
+	return id, nil
+}
+
+// isUniqueViolation checks if query failed because of unique constraint
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+func nanos(t time.Time) int64 {
+	return t.UnixNano()
+}
+
+func nullNanos(t time.Time) sql.NullInt64 {
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: !t.IsZero()}
+}
+
+func fromNanos(n int64) time.Time {
+	return time.Unix(0, n)
+}
+
+func fromNullNanos(n sql.NullInt64) time.Time {
+	if !n.Valid {
+		return time.Time{}
+	}
+	return time.Unix(0, n.Int64)
+}
@@ -0,0 +1,21 @@
+package sqlite_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Ragnar-BY/companies-handler/internal/repository/repotest"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/sqlite"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteClient(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Repository {
+		client, err := sqlite.NewSQLiteClient(filepath.Join(t.TempDir(), "companies.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, client.Close())
+		})
+		return client
+	})
+}
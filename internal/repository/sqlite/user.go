@@ -0,0 +1,217 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type user struct {
+	ID          int64          `db:"id"`
+	Username    string         `db:"username"`
+	Email       string         `db:"email"`
+	Password    string         `db:"password"`
+	Role        string         `db:"role"`
+	OIDCSubject sql.NullString `db:"oidc_subject"`
+	CreatedAt   int64          `db:"created_at"`
+	UpdatedAt   int64          `db:"updated_at"`
+
+	PendingEmail               sql.NullString `db:"pending_email"`
+	EmailVerificationToken     sql.NullString `db:"email_verification_token"`
+	EmailVerificationExpiresAt sql.NullInt64  `db:"email_verification_expires_at"`
+
+	Disabled               bool           `db:"disabled"`
+	PasswordResetToken     sql.NullString `db:"password_reset_token"`
+	PasswordResetExpiresAt sql.NullInt64  `db:"password_reset_expires_at"`
+}
+
+func userFromDomain(u domain.User) user {
+	role := u.Role
+	if role == "" {
+		role = domain.RoleUser
+	}
+	now := nanos(time.Now())
+	return user{
+		ID:          u.ID,
+		Username:    u.Username,
+		Email:       u.Email,
+		Password:    u.Password,
+		Role:        string(role),
+		OIDCSubject: sql.NullString{String: u.OIDCSubject, Valid: u.OIDCSubject != ""},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+
+		PendingEmail:               sql.NullString{String: u.PendingEmail, Valid: u.PendingEmail != ""},
+		EmailVerificationToken:     sql.NullString{String: u.EmailVerificationToken, Valid: u.EmailVerificationToken != ""},
+		EmailVerificationExpiresAt: nullNanos(u.EmailVerificationExpiresAt),
+
+		Disabled:               u.Disabled,
+		PasswordResetToken:     sql.NullString{String: u.PasswordResetToken, Valid: u.PasswordResetToken != ""},
+		PasswordResetExpiresAt: nullNanos(u.PasswordResetExpiresAt),
+	}
+}
+
+func (u user) userToDomain() domain.User {
+	return domain.User{
+		ID:          u.ID,
+		Username:    u.Username,
+		Email:       u.Email,
+		Password:    u.Password,
+		Role:        domain.Role(u.Role),
+		OIDCSubject: u.OIDCSubject.String,
+
+		PendingEmail:               u.PendingEmail.String,
+		EmailVerificationToken:     u.EmailVerificationToken.String,
+		EmailVerificationExpiresAt: fromNullNanos(u.EmailVerificationExpiresAt),
+
+		Disabled:               u.Disabled,
+		PasswordResetToken:     u.PasswordResetToken.String,
+		PasswordResetExpiresAt: fromNullNanos(u.PasswordResetExpiresAt),
+	}
+}
+
+// namedGet is sqlx.GetContext for query with named parameters
+func namedGet(ctx context.Context, q sqlx.ExtContext, dest any, query string, arg any) error {
+	query, args, err := sqlx.Named(query, arg)
+	if err != nil {
+		return err
+	}
+	return sqlx.GetContext(ctx, q, dest, q.Rebind(query), args...)
+}
+
+// getUser gets user by condition on column
+func (c *SQLiteClient) getUser(ctx context.Context, column string, value any) (*domain.User, error) {
+	var u user
+	err := sqlx.GetContext(ctx, c.conn(ctx), &u, "SELECT * FROM users WHERE "+column+"=?", value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	domainUser := u.userToDomain()
+	return &domainUser, nil
+}
+
+// CreateUser creates new user and adds it to default organization.
+// Users from identity provider are provisioned just in time: if user with the same
+// oidc subject already exists, its email is refreshed from provider. Role is kept, it is mapped
+// from provider groups only for new user and is managed by admins afterwards.
+func (c *SQLiteClient) CreateUser(ctx context.Context, u domain.User) (*domain.User, error) {
+	createUser := userFromDomain(u)
+	var newUser user
+	err := c.WithinTx(ctx, func(ctx context.Context) error {
+		err := namedGet(ctx, c.conn(ctx), &newUser, `INSERT INTO users
+		(username, email, password, role, oidc_subject, created_at, updated_at)
+		VALUES (:username, :email, :password, :role, :oidc_subject, :created_at, :updated_at)
+		ON CONFLICT (oidc_subject) DO UPDATE SET email=excluded.email, updated_at=excluded.updated_at
+		RETURNING *`, createUser)
+		if err != nil {
+			return err
+		}
+		_, err = c.conn(ctx).ExecContext(ctx, `INSERT OR IGNORE INTO organization_members
+		(organization_id, user_id, created_at) VALUES (?, ?, ?)`, domain.DefaultOrganizationID, newUser.ID, createUser.CreatedAt)
+		return err
+	})
+	if isUniqueViolation(err) {
+		err = domain.ErrUserExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can not create user: %w", err)
+	}
+	domainUser := newUser.userToDomain()
+	return &domainUser, nil
+}
+
+// GetUserByEmail gets user by email
+func (c *SQLiteClient) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
+	u, err := c.getUser(ctx, "email", email)
+	if err != nil {
+		return nil, fmt.Errorf("can not get user by email: %w", err)
+	}
+	return u, nil
+}
+
+// GetUserByID gets user by id
+func (c *SQLiteClient) GetUserByID(ctx context.Context, id int64) (*domain.User, error) {
+	u, err := c.getUser(ctx, "id", id)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can not get user by id: %w", err)
+	}
+	return u, nil
+}
+
+// GetUserByPasswordResetToken gets user by hash of password reset token
+func (c *SQLiteClient) GetUserByPasswordResetToken(ctx context.Context, token string) (*domain.User, error) {
+	u, err := c.getUser(ctx, "password_reset_token", token)
+	if errors.Is(err, domain.ErrUserNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can not get user by password reset token: %w", err)
+	}
+	return u, nil
+}
+
+// SelectUsers selects users matching filter ordered by id
+func (c *SQLiteClient) SelectUsers(ctx context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	var users []user
+	// LIKE is case-insensitive for ASCII in SQLite
+	err := sqlx.SelectContext(ctx, c.conn(ctx), &users, `SELECT * FROM users
+	WHERE ?1 = '' OR username LIKE '%' || ?1 || '%' OR email LIKE '%' || ?1 || '%'
+	ORDER BY id LIMIT ?2 OFFSET ?3`, filter.Query, filter.Limit, filter.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("can not select users: %w", err)
+	}
+	result := make([]domain.User, 0, len(users))
+	for _, u := range users {
+		result = append(result, u.userToDomain())
+	}
+	return result, nil
+}
+
+// UpdateUser updates user by id
+func (c *SQLiteClient) UpdateUser(ctx context.Context, u domain.User) (*domain.User, error) {
+	var updated user
+	err := namedGet(ctx, c.conn(ctx), &updated, `UPDATE users SET username=:username, email=:email, password=:password,
+	role=:role, pending_email=:pending_email, email_verification_token=:email_verification_token,
+	email_verification_expires_at=:email_verification_expires_at, disabled=:disabled,
+	password_reset_token=:password_reset_token, password_reset_expires_at=:password_reset_expires_at,
+	updated_at=:updated_at
+	WHERE id=:id RETURNING *`, userFromDomain(u))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.ErrUserNotFound
+	}
+	if isUniqueViolation(err) {
+		err = domain.ErrUserExists
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can not update user: %w", err)
+	}
+	domainUser := updated.userToDomain()
+	return &domainUser, nil
+}
+
+// DeleteUser deletes user by id
+func (c *SQLiteClient) DeleteUser(ctx context.Context, id int64) error {
+	res, err := c.conn(ctx).ExecContext(ctx, "DELETE FROM users WHERE id=?", id)
+	if err != nil {
+		return fmt.Errorf("can not delete user: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("can not delete user: %w", err)
+	}
+	if n == 0 {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
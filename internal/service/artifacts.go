@@ -0,0 +1,57 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// ArtifactStore stores input and result files of jobs on local disk
+type ArtifactStore struct {
+	dir string
+}
+
+// NewArtifactStore creates store in directory, directory is created if it does not exist
+func NewArtifactStore(dir string) (*ArtifactStore, error) {
+	err := os.MkdirAll(dir, 0o750)
+	if err != nil {
+		return nil, fmt.Errorf("can not create artifact directory: %w", err)
+	}
+	return &ArtifactStore{dir: dir}, nil
+}
+
+// Path returns path of artifact with name
+func (s *ArtifactStore) Path(name string) string {
+	return filepath.Join(s.dir, name)
+}
+
+// Save writes content of r to artifact with name and returns its path
+func (s *ArtifactStore) Save(name string, r io.Reader) (string, error) {
+	path := s.Path(name)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("can not save artifact: %w", err)
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return "", fmt.Errorf("can not save artifact: %w", err)
+	}
+	return path, nil
+}
+
+// Remove removes artifact by path, missing artifact is not an error
+func (s *ArtifactStore) Remove(path string) error {
+	if path == "" {
+		return nil
+	}
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can not remove artifact: %w", err)
+	}
+	return nil
+}
@@ -10,8 +10,11 @@ import (
 const expireAt = time.Hour
 
 type JWTClaim struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	UserID   int64       `json:"user_id"`
+	Username string      `json:"username"`
+	Email    string      `json:"email"`
+	Role     domain.Role `json:"role"`
+	TenantID int64       `json:"tenant_id"`
 	jwt.StandardClaims
 }
 
@@ -27,12 +30,15 @@ func NewAuthService(jwtKey []byte) *AuthService {
 	}
 }
 
-// GenerateJWT generate JWT for email and username
-func (a *AuthService) GenerateJWT(email, username string) (string, error) {
+// GenerateJWT generate JWT for user
+func (a *AuthService) GenerateJWT(user domain.User) (string, error) {
 	expirationTime := time.Now().Add(a.expireAt)
 	claims := &JWTClaim{
-		Email:    email,
-		Username: username,
+		UserID:   user.ID,
+		Email:    user.Email,
+		Username: user.Username,
+		Role:     user.Role,
+		TenantID: user.OrganizationID,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: expirationTime.Unix(),
 		},
@@ -41,8 +47,8 @@ func (a *AuthService) GenerateJWT(email, username string) (string, error) {
 	return token.SignedString(a.jwtKey)
 }
 
-// ValidateToken validates token
-func (a *AuthService) ValidateToken(signedToken string) error {
+// ValidateToken validates token and returns user from its claims
+func (a *AuthService) ValidateToken(signedToken string) (domain.User, error) {
 	token, err := jwt.ParseWithClaims(
 		signedToken,
 		&JWTClaim{},
@@ -51,14 +57,21 @@ func (a *AuthService) ValidateToken(signedToken string) error {
 		},
 	)
 	if err != nil {
-		return err
+		return domain.User{}, err
 	}
 	claims, ok := token.Claims.(*JWTClaim)
 	if !ok {
-		return domain.ErrTokenBadClaims
+		return domain.User{}, domain.ErrTokenBadClaims
 	}
 	if claims.ExpiresAt < time.Now().Local().Unix() {
-		return domain.ErrTokenExpired
+		return domain.User{}, domain.ErrTokenExpired
 	}
-	return nil
+	return domain.User{
+		ID:       claims.UserID,
+		Username: claims.Username,
+		Email:    claims.Email,
+		Role:     claims.Role,
+
+		OrganizationID: claims.TenantID,
+	}, nil
 }
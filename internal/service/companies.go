@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/Ragnar-BY/companies-handler/internal/domain"
 	"github.com/google/uuid"
@@ -11,9 +12,15 @@ import (
 type CompanyRepository interface {
 	CreateCompany(ctx context.Context, company domain.Company) (uuid.UUID, error)
 	GetCompany(ctx context.Context, id uuid.UUID) (domain.Company, error)
-	SelectCompanies(ctx context.Context, limit, offset int) ([]domain.Company, error)
+	SelectCompanies(ctx context.Context, filter domain.CompanyFilter) ([]domain.Company, error)
 	DeleteCompany(ctx context.Context, id uuid.UUID) error
+	RestoreCompany(ctx context.Context, id uuid.UUID) error
 	UpdateCompany(ctx context.Context, uuid uuid.UUID, company domain.Company) error
+	GetCompanyAsOf(ctx context.Context, id uuid.UUID, asOf time.Time) (domain.Company, error)
+	SelectCompanyHistory(ctx context.Context, id uuid.UUID) ([]domain.CompanyChange, error)
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	ImportCompanies(ctx context.Context, src domain.CompanySource) (domain.ImportResult, error)
+	ExportCompanies(ctx context.Context, filter domain.CompanyFilter, fn func(domain.Company) error) error
 }
 
 // CompanyService is service to work with companies
@@ -37,8 +44,8 @@ func (s *CompanyService) Get(ctx context.Context, id uuid.UUID) (domain.Company,
 }
 
 // Select selects list of companies
-func (s *CompanyService) Select(ctx context.Context, limit, offset int) ([]domain.Company, error) {
-	return s.repo.SelectCompanies(ctx, limit, offset)
+func (s *CompanyService) Select(ctx context.Context, filter domain.CompanyFilter) ([]domain.Company, error) {
+	return s.repo.SelectCompanies(ctx, filter)
 }
 
 // Delete deletes company by id
@@ -46,7 +53,37 @@ func (s *CompanyService) Delete(ctx context.Context, id uuid.UUID) error {
 	return s.repo.DeleteCompany(ctx, id)
 }
 
+// Restore restores deleted company by id
+func (s *CompanyService) Restore(ctx context.Context, id uuid.UUID) error {
+	return s.repo.RestoreCompany(ctx, id)
+}
+
 // Update updates company by id
 func (s *CompanyService) Update(ctx context.Context, id uuid.UUID, company domain.Company) error {
 	return s.repo.UpdateCompany(ctx, id, company)
 }
+
+// GetAsOf gets state of company at moment of time
+func (s *CompanyService) GetAsOf(ctx context.Context, id uuid.UUID, asOf time.Time) (domain.Company, error) {
+	return s.repo.GetCompanyAsOf(ctx, id, asOf)
+}
+
+// History selects history of company changes
+func (s *CompanyService) History(ctx context.Context, id uuid.UUID) ([]domain.CompanyChange, error) {
+	return s.repo.SelectCompanyHistory(ctx, id)
+}
+
+// WithinTx runs fn in transaction
+func (s *CompanyService) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.repo.WithinTx(ctx, fn)
+}
+
+// Import upserts companies from source by name
+func (s *CompanyService) Import(ctx context.Context, src domain.CompanySource) (domain.ImportResult, error) {
+	return s.repo.ImportCompanies(ctx, src)
+}
+
+// Export streams companies matching filter to fn
+func (s *CompanyService) Export(ctx context.Context, filter domain.CompanyFilter, fn func(domain.Company) error) error {
+	return s.repo.ExportCompanies(ctx, filter, fn)
+}
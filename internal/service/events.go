@@ -1,24 +1,94 @@
 package service
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/Ragnar-BY/companies-handler/internal/service")
 
 type EventSender interface {
-	SendEvent(topic string, message []byte) error
+	// SendEvent sends message to topic, headers carry trace context of event
+	SendEvent(ctx context.Context, topic string, message []byte, headers map[string]string) error
+	Ping(ctx context.Context) error
 }
 type EventService struct {
 	sender EventSender
+
+	published *prometheus.CounterVec
+	failed    *prometheus.CounterVec
+}
+
+// EventOption configures optional features of event service
+type EventOption func(s *EventService)
+
+// WithEventMetrics counts published and failed events per topic
+func WithEventMetrics(reg prometheus.Registerer) EventOption {
+	return func(s *EventService) {
+		s.published = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "events_published_total",
+			Help: "Number of events published to broker by topic.",
+		}, []string{"topic"})
+		s.failed = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "events_failed_total",
+			Help: "Number of events which could not be published by topic.",
+		}, []string{"topic"})
+		reg.MustRegister(s.published, s.failed)
+	}
 }
 
-func NewEventSender(sender EventSender) *EventService {
-	return &EventService{
+func NewEventSender(sender EventSender, opts ...EventOption) *EventService {
+	s := &EventService{
 		sender: sender,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func (s *EventService) SendEvent(topic string, message any) error {
+// SendEvent publishes message to topic in span, W3C trace context of span is propagated in headers of event
+func (s *EventService) SendEvent(ctx context.Context, topic string, message any) (err error) {
+	ctx, span := tracer.Start(ctx, topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingOperationPublish,
+			semconv.MessagingDestinationName(topic),
+			semconv.MessagingDestinationKindTopic,
+		),
+	)
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
 	msg, err := json.Marshal(message)
-	if err != nil {
-		return err
+	if err == nil {
+		headers := propagation.MapCarrier{}
+		otel.GetTextMapPropagator().Inject(ctx, headers)
+		err = s.sender.SendEvent(ctx, topic, msg, headers)
 	}
-	return s.sender.SendEvent(topic, msg)
+	if s.published != nil {
+		if err != nil {
+			s.failed.WithLabelValues(topic).Inc()
+		} else {
+			s.published.WithLabelValues(topic).Inc()
+		}
+	}
+	return err
+}
+
+// Ping checks connection to broker
+func (s *EventService) Ping(ctx context.Context) error {
+	return s.sender.Ping(ctx)
 }
@@ -0,0 +1,68 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Ragnar-BY/companies-handler/internal/service"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordingSender records headers of sent events
+type recordingSender struct {
+	headers []map[string]string
+	err     error
+}
+
+func (s *recordingSender) SendEvent(_ context.Context, _ string, _ []byte, headers map[string]string) error {
+	s.headers = append(s.headers, headers)
+	return s.err
+}
+
+func (s *recordingSender) Ping(context.Context) error {
+	return nil
+}
+
+func TestEventServiceSendEvent(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	sender := &recordingSender{}
+	reg := prometheus.NewRegistry()
+	events := service.NewEventSender(sender, service.WithEventMetrics(reg))
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	require.NoError(t, events.SendEvent(ctx, "create-company", "id"))
+	sender.err = errors.New("broker is down")
+	require.ErrorIs(t, events.SendEvent(ctx, "create-company", "id"), sender.err)
+	parent.End()
+
+	// publish spans are children of span of context, their trace context is sent in headers
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	for i, span := range spans[:2] {
+		require.Equal(t, "create-company publish", span.Name())
+		require.Equal(t, parent.SpanContext().SpanID(), span.Parent().SpanID())
+		traceparent := sender.headers[i]["traceparent"]
+		require.Contains(t, traceparent, span.SpanContext().TraceID().String())
+		require.Contains(t, traceparent, span.SpanContext().SpanID().String())
+	}
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP events_failed_total Number of events which could not be published by topic.
+# TYPE events_failed_total counter
+events_failed_total{topic="create-company"} 1
+# HELP events_published_total Number of events published to broker by topic.
+# TYPE events_published_total counter
+events_published_total{topic="create-company"} 1
+`)))
+}
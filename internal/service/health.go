@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+)
+
+// defaultHealthCheckTimeout is used if timeout of check is not set
+const defaultHealthCheckTimeout = 2 * time.Second
+
+// HealthCheck is check of dependency instance needs to serve requests
+type HealthCheck struct {
+	Name string
+	// Check returns error if dependency is unhealthy, it is canceled after Timeout
+	Check   func(ctx context.Context) error
+	Timeout time.Duration
+}
+
+// HealthService checks readiness of instance. Checks run concurrently and their report is cached,
+// so that frequent probes do not load dependencies.
+type HealthService struct {
+	checks   []HealthCheck
+	cacheTTL time.Duration
+
+	shuttingDown atomic.Bool
+
+	mu     sync.Mutex
+	report domain.HealthReport
+}
+
+// NewHealthService creates health service, report of checks is reused for cacheTTL
+func NewHealthService(cacheTTL time.Duration, checks ...HealthCheck) *HealthService {
+	return &HealthService{checks: checks, cacheTTL: cacheTTL}
+}
+
+// Ready returns report of checks, they are run again if cached report is older than cache TTL.
+// Checks are limited by their timeouts only, so that canceled probe does not spoil cached report.
+func (s *HealthService) Ready() domain.HealthReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.report.Checks == nil || time.Since(s.report.CheckedAt) >= s.cacheTTL {
+		s.report = s.run()
+	}
+	report := s.report
+	report.ShuttingDown = s.shuttingDown.Load()
+	return report
+}
+
+// Shutdown marks instance as not ready, so that load balancers stop sending requests to it
+// while it finishes requests in progress
+func (s *HealthService) Shutdown() {
+	s.shuttingDown.Store(true)
+}
+
+func (s *HealthService) run() domain.HealthReport {
+	results := make([]domain.HealthCheckResult, len(s.checks))
+	var wg sync.WaitGroup
+	for i, check := range s.checks {
+		wg.Add(1)
+		go func(i int, check HealthCheck) {
+			defer wg.Done()
+			results[i] = runCheck(check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := domain.HealthReport{
+		Checks:    make(map[string]domain.HealthCheckResult, len(s.checks)),
+		CheckedAt: time.Now(),
+	}
+	for i, check := range s.checks {
+		report.Checks[check.Name] = results[i]
+	}
+	return report
+}
+
+// runCheck runs check with its timeout. Check which ignores context is not waited for after timeout.
+func runCheck(check HealthCheck) domain.HealthCheckResult {
+	if check.Timeout <= 0 {
+		check.Timeout = defaultHealthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), check.Timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check.Check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out after %v", check.Timeout)
+	}
+	return domain.HealthCheckResult{Err: err, Duration: time.Since(start)}
+}
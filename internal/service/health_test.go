@@ -0,0 +1,80 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthServiceReady(t *testing.T) {
+	errDown := errors.New("down")
+	var calls atomic.Int32
+	var failing atomic.Bool
+	srv := service.NewHealthService(time.Hour,
+		service.HealthCheck{
+			Name: "db",
+			Check: func(ctx context.Context) error {
+				calls.Add(1)
+				if failing.Load() {
+					return errDown
+				}
+				return nil
+			},
+		},
+		service.HealthCheck{
+			Name: "broker",
+			Check: func(ctx context.Context) error {
+				return nil
+			},
+		},
+	)
+
+	report := srv.Ready()
+	require.True(t, report.Healthy())
+	require.Len(t, report.Checks, 2)
+	require.NoError(t, report.Checks["db"].Err)
+
+	// report is cached
+	failing.Store(true)
+	report = srv.Ready()
+	require.True(t, report.Healthy())
+	require.Equal(t, int32(1), calls.Load())
+
+	// instance is not ready once shutdown starts, even with cached report
+	srv.Shutdown()
+	report = srv.Ready()
+	require.False(t, report.Healthy())
+	require.True(t, report.ShuttingDown)
+}
+
+func TestHealthServiceFailedCheck(t *testing.T) {
+	errDown := errors.New("down")
+	srv := service.NewHealthService(0,
+		service.HealthCheck{
+			Name: "db",
+			Check: func(ctx context.Context) error {
+				return errDown
+			},
+		},
+		service.HealthCheck{
+			Name:    "slow",
+			Timeout: 10 * time.Millisecond,
+			Check: func(ctx context.Context) error {
+				// check ignoring context is not waited for
+				time.Sleep(time.Second)
+				return nil
+			},
+		},
+	)
+	start := time.Now()
+	report := srv.Ready()
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+	require.False(t, report.Healthy())
+	require.ErrorIs(t, report.Checks["db"].Err, errDown)
+	require.ErrorContains(t, report.Checks["slow"].Err, "timed out")
+}
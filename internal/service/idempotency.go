@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"go.uber.org/zap"
+)
+
+// IdempotencyRepository describes repository of idempotency keys
+type IdempotencyRepository interface {
+	StartIdempotencyKey(ctx context.Context, key domain.IdempotencyKey, ttl, lease time.Duration) (domain.IdempotencyKey, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, key domain.IdempotencyKey) error
+	DeleteIdempotencyKey(ctx context.Context, key domain.IdempotencyKey) error
+	PurgeIdempotencyKeys(ctx context.Context) (int64, error)
+}
+
+// IdempotencyService is service to work with idempotency keys
+type IdempotencyService struct {
+	repo IdempotencyRepository
+	log  *zap.Logger
+}
+
+// NewIdempotencyService creates new idempotency service
+func NewIdempotencyService(repo IdempotencyRepository, log *zap.Logger) *IdempotencyService {
+	return &IdempotencyService{repo: repo, log: log}
+}
+
+// Start stores key of request in progress or returns already stored key. Key expires after ttl,
+// key of request in progress is taken again after lease.
+func (s *IdempotencyService) Start(ctx context.Context, key domain.IdempotencyKey, ttl, lease time.Duration) (domain.IdempotencyKey, bool, error) {
+	return s.repo.StartIdempotencyKey(ctx, key, ttl, lease)
+}
+
+// Complete stores response of request
+func (s *IdempotencyService) Complete(ctx context.Context, key domain.IdempotencyKey) error {
+	return s.repo.CompleteIdempotencyKey(ctx, key)
+}
+
+// Delete deletes key of request in progress
+func (s *IdempotencyService) Delete(ctx context.Context, key domain.IdempotencyKey) error {
+	return s.repo.DeleteIdempotencyKey(ctx, key)
+}
+
+// Run deletes expired keys every interval until context is canceled
+func (s *IdempotencyService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := s.repo.PurgeIdempotencyKeys(ctx)
+		if err != nil {
+			s.log.Error("can not purge idempotency keys", zap.Error(err))
+		} else if n > 0 {
+			s.log.Info("idempotency keys purged", zap.Int64("count", n))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
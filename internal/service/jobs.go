@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/google/uuid"
+)
+
+// JobRepository describes repository of jobs
+type JobRepository interface {
+	CreateJob(ctx context.Context, job domain.Job) (domain.Job, error)
+	GetJob(ctx context.Context, id uuid.UUID) (domain.Job, error)
+	CancelJob(ctx context.Context, id uuid.UUID) (domain.Job, error)
+	ClaimJob(ctx context.Context, staleAfter time.Duration) (domain.Job, error)
+	HeartbeatJob(ctx context.Context, job domain.Job, progress int) (bool, error)
+	FinishJob(ctx context.Context, job domain.Job) error
+	RetryJob(ctx context.Context, job domain.Job, delay time.Duration) error
+}
+
+// JobService is service to work with jobs
+type JobService struct {
+	repo JobRepository
+}
+
+// NewJobService creates new job service
+func NewJobService(repo JobRepository) *JobService {
+	return &JobService{repo: repo}
+}
+
+// Create creates new queued job
+func (s *JobService) Create(ctx context.Context, job domain.Job) (domain.Job, error) {
+	return s.repo.CreateJob(ctx, job)
+}
+
+// Get gets job by id
+func (s *JobService) Get(ctx context.Context, id uuid.UUID) (domain.Job, error) {
+	return s.repo.GetJob(ctx, id)
+}
+
+// Cancel requests cancellation of job
+func (s *JobService) Cancel(ctx context.Context, id uuid.UUID) (domain.Job, error) {
+	return s.repo.CancelJob(ctx, id)
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+const (
+	loginExpireAt      = 10 * time.Minute
+	maxPendingLogins   = 10000
+	defaultGroupsClaim = "groups"
+)
+
+// OIDCSettings are settings for OpenID Connect provider
+type OIDCSettings struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// GroupsClaim is name of id token claim with user groups
+	GroupsClaim string
+	// RoleMapping maps provider groups to local roles
+	RoleMapping map[string]domain.Role
+}
+
+// pendingLogin is login started by AuthCodeURL and not finished by Exchange yet
+type pendingLogin struct {
+	verifier  string
+	nonce     string
+	expiresAt time.Time
+}
+
+// OIDCService is service for authorization code + PKCE login with OpenID Connect provider
+type OIDCService struct {
+	oauth       oauth2.Config
+	verifier    *oidc.IDTokenVerifier
+	groupsClaim string
+	roleMapping map[string]domain.Role
+
+	mu      sync.Mutex
+	pending map[string]pendingLogin
+	// order holds states of pending logins in order of start, which is also order of expiration
+	order []string
+}
+
+// NewOIDCService discovers provider configuration and creates new OIDC service
+func NewOIDCService(ctx context.Context, settings OIDCSettings) (*OIDCService, error) {
+	for group, role := range settings.RoleMapping {
+		if !role.Valid() {
+			return nil, fmt.Errorf("unknown role %q of oidc group %q", role, group)
+		}
+	}
+	provider, err := oidc.NewProvider(ctx, settings.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("can not discover oidc provider: %w", err)
+	}
+	scopes := []string{oidc.ScopeOpenID, "email", "profile"}
+	scopes = append(scopes, settings.Scopes...)
+	groupsClaim := settings.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = defaultGroupsClaim
+	}
+	return &OIDCService{
+		oauth: oauth2.Config{
+			ClientID:     settings.ClientID,
+			ClientSecret: settings.ClientSecret,
+			RedirectURL:  settings.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+		verifier:    provider.Verifier(&oidc.Config{ClientID: settings.ClientID}),
+		groupsClaim: groupsClaim,
+		roleMapping: settings.RoleMapping,
+		pending:     make(map[string]pendingLogin),
+	}, nil
+}
+
+// AuthCodeURL starts new login and returns url of provider login page.
+// Pending logins are kept in memory, at most maxPendingLogins of them, callback must reach the same instance.
+// It returns domain.ErrOIDCTooManyLogins if limit is reached.
+func (s *OIDCService) AuthCodeURL() (string, error) {
+	state, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomString()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.order) > 0 {
+		p, ok := s.pending[s.order[0]]
+		if ok && !now.After(p.expiresAt) {
+			break
+		}
+		delete(s.pending, s.order[0])
+		s.order = s.order[1:]
+	}
+	if len(s.order) >= maxPendingLogins {
+		return "", domain.ErrOIDCTooManyLogins
+	}
+	s.pending[state] = pendingLogin{
+		verifier:  verifier,
+		nonce:     nonce,
+		expiresAt: now.Add(loginExpireAt),
+	}
+	s.order = append(s.order, state)
+
+	return s.oauth.AuthCodeURL(state,
+		oidc.Nonce(nonce),
+		oauth2.SetAuthURLParam("code_challenge", codeChallenge(verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	), nil
+}
+
+// Exchange finishes login: exchanges code for tokens, verifies id token
+// and returns user described by it
+func (s *OIDCService) Exchange(ctx context.Context, code, state string) (domain.User, error) {
+	s.mu.Lock()
+	login, ok := s.pending[state]
+	delete(s.pending, state)
+	s.mu.Unlock()
+	if !ok || time.Now().After(login.expiresAt) {
+		return domain.User{}, domain.ErrOIDCBadState
+	}
+
+	token, err := s.oauth.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", login.verifier))
+	if err != nil {
+		return domain.User{}, fmt.Errorf("can not exchange code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return domain.User{}, errors.New("token response does not contain id token")
+	}
+	idToken, err := s.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return domain.User{}, fmt.Errorf("can not verify id token: %w", err)
+	}
+	if idToken.Nonce != login.nonce {
+		return domain.User{}, errors.New("id token nonce mismatch")
+	}
+
+	claims := make(map[string]any)
+	if err = idToken.Claims(&claims); err != nil {
+		return domain.User{}, fmt.Errorf("can not parse id token claims: %w", err)
+	}
+	email, _ := claims["email"].(string)
+	if email == "" {
+		return domain.User{}, domain.ErrOIDCMissingEmail
+	}
+	// email identifies user, unverified email could be set to email of another user
+	if verified, _ := claims["email_verified"].(bool); !verified {
+		return domain.User{}, domain.ErrOIDCEmailNotVerified
+	}
+	username, _ := claims["preferred_username"].(string)
+	if username == "" {
+		username, _, _ = strings.Cut(email, "@")
+	}
+	if runes := []rune(username); len(runes) > domain.MaxUsernameLength {
+		username = string(runes[:domain.MaxUsernameLength])
+	}
+	return domain.User{
+		Username:    username,
+		Email:       email,
+		Role:        s.mapRole(groupsFromClaim(claims[s.groupsClaim])),
+		OIDCSubject: idToken.Subject,
+	}, nil
+}
+
+// mapRole maps provider groups to local role, admin role wins over others
+func (s *OIDCService) mapRole(groups []string) domain.Role {
+	role := domain.RoleUser
+	for _, g := range groups {
+		mapped, ok := s.roleMapping[g]
+		if !ok {
+			continue
+		}
+		if mapped == domain.RoleAdmin {
+			return mapped
+		}
+		role = mapped
+	}
+	return role
+}
+
+func groupsFromClaim(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		groups := make([]string, 0, len(v))
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+		return groups
+	}
+	return nil
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
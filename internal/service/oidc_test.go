@@ -0,0 +1,257 @@
+package service_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/service"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	mockClientID = "companies-handler"
+	mockKeyID    = "test-key"
+)
+
+// mockProvider is local OpenID Connect provider
+type mockProvider struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	mu         sync.Mutex
+	challenges map[string]string // code -> code challenge
+	nonces     map[string]string // code -> nonce
+	groups     []string
+	username   string
+	unverified bool
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p := &mockProvider{
+		key:        key,
+		challenges: make(map[string]string),
+		nonces:     make(map[string]string),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/token", p.token)
+	p.srv = httptest.NewServer(mux)
+	t.Cleanup(p.srv.Close)
+	return p
+}
+
+func (p *mockProvider) discovery(w http.ResponseWriter, _ *http.Request) {
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"issuer":                                p.srv.URL,
+		"authorization_endpoint":                p.srv.URL + "/authorize",
+		"token_endpoint":                        p.srv.URL + "/token",
+		"jwks_uri":                              p.srv.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (p *mockProvider) jwks(w http.ResponseWriter, _ *http.Request) {
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": mockKeyID,
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize emulates user login on provider page and returns code
+func (p *mockProvider) authorize(t *testing.T, authURL string) (code, state string) {
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	q := u.Query()
+	require.Equal(t, "S256", q.Get("code_challenge_method"))
+	require.Equal(t, mockClientID, q.Get("client_id"))
+
+	code = "code-" + q.Get("state")
+	p.mu.Lock()
+	p.challenges[code] = q.Get("code_challenge")
+	p.nonces[code] = q.Get("nonce")
+	p.mu.Unlock()
+	return code, q.Get("state")
+}
+
+func (p *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	challenge, nonce := p.challenges[code], p.nonces[code]
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if challenge == "" || base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                p.srv.URL,
+		"aud":                mockClientID,
+		"sub":                "subject-1",
+		"iat":                time.Now().Unix(),
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"nonce":              nonce,
+		"email":              "sso@example.com",
+		"email_verified":     !p.unverified,
+		"preferred_username": p.username,
+		"groups":             p.groups,
+	})
+	idToken.Header["kid"] = mockKeyID
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+func TestOIDCService_Exchange(t *testing.T) {
+	provider := newMockProvider(t)
+	ctx := context.Background()
+	srv, err := service.NewOIDCService(ctx, service.OIDCSettings{
+		Issuer:      provider.srv.URL,
+		ClientID:    mockClientID,
+		RedirectURL: "http://localhost:8080/oidc/callback",
+		RoleMapping: map[string]domain.Role{"ops": domain.RoleAdmin},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name             string
+		groups           []string
+		role             domain.Role
+		providerUsername string
+		unverified       bool
+		username         string
+		err              error
+	}{
+		{
+			name:             "mapped group",
+			groups:           []string{"staff", "ops"},
+			role:             domain.RoleAdmin,
+			providerUsername: "sso-user",
+			username:         "sso-user",
+		},
+		{
+			name:             "unmapped group",
+			groups:           []string{"staff"},
+			role:             domain.RoleUser,
+			providerUsername: "sso-user",
+			username:         "sso-user",
+		},
+		{
+			name:             "long username is truncated by characters",
+			groups:           []string{"staff"},
+			role:             domain.RoleUser,
+			providerUsername: "ЁжикВТуманеИДругие",
+			username:         "ЁжикВТуманеИДру",
+		},
+		{
+			name:             "unverified email",
+			groups:           []string{"staff"},
+			providerUsername: "sso-user",
+			unverified:       true,
+			err:              domain.ErrOIDCEmailNotVerified,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			provider.groups = tt.groups
+			provider.username = tt.providerUsername
+			provider.unverified = tt.unverified
+			authURL, err := srv.AuthCodeURL()
+			require.NoError(t, err)
+			code, state := provider.authorize(t, authURL)
+
+			user, err := srv.Exchange(ctx, code, state)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, domain.User{
+				Username:    tt.username,
+				Email:       "sso@example.com",
+				Role:        tt.role,
+				OIDCSubject: "subject-1",
+			}, user)
+
+			_, err = srv.Exchange(ctx, code, state)
+			require.ErrorIs(t, err, domain.ErrOIDCBadState)
+		})
+	}
+}
+
+func TestOIDCService_PendingLoginsLimit(t *testing.T) {
+	provider := newMockProvider(t)
+	ctx := context.Background()
+	srv, err := service.NewOIDCService(ctx, service.OIDCSettings{
+		Issuer:      provider.srv.URL,
+		ClientID:    mockClientID,
+		RedirectURL: "http://localhost:8080/oidc/callback",
+	})
+	require.NoError(t, err)
+
+	var authURL string
+	for i := 0; i < 100000; i++ {
+		next, err := srv.AuthCodeURL()
+		if err != nil {
+			require.ErrorIs(t, err, domain.ErrOIDCTooManyLogins)
+			break
+		}
+		authURL = next
+	}
+	_, err = srv.AuthCodeURL()
+	require.ErrorIs(t, err, domain.ErrOIDCTooManyLogins)
+
+	// logins started before limit is reached can be finished
+	code, state := provider.authorize(t, authURL)
+	_, err = srv.Exchange(ctx, code, state)
+	require.NoError(t, err)
+}
+
+func TestNewOIDCService_UnknownRole(t *testing.T) {
+	provider := newMockProvider(t)
+	_, err := service.NewOIDCService(context.Background(), service.OIDCSettings{
+		Issuer:      provider.srv.URL,
+		ClientID:    mockClientID,
+		RedirectURL: "http://localhost:8080/oidc/callback",
+		RoleMapping: map[string]domain.Role{"ops": "superuser"},
+	})
+	require.ErrorContains(t, err, `unknown role "superuser"`)
+}
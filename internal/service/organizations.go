@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+)
+
+// OrganizationRepository describes organization repository
+type OrganizationRepository interface {
+	CreateOrganization(ctx context.Context, name string) (domain.Organization, error)
+	SelectOrganizations(ctx context.Context) ([]domain.Organization, error)
+	SelectUserOrganizations(ctx context.Context, userID int64) ([]domain.Organization, error)
+	IsOrganizationMember(ctx context.Context, organizationID, userID int64) (bool, error)
+	AddOrganizationMember(ctx context.Context, organizationID, userID int64) error
+	RemoveOrganizationMember(ctx context.Context, organizationID, userID int64) error
+}
+
+// OrganizationService is service to work with organizations
+type OrganizationService struct {
+	repo OrganizationRepository
+}
+
+// NewOrganizationService creates new organization service
+func NewOrganizationService(repo OrganizationRepository) *OrganizationService {
+	return &OrganizationService{repo: repo}
+}
+
+// Create creates new organization
+func (s *OrganizationService) Create(ctx context.Context, name string) (domain.Organization, error) {
+	return s.repo.CreateOrganization(ctx, name)
+}
+
+// Select selects all organizations
+func (s *OrganizationService) Select(ctx context.Context) ([]domain.Organization, error) {
+	return s.repo.SelectOrganizations(ctx)
+}
+
+// SelectForUser selects organizations user is member of
+func (s *OrganizationService) SelectForUser(ctx context.Context, userID int64) ([]domain.Organization, error) {
+	return s.repo.SelectUserOrganizations(ctx, userID)
+}
+
+// IsMember checks if user is member of organization
+func (s *OrganizationService) IsMember(ctx context.Context, organizationID, userID int64) (bool, error) {
+	return s.repo.IsOrganizationMember(ctx, organizationID, userID)
+}
+
+// AddMember adds user to organization
+func (s *OrganizationService) AddMember(ctx context.Context, organizationID, userID int64) error {
+	return s.repo.AddOrganizationMember(ctx, organizationID, userID)
+}
+
+// RemoveMember removes user from organization
+func (s *OrganizationService) RemoveMember(ctx context.Context, organizationID, userID int64) error {
+	return s.repo.RemoveOrganizationMember(ctx, organizationID, userID)
+}
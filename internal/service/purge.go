@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CompanyPurger describes repository permanently deleting soft deleted companies
+type CompanyPurger interface {
+	PurgeCompanies(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+// PurgeService permanently deletes companies soft deleted longer than retention period
+type PurgeService struct {
+	repo      CompanyPurger
+	retention time.Duration
+	interval  time.Duration
+	log       *zap.Logger
+}
+
+// NewPurgeService creates new purge service
+func NewPurgeService(repo CompanyPurger, retention, interval time.Duration, log *zap.Logger) *PurgeService {
+	return &PurgeService{
+		repo:      repo,
+		retention: retention,
+		interval:  interval,
+		log:       log,
+	}
+}
+
+// Run purges companies every interval until context is canceled
+func (s *PurgeService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		n, err := s.Purge(ctx)
+		if err != nil {
+			s.log.Error("can not purge companies", zap.Error(err))
+		} else if n > 0 {
+			s.log.Info("companies purged", zap.Int64("count", n))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge permanently deletes companies soft deleted before retention period
+func (s *PurgeService) Purge(ctx context.Context) (int64, error) {
+	return s.repo.PurgeCompanies(ctx, time.Now().Add(-s.retention))
+}
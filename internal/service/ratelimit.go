@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/logging"
+	"go.uber.org/zap"
+)
+
+// RateLimitStore describes storage of token buckets of rate limits
+type RateLimitStore interface {
+	// Take takes token from bucket of key refilled as of now
+	Take(ctx context.Context, key string, limit domain.RateLimit, now time.Time) (domain.RateLimitResult, error)
+}
+
+// RateLimitService is service to limit rate of requests
+type RateLimitService struct {
+	store RateLimitStore
+}
+
+// NewRateLimitService creates new rate limit service
+func NewRateLimitService(store RateLimitStore) *RateLimitService {
+	return &RateLimitService{store: store}
+}
+
+// Allow takes token from bucket of key. Request is allowed if store fails, so that outage of shared store
+// does not fail all requests.
+func (s *RateLimitService) Allow(ctx context.Context, key string, limit domain.RateLimit) domain.RateLimitResult {
+	now := time.Now()
+	result, err := s.store.Take(ctx, key, limit, now)
+	if err != nil {
+		logging.FromContext(ctx).Warn("can not check rate limit, request is allowed", zap.Error(err))
+		return limit.Result(float64(limit.Capacity()), true)
+	}
+	return result
+}
@@ -0,0 +1,38 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/repository/ratelimit"
+	"github.com/Ragnar-BY/companies-handler/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(context.Context, string, domain.RateLimit, time.Time) (domain.RateLimitResult, error) {
+	return domain.RateLimitResult{}, errors.New("down")
+}
+
+func TestRateLimitServiceAllow(t *testing.T) {
+	ctx := context.Background()
+	limit := domain.RateLimit{Requests: 1, Period: time.Minute}
+
+	srv := service.NewRateLimitService(ratelimit.NewMemory())
+	require.True(t, srv.Allow(ctx, "key", limit).Allowed)
+	result := srv.Allow(ctx, "key", limit)
+	require.False(t, result.Allowed)
+	require.Positive(t, result.RetryAfter)
+
+	// requests are allowed while store is down
+	srv = service.NewRateLimitService(failingRateLimitStore{})
+	for i := 0; i < 3; i++ {
+		result = srv.Allow(ctx, "key", limit)
+		require.True(t, result.Allowed)
+		require.Equal(t, 1, result.Remaining)
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+// CompanyCounter describes repository counting companies of all organizations
+type CompanyCounter interface {
+	CountCompaniesByType(ctx context.Context) (map[domain.CompanyType]int64, error)
+}
+
+// CompanyStatsCollector exposes number of companies by type as prometheus gauge. Companies of all
+// organizations are counted at most once per ttl, so that frequent or concurrent scrapes do not load database.
+type CompanyStatsCollector struct {
+	repo    CompanyCounter
+	timeout time.Duration
+	ttl     time.Duration
+	log     *zap.Logger
+	desc    *prometheus.Desc
+
+	mu        sync.Mutex
+	counts    map[domain.CompanyType]int64
+	countedAt time.Time
+}
+
+// NewCompanyStatsCollector creates collector of company stats, counting is canceled after timeout
+// and its result is reused for ttl
+func NewCompanyStatsCollector(repo CompanyCounter, timeout, ttl time.Duration, log *zap.Logger) *CompanyStatsCollector {
+	return &CompanyStatsCollector{
+		repo:    repo,
+		timeout: timeout,
+		ttl:     ttl,
+		log:     log,
+		desc: prometheus.NewDesc("companies", "Number of companies which are not deleted by type.",
+			[]string{"type"}, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *CompanyStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector, gauge is skipped if companies can not be counted
+func (c *CompanyStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := c.count()
+	if err != nil {
+		c.log.Error("can not count companies", zap.Error(err))
+		return
+	}
+	for companyType, n := range counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), string(companyType))
+	}
+}
+
+// count returns counts of companies, concurrent scrapes wait for one count
+func (c *CompanyStatsCollector) count() (map[domain.CompanyType]int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts != nil && time.Since(c.countedAt) < c.ttl {
+		return c.counts, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	counts, err := c.repo.CountCompaniesByType(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.counts, c.countedAt = counts, time.Now()
+	return counts, nil
+}
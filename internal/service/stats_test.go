@@ -0,0 +1,44 @@
+package service_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/service"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// countingCounter counts companies and calls of it
+type countingCounter struct {
+	calls atomic.Int32
+}
+
+func (c *countingCounter) CountCompaniesByType(context.Context) (map[domain.CompanyType]int64, error) {
+	c.calls.Add(1)
+	return map[domain.CompanyType]int64{domain.NonProfit: 2}, nil
+}
+
+func TestCompanyStatsCollector(t *testing.T) {
+	repo := &countingCounter{}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(service.NewCompanyStatsCollector(repo, time.Second, 50*time.Millisecond, zap.NewNop()))
+
+	// scrapes within ttl reuse count
+	for i := 0; i < 3; i++ {
+		n, err := testutil.GatherAndCount(registry, "companies")
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+	}
+	require.Equal(t, int32(1), repo.calls.Load())
+
+	time.Sleep(50 * time.Millisecond)
+	_, err := testutil.GatherAndCount(registry, "companies")
+	require.NoError(t, err)
+	require.Equal(t, int32(2), repo.calls.Load())
+}
@@ -0,0 +1,25 @@
+package service
+
+import "context"
+
+// Transactor describes repository able to run several calls atomically
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// TxService runs calls of services in one transaction of repository
+type TxService struct {
+	repo Transactor
+}
+
+// NewTxService creates new transaction service
+func NewTxService(repo Transactor) *TxService {
+	return &TxService{repo: repo}
+}
+
+// WithinTx runs fn in transaction, calls of services with context passed to fn are committed
+// together if fn returns nil and rolled back otherwise. fn can be run again if transaction
+// conflicts with concurrent one, so it must not have side effects outside of repository.
+func (s *TxService) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.repo.WithinTx(ctx, fn)
+}
@@ -10,6 +10,11 @@ import (
 type UserRepository interface {
 	CreateUser(ctx context.Context, u domain.User) (*domain.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
+	GetUserByID(ctx context.Context, id int64) (*domain.User, error)
+	UpdateUser(ctx context.Context, u domain.User) (*domain.User, error)
+	DeleteUser(ctx context.Context, id int64) error
+	GetUserByPasswordResetToken(ctx context.Context, token string) (*domain.User, error)
+	SelectUsers(ctx context.Context, filter domain.UserFilter) ([]domain.User, error)
 }
 
 type UserService struct {
@@ -28,3 +33,28 @@ func (s *UserService) CreateUser(ctx context.Context, u domain.User) (*domain.Us
 func (s *UserService) GetUserByEmail(ctx context.Context, email string) (*domain.User, error) {
 	return s.repo.GetUserByEmail(ctx, email)
 }
+
+// GetUserByID gets user by id
+func (s *UserService) GetUserByID(ctx context.Context, id int64) (*domain.User, error) {
+	return s.repo.GetUserByID(ctx, id)
+}
+
+// UpdateUser updates user
+func (s *UserService) UpdateUser(ctx context.Context, u domain.User) (*domain.User, error) {
+	return s.repo.UpdateUser(ctx, u)
+}
+
+// DeleteUser deletes user by id
+func (s *UserService) DeleteUser(ctx context.Context, id int64) error {
+	return s.repo.DeleteUser(ctx, id)
+}
+
+// GetUserByPasswordResetToken gets user by hash of password reset token
+func (s *UserService) GetUserByPasswordResetToken(ctx context.Context, token string) (*domain.User, error) {
+	return s.repo.GetUserByPasswordResetToken(ctx, token)
+}
+
+// SelectUsers selects users matching filter
+func (s *UserService) SelectUsers(ctx context.Context, filter domain.UserFilter) ([]domain.User, error) {
+	return s.repo.SelectUsers(ctx, filter)
+}
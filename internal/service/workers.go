@@ -0,0 +1,267 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"go.uber.org/zap"
+)
+
+// finishTimeout limits storing of job status after job is done
+const finishTimeout = 10 * time.Second
+
+// JobHandler executes job of some type. Result artifact must be written to job.Result,
+// number of processed rows is reported with progress. Handler must stop when context is cancelled.
+type JobHandler func(ctx context.Context, job domain.Job, progress func(done int)) error
+
+// WorkerSettings are settings of job workers
+type WorkerSettings struct {
+	Workers      int
+	PollInterval time.Duration
+	// HeartbeatInterval is how often running job stores progress and checks cancellation
+	HeartbeatInterval time.Duration
+	// StaleAfter is time without heartbeat after which running job is claimed by another worker
+	StaleAfter      time.Duration
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+}
+
+// JobWorkers is pool of workers executing queued jobs
+type JobWorkers struct {
+	repo      JobRepository
+	artifacts *ArtifactStore
+	settings  WorkerSettings
+	handlers  map[domain.JobType]JobHandler
+	log       *zap.Logger
+
+	stop       chan struct{}
+	jobCtx     context.Context
+	cancelJobs context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// NewJobWorkers creates new pool of workers
+func NewJobWorkers(repo JobRepository, artifacts *ArtifactStore, settings WorkerSettings, log *zap.Logger) *JobWorkers {
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	return &JobWorkers{
+		repo:       repo,
+		artifacts:  artifacts,
+		settings:   settings,
+		handlers:   make(map[domain.JobType]JobHandler),
+		log:        log,
+		stop:       make(chan struct{}),
+		jobCtx:     jobCtx,
+		cancelJobs: cancelJobs,
+	}
+}
+
+// Handle registers handler of jobs of type, it must be called before Start
+func (w *JobWorkers) Handle(jobType domain.JobType, handler JobHandler) {
+	w.handlers[jobType] = handler
+}
+
+// Start starts workers
+func (w *JobWorkers) Start() {
+	for i := 0; i < w.settings.Workers; i++ {
+		w.wg.Add(1)
+		go w.work()
+	}
+}
+
+// Shutdown stops claiming new jobs and waits for running jobs to finish. When context is done
+// running jobs are cancelled and queued again, they are continued by next start of workers.
+func (w *JobWorkers) Shutdown(ctx context.Context) error {
+	close(w.stop)
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		w.cancelJobs()
+		return nil
+	case <-ctx.Done():
+		w.cancelJobs()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (w *JobWorkers) work() {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+		job, err := w.repo.ClaimJob(w.jobCtx, w.settings.StaleAfter)
+		if err == nil {
+			w.run(job)
+			continue
+		}
+		if !errors.Is(err, domain.ErrJobQueueIsEmpty) {
+			w.log.Error("can not claim job", zap.Error(err))
+		}
+		select {
+		case <-w.stop:
+			return
+		case <-time.After(w.settings.PollInterval):
+		}
+	}
+}
+
+// run executes claimed job and stores its outcome
+func (w *JobWorkers) run(job domain.Job) {
+	log := w.log.With(zap.Stringer("job_id", job.ID), zap.String("job_type", string(job.Type)),
+		zap.Int("attempt", job.Attempts))
+	handler, ok := w.handlers[job.Type]
+	if !ok {
+		job.Status = domain.JobFailed
+		job.Error = domain.ErrUnknownJobType.Error()
+		w.finish(log, job)
+		return
+	}
+	if job.CancelRequested {
+		job.Status = domain.JobCancelled
+		w.finish(log, job)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(w.jobCtx)
+	defer cancel()
+	ctx = domain.WithTenant(ctx, job.OrganizationID)
+	if job.UserID != 0 {
+		ctx = domain.WithActor(ctx, job.UserID)
+	}
+
+	var progress atomic.Int64
+	var cancelled, claimLost atomic.Bool
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		ticker := time.NewTicker(w.settings.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			cancelRequested, err := w.repo.HeartbeatJob(ctx, job, int(progress.Load()))
+			if errors.Is(err, domain.ErrJobClaimLost) {
+				// job is considered stale and runs on another worker, this attempt is abandoned
+				claimLost.Store(true)
+				cancel()
+				return
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Warn("can not store job heartbeat", zap.Error(err))
+				}
+				continue
+			}
+			if cancelRequested {
+				cancelled.Store(true)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	log.Info("job started")
+	job.Result = w.resultPath(job)
+	err := handler(ctx, job, func(done int) {
+		progress.Store(int64(done))
+	})
+	cancel()
+	<-heartbeatDone
+	job.Progress = int(progress.Load())
+
+	switch {
+	case claimLost.Load():
+		log.Warn("job is claimed by another worker, attempt is abandoned")
+		w.removeResult(log, job)
+		return
+	case err == nil:
+		job.Status = domain.JobSucceeded
+		job.Error = ""
+	case cancelled.Load():
+		job.Status = domain.JobCancelled
+		job.Result = ""
+	case w.jobCtx.Err() != nil:
+		// interrupted by shutdown, attempt is not counted
+		job.Attempts--
+		w.retry(log, job, 0)
+		return
+	case job.Attempts < job.MaxAttempts:
+		job.Error = err.Error()
+		w.retry(log, job, w.backoff(job.Attempts))
+		return
+	default:
+		job.Status = domain.JobFailed
+		job.Error = err.Error()
+		job.Result = ""
+	}
+	w.finish(log, job)
+}
+
+// finish stores final status of job and removes artifacts which are not needed anymore. Artifacts are kept
+// if status is not stored, e.g. job was claimed by another worker which still needs them.
+func (w *JobWorkers) finish(log *zap.Logger, job domain.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), finishTimeout)
+	defer cancel()
+	if err := w.repo.FinishJob(ctx, job); err != nil {
+		log.Error("can not finish job", zap.Error(err))
+		return
+	}
+	if job.Status != domain.JobSucceeded {
+		w.removeResult(log, job)
+	}
+	if err := w.artifacts.Remove(job.Input); err != nil {
+		log.Warn("can not remove job input", zap.Error(err))
+	}
+	log.Info("job finished", zap.String("status", string(job.Status)), zap.String("error", job.Error))
+}
+
+// resultPath returns path of result artifact of job attempt. It includes claim token, so that worker which
+// lost its claim does not overwrite or remove result of attempt which claimed job again.
+func (w *JobWorkers) resultPath(job domain.Job) string {
+	return w.artifacts.Path(job.ID.String() + "." + job.ClaimToken.String() + ".result")
+}
+
+// removeResult removes result artifact of job attempt
+func (w *JobWorkers) removeResult(log *zap.Logger, job domain.Job) {
+	if err := w.artifacts.Remove(w.resultPath(job)); err != nil {
+		log.Warn("can not remove job result", zap.Error(err))
+	}
+}
+
+// retry queues job again to run after delay
+func (w *JobWorkers) retry(log *zap.Logger, job domain.Job, delay time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), finishTimeout)
+	defer cancel()
+	if err := w.repo.RetryJob(ctx, job, delay); err != nil {
+		log.Error("can not retry job", zap.Error(err))
+		return
+	}
+	w.removeResult(log, job)
+	log.Info("job queued again", zap.Duration("delay", delay), zap.String("error", job.Error))
+}
+
+// backoff returns delay before next attempt, it doubles with every attempt
+func (w *JobWorkers) backoff(attempt int) time.Duration {
+	delay := w.settings.RetryBackoff
+	for i := 1; i < attempt && delay < w.settings.MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > w.settings.MaxRetryBackoff {
+		delay = w.settings.MaxRetryBackoff
+	}
+	return delay
+}
@@ -0,0 +1,251 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/service"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// memoryJobs is job repository in memory
+type memoryJobs struct {
+	mu   sync.Mutex
+	jobs map[uuid.UUID]domain.Job
+}
+
+func newMemoryJobs(jobs ...domain.Job) *memoryJobs {
+	m := &memoryJobs{jobs: make(map[uuid.UUID]domain.Job)}
+	for _, j := range jobs {
+		m.jobs[j.ID] = j
+	}
+	return m
+}
+
+func (m *memoryJobs) get(id uuid.UUID) domain.Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.jobs[id]
+}
+
+func (m *memoryJobs) CreateJob(_ context.Context, job domain.Job) (domain.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job.ID = uuid.New()
+	job.Status = domain.JobQueued
+	m.jobs[job.ID] = job
+	return job, nil
+}
+
+func (m *memoryJobs) GetJob(_ context.Context, id uuid.UUID) (domain.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return domain.Job{}, domain.ErrJobNotFound
+	}
+	return job, nil
+}
+
+func (m *memoryJobs) CancelJob(_ context.Context, id uuid.UUID) (domain.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.jobs[id]
+	job.CancelRequested = true
+	m.jobs[id] = job
+	return job, nil
+}
+
+func (m *memoryJobs) ClaimJob(_ context.Context, _ time.Duration) (domain.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, job := range m.jobs {
+		if job.Status == domain.JobQueued && !job.RunAt.After(time.Now()) {
+			job.Status = domain.JobRunning
+			job.Attempts++
+			job.ClaimToken = uuid.New()
+			m.jobs[id] = job
+			return job, nil
+		}
+	}
+	return domain.Job{}, domain.ErrJobQueueIsEmpty
+}
+
+func (m *memoryJobs) HeartbeatJob(_ context.Context, claimed domain.Job, progress int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.jobs[claimed.ID]
+	if job.ClaimToken != claimed.ClaimToken {
+		return false, domain.ErrJobClaimLost
+	}
+	job.Progress = progress
+	m.jobs[job.ID] = job
+	return job.CancelRequested, nil
+}
+
+func (m *memoryJobs) FinishJob(_ context.Context, job domain.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.jobs[job.ID].ClaimToken != job.ClaimToken {
+		return domain.ErrJobClaimLost
+	}
+	m.jobs[job.ID] = job
+	return nil
+}
+
+func (m *memoryJobs) RetryJob(_ context.Context, job domain.Job, delay time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.jobs[job.ID].ClaimToken != job.ClaimToken {
+		return domain.ErrJobClaimLost
+	}
+	job.Status = domain.JobQueued
+	job.RunAt = time.Now().Add(delay)
+	job.ClaimToken = uuid.Nil
+	m.jobs[job.ID] = job
+	return nil
+}
+
+// reclaim claims running job again as another worker does when job is stale
+func (m *memoryJobs) reclaim(id uuid.UUID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job := m.jobs[id]
+	job.Attempts++
+	job.ClaimToken = uuid.New()
+	m.jobs[id] = job
+}
+
+func newTestWorkers(t *testing.T, repo service.JobRepository) *service.JobWorkers {
+	artifacts, err := service.NewArtifactStore(t.TempDir())
+	require.NoError(t, err)
+	return service.NewJobWorkers(repo, artifacts, service.WorkerSettings{
+		Workers:           1,
+		PollInterval:      5 * time.Millisecond,
+		HeartbeatInterval: 5 * time.Millisecond,
+		StaleAfter:        time.Minute,
+		RetryBackoff:      10 * time.Millisecond,
+		MaxRetryBackoff:   20 * time.Millisecond,
+	}, zap.NewNop())
+}
+
+func waitStatus(t *testing.T, repo *memoryJobs, id uuid.UUID, status domain.JobStatus) domain.Job {
+	require.Eventually(t, func() bool {
+		return repo.get(id).Status == status
+	}, 5*time.Second, 5*time.Millisecond)
+	return repo.get(id)
+}
+
+func TestJobWorkers_Retry(t *testing.T) {
+	testCases := []struct {
+		name        string
+		failures    int
+		maxAttempts int
+		status      domain.JobStatus
+		attempts    int
+	}{
+		{
+			name:        "succeeds after retry",
+			failures:    2,
+			maxAttempts: 3,
+			status:      domain.JobSucceeded,
+			attempts:    3,
+		},
+		{
+			name:        "fails after max attempts",
+			failures:    3,
+			maxAttempts: 2,
+			status:      domain.JobFailed,
+			attempts:    2,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			id := uuid.New()
+			repo := newMemoryJobs(domain.Job{ID: id, Type: domain.JobExport, Status: domain.JobQueued, MaxAttempts: tt.maxAttempts})
+			workers := newTestWorkers(t, repo)
+			calls := 0
+			workers.Handle(domain.JobExport, func(ctx context.Context, job domain.Job, progress func(done int)) error {
+				calls++
+				if calls <= tt.failures {
+					return errors.New("temporary failure")
+				}
+				return nil
+			})
+			workers.Start()
+			job := waitStatus(t, repo, id, tt.status)
+			require.NoError(t, workers.Shutdown(context.Background()))
+			require.Equal(t, tt.attempts, job.Attempts)
+		})
+	}
+}
+
+func TestJobWorkers_Cancel(t *testing.T) {
+	id := uuid.New()
+	repo := newMemoryJobs(domain.Job{ID: id, Type: domain.JobExport, Status: domain.JobQueued, MaxAttempts: 3})
+	workers := newTestWorkers(t, repo)
+	workers.Handle(domain.JobExport, func(ctx context.Context, job domain.Job, progress func(done int)) error {
+		progress(1)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	workers.Start()
+	waitStatus(t, repo, id, domain.JobRunning)
+	_, err := repo.CancelJob(context.Background(), id)
+	require.NoError(t, err)
+
+	job := waitStatus(t, repo, id, domain.JobCancelled)
+	require.NoError(t, workers.Shutdown(context.Background()))
+	require.Equal(t, 1, job.Progress)
+}
+
+func TestJobWorkers_ShutdownRequeues(t *testing.T) {
+	id := uuid.New()
+	repo := newMemoryJobs(domain.Job{ID: id, Type: domain.JobExport, Status: domain.JobQueued, MaxAttempts: 3})
+	workers := newTestWorkers(t, repo)
+	workers.Handle(domain.JobExport, func(ctx context.Context, job domain.Job, progress func(done int)) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	workers.Start()
+	waitStatus(t, repo, id, domain.JobRunning)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, workers.Shutdown(ctx), context.DeadlineExceeded)
+
+	job := repo.get(id)
+	require.Equal(t, domain.JobQueued, job.Status)
+	require.Equal(t, 0, job.Attempts)
+}
+
+func TestJobWorkers_ClaimLost(t *testing.T) {
+	id := uuid.New()
+	repo := newMemoryJobs(domain.Job{ID: id, Type: domain.JobExport, Status: domain.JobQueued, MaxAttempts: 3})
+	workers := newTestWorkers(t, repo)
+	stopped := make(chan struct{})
+	workers.Handle(domain.JobExport, func(ctx context.Context, job domain.Job, progress func(done int)) error {
+		<-ctx.Done()
+		close(stopped)
+		return ctx.Err()
+	})
+	workers.Start()
+	waitStatus(t, repo, id, domain.JobRunning)
+	repo.reclaim(id)
+	reclaimed := repo.get(id)
+
+	// handler of lost claim is stopped and job of another worker is not changed
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler of lost claim is not stopped")
+	}
+	require.NoError(t, workers.Shutdown(context.Background()))
+	require.Equal(t, reclaimed, repo.get(id))
+}
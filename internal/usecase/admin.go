@@ -0,0 +1,189 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	auditTopic         = "audit"
+	resetPasswordTopic = "reset-password"
+
+	passwordResetExpireAt = 24 * time.Hour
+)
+
+const (
+	auditListUsers   = "list-users"
+	auditViewUser    = "view-user"
+	auditSetRole     = "set-role"
+	auditDisableUser = "disable-user"
+	auditEnableUser  = "enable-user"
+	auditForceReset  = "force-password-reset"
+	auditDeleteUser  = "delete-user"
+)
+
+// auditEvent is event about action of admin
+type auditEvent struct {
+	ActorID  int64     `json:"actor_id"`
+	Action   string    `json:"action"`
+	TargetID int64     `json:"target_id,omitempty"`
+	Details  any       `json:"details,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+// passwordReset is event sent to user when admin forced password reset
+type passwordReset struct {
+	UserID int64  `json:"user_id"`
+	Email  string `json:"email"`
+	Token  string `json:"token"`
+}
+
+// AdminUsecase is usecase for user management by admins
+type AdminUsecase struct {
+	users  UserService
+	events EventService
+	tx     TxManager
+}
+
+// NewAdminUsecase creates new admin usecase
+func NewAdminUsecase(users UserService, events EventService, tx TxManager) *AdminUsecase {
+	return &AdminUsecase{users: users, events: events, tx: tx}
+}
+
+// ListUsers selects users matching filter
+func (u *AdminUsecase) ListUsers(ctx context.Context, actor domain.User, filter domain.UserFilter) ([]domain.User, error) {
+	users, err := u.users.SelectUsers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var details any
+	if filter.Query != "" {
+		details = map[string]string{"query": filter.Query}
+	}
+	u.audit(ctx, actor, auditListUsers, 0, details)
+	return users, nil
+}
+
+// GetUser gets user by id
+func (u *AdminUsecase) GetUser(ctx context.Context, actor domain.User, id int64) (*domain.User, error) {
+	user, err := u.users.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	u.audit(ctx, actor, auditViewUser, id, nil)
+	return user, nil
+}
+
+// SetRole changes role of user
+func (u *AdminUsecase) SetRole(ctx context.Context, actor domain.User, id int64, role domain.Role) (*domain.User, error) {
+	if actor.ID == id {
+		return nil, domain.ErrSelfAdminAction
+	}
+	var previous domain.Role
+	user, err := u.updateUser(ctx, id, func(user *domain.User) {
+		previous = user.Role
+		user.Role = role
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.audit(ctx, actor, auditSetRole, id, map[string]domain.Role{"from": previous, "to": role})
+	return user, nil
+}
+
+// SetDisabled disables or enables user
+func (u *AdminUsecase) SetDisabled(ctx context.Context, actor domain.User, id int64, disabled bool) (*domain.User, error) {
+	if actor.ID == id {
+		return nil, domain.ErrSelfAdminAction
+	}
+	user, err := u.updateUser(ctx, id, func(user *domain.User) {
+		user.Disabled = disabled
+	})
+	if err != nil {
+		return nil, err
+	}
+	action := auditEnableUser
+	if disabled {
+		action = auditDisableUser
+	}
+	u.audit(ctx, actor, action, id, nil)
+	return user, nil
+}
+
+// ForcePasswordReset invalidates password of user and sends reset token to user. Failure to send
+// token is logged, password stays invalidated.
+func (u *AdminUsecase) ForcePasswordReset(ctx context.Context, actor domain.User, id int64) error {
+	if actor.ID == id {
+		return domain.ErrSelfAdminAction
+	}
+	token, hash, err := newVerificationToken()
+	if err != nil {
+		return err
+	}
+	user, err := u.updateUser(ctx, id, func(user *domain.User) {
+		user.Password = ""
+		user.PasswordResetToken = hash
+		user.PasswordResetExpiresAt = time.Now().Add(passwordResetExpireAt)
+	})
+	if err != nil {
+		return err
+	}
+	u.audit(ctx, actor, auditForceReset, id, nil)
+	// password is already invalidated, reset of user whose event is lost is forced again
+	err = u.events.SendEvent(ctx, resetPasswordTopic, passwordReset{
+		UserID: user.ID,
+		Email:  user.Email,
+		Token:  token,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("can not send password reset event", zap.Int64("user_id", user.ID), zap.Error(err))
+	}
+	return nil
+}
+
+// DeleteUser deletes user
+func (u *AdminUsecase) DeleteUser(ctx context.Context, actor domain.User, id int64) error {
+	if actor.ID == id {
+		return domain.ErrSelfAdminAction
+	}
+	err := u.users.DeleteUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.audit(ctx, actor, auditDeleteUser, id, nil)
+	return nil
+}
+
+// updateUser applies change to current state of user, user is read and updated in one transaction
+func (u *AdminUsecase) updateUser(ctx context.Context, id int64, change func(user *domain.User)) (*domain.User, error) {
+	var user *domain.User
+	err := u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		current, err := u.users.GetUserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		change(current)
+		user, err = u.users.UpdateUser(ctx, *current)
+		return err
+	})
+	return user, err
+}
+
+// audit sends audit event, failure to send it is logged, action is already done
+func (u *AdminUsecase) audit(ctx context.Context, actor domain.User, action string, target int64, details any) {
+	err := u.events.SendEvent(ctx, auditTopic, auditEvent{
+		ActorID:  actor.ID,
+		Action:   action,
+		TargetID: target,
+		Details:  details,
+		At:       time.Now().UTC(),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("can not send audit event", zap.String("action", action),
+			zap.Int64("actor_id", actor.ID), zap.Int64("target_id", target), zap.Error(err))
+	}
+}
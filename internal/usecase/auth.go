@@ -7,50 +7,98 @@ import (
 )
 
 type AuthService interface {
-	GenerateJWT(email string, username string) (string, error)
-	ValidateToken(signedToken string) error
+	GenerateJWT(user domain.User) (string, error)
+	ValidateToken(signedToken string) (domain.User, error)
 }
 
 type AuthUsecase struct {
 	auth  AuthService
 	users UserService
+	orgs  OrganizationService
 }
 
-func NewAuthUsecase(auth AuthService, users UserService) AuthUsecase {
+func NewAuthUsecase(auth AuthService, users UserService, orgs OrganizationService) AuthUsecase {
 	return AuthUsecase{
 		auth:  auth,
 		users: users,
+		orgs:  orgs,
 	}
 }
 
-func (u AuthUsecase) ValidateToken(token string) error {
-	return u.auth.ValidateToken(token)
+// ValidateToken validates token and returns current state of its user,
+// tokens of disabled users, users with forced password reset and users removed
+// from organization of token are rejected
+func (u AuthUsecase) ValidateToken(ctx context.Context, token string) (_ domain.User, err error) {
+	ctx, span := startSpan(ctx, "AuthUsecase.ValidateToken")
+	defer func() { endSpan(span, err) }()
+	claims, err := u.auth.ValidateToken(token)
+	if err != nil {
+		return domain.User{}, err
+	}
+	user, err := u.users.GetUserByID(ctx, claims.ID)
+	if err != nil {
+		return domain.User{}, err
+	}
+	err = checkUserActive(user)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if claims.OrganizationID != 0 {
+		member, err := u.orgs.IsMember(ctx, claims.OrganizationID, user.ID)
+		if err != nil {
+			return domain.User{}, err
+		}
+		if !member {
+			return domain.User{}, domain.ErrNotOrganizationMember
+		}
+	}
+	user.OrganizationID = claims.OrganizationID
+	return *user, nil
 }
 
-func (u AuthUsecase) SignUp(ctx context.Context, user domain.User) (string, error) {
-	_, err := u.users.CreateUser(ctx, user)
+func (u AuthUsecase) SignUp(ctx context.Context, user domain.User) (_ string, err error) {
+	ctx, span := startSpan(ctx, "AuthUsecase.SignUp")
+	defer func() { endSpan(span, err) }()
+	newUser, err := u.users.CreateUser(ctx, user)
 	if err != nil {
 		return "", err
 	}
-	token, err := u.auth.GenerateJWT(user.Email, user.Username)
+	token, err := issueToken(ctx, u.auth, u.orgs, *newUser)
 	if err != nil {
 		return "", err
 	}
 	return token, nil
 }
 
-func (u AuthUsecase) SignIn(ctx context.Context, email, password string) (string, error) {
+func (u AuthUsecase) SignIn(ctx context.Context, email, password string) (_ string, err error) {
+	ctx, span := startSpan(ctx, "AuthUsecase.SignIn")
+	defer func() { endSpan(span, err) }()
 	user, err := u.users.GetUserByEmail(ctx, email)
 	if err != nil {
 		return "", err
 	}
+	// state of account is revealed only to whom knows its password
 	err = user.CheckPassword(password)
 	if err != nil {
 		return "", err
 	}
-	token, err := u.auth.GenerateJWT(user.Email, user.Username)
+	err = checkUserActive(user)
+	if err != nil {
+		return "", err
+	}
+	token, err := issueToken(ctx, u.auth, u.orgs, *user)
 	if err != nil {
 		return "", err
 	}
 	return token, nil
 }
+
+func checkUserActive(user *domain.User) error {
+	if user.Disabled {
+		return domain.ErrUserDisabled
+	}
+	if user.PasswordResetToken != "" {
+		return domain.ErrPasswordResetRequired
+	}
+	return nil
+}
@@ -2,78 +2,263 @@ package usecase
 
 import (
 	"context"
+	"time"
 
 	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/logging"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 const (
-	createCompanyTopic = "create-company"
-	updateCompanyTopic = "update-company"
-	deleteCompanyTopic = "delete-company"
+	createCompanyTopic  = "create-company"
+	updateCompanyTopic  = "update-company"
+	deleteCompanyTopic  = "delete-company"
+	restoreCompanyTopic = "restore-company"
+	importCompanyTopic  = "import-companies"
 )
 
 // CompanyService describes interface for company service
 type CompanyService interface {
 	Create(ctx context.Context, company domain.Company) (uuid.UUID, error)
 	Get(ctx context.Context, id uuid.UUID) (domain.Company, error)
-	Select(ctx context.Context, limit, offset int) ([]domain.Company, error)
+	Select(ctx context.Context, filter domain.CompanyFilter) ([]domain.Company, error)
 	Delete(ctx context.Context, id uuid.UUID) error
+	Restore(ctx context.Context, id uuid.UUID) error
 	Update(ctx context.Context, uuid uuid.UUID, company domain.Company) error
+	GetAsOf(ctx context.Context, id uuid.UUID, asOf time.Time) (domain.Company, error)
+	History(ctx context.Context, id uuid.UUID) ([]domain.CompanyChange, error)
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+	Import(ctx context.Context, src domain.CompanySource) (domain.ImportResult, error)
+	Export(ctx context.Context, filter domain.CompanyFilter, fn func(domain.Company) error) error
 }
 
 // EventService describe service for sending events in message broker
 type EventService interface {
-	SendEvent(topic string, message any) error
+	SendEvent(ctx context.Context, topic string, message any) error
+}
+
+// CompanyCache describes cache of companies
+type CompanyCache interface {
+	// Invalidate drops cached companies of organization of context
+	Invalidate(ctx context.Context)
 }
 
 // CompanyUsecase is usecase for companies
 type CompanyUsecase struct {
 	srv    CompanyService
 	events EventService
+	cache  CompanyCache
+}
+
+// CompanyOption configures optional features of company usecase
+type CompanyOption func(u *CompanyUsecase)
+
+// WithCompanyCache invalidates cache on every change of companies
+func WithCompanyCache(cache CompanyCache) CompanyOption {
+	return func(u *CompanyUsecase) {
+		u.cache = cache
+	}
 }
 
 // NewCompanyUsecase creates new company usecase
-func NewCompanyUsecase(companies CompanyService, events EventService) *CompanyUsecase {
-	return &CompanyUsecase{srv: companies, events: events}
+func NewCompanyUsecase(companies CompanyService, events EventService, opts ...CompanyOption) *CompanyUsecase {
+	u := &CompanyUsecase{srv: companies, events: events}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// invalidate invalidates cache after change of companies
+func (u *CompanyUsecase) invalidate(ctx context.Context) {
+	if u.cache != nil {
+		u.cache.Invalidate(ctx)
+	}
 }
 
 // Create creates new company
-func (u *CompanyUsecase) Create(ctx context.Context, company domain.Company) (uuid.UUID, error) {
+func (u *CompanyUsecase) Create(ctx context.Context, company domain.Company) (_ uuid.UUID, err error) {
+	ctx, span := startSpan(ctx, "CompanyUsecase.Create")
+	defer func() { endSpan(span, err) }()
 	id, err := u.srv.Create(ctx, company)
 	if err != nil {
 		return uuid.Nil, err
 	}
-	err = u.events.SendEvent(createCompanyTopic, id)
+	u.invalidate(ctx)
+	err = u.events.SendEvent(ctx, createCompanyTopic, id)
 	return id, err
 }
 
 // Get gets new company
-func (u *CompanyUsecase) Get(ctx context.Context, id uuid.UUID) (domain.Company, error) {
+func (u *CompanyUsecase) Get(ctx context.Context, id uuid.UUID) (_ domain.Company, err error) {
+	ctx, span := startSpan(ctx, "CompanyUsecase.Get")
+	defer func() { endSpan(span, err) }()
 	return u.srv.Get(ctx, id)
 }
 
 // Select selects list of companies
-func (u *CompanyUsecase) Select(ctx context.Context, limit, offset int) ([]domain.Company, error) {
-	return u.srv.Select(ctx, limit, offset)
+func (u *CompanyUsecase) Select(ctx context.Context, filter domain.CompanyFilter) (_ []domain.Company, err error) {
+	ctx, span := startSpan(ctx, "CompanyUsecase.Select")
+	defer func() { endSpan(span, err) }()
+	return u.srv.Select(ctx, filter)
 }
 
 // Delete deletes company
-func (u *CompanyUsecase) Delete(ctx context.Context, id uuid.UUID) error {
-	err := u.srv.Delete(ctx, id)
+func (u *CompanyUsecase) Delete(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := startSpan(ctx, "CompanyUsecase.Delete")
+	defer func() { endSpan(span, err) }()
+	err = u.srv.Delete(ctx, id)
 	if err != nil {
 		return err
 	}
-	return u.events.SendEvent(deleteCompanyTopic, id)
+	u.invalidate(ctx)
+	return u.events.SendEvent(ctx, deleteCompanyTopic, id)
+}
+
+// Restore restores deleted company
+func (u *CompanyUsecase) Restore(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := startSpan(ctx, "CompanyUsecase.Restore")
+	defer func() { endSpan(span, err) }()
+	err = u.srv.Restore(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.invalidate(ctx)
+	return u.events.SendEvent(ctx, restoreCompanyTopic, id)
 }
 
 // Update updates company
-func (u *CompanyUsecase) Update(ctx context.Context, id uuid.UUID, company domain.Company) error {
+func (u *CompanyUsecase) Update(ctx context.Context, id uuid.UUID, company domain.Company) (err error) {
+	ctx, span := startSpan(ctx, "CompanyUsecase.Update")
+	defer func() { endSpan(span, err) }()
 	company.ID = id
-	err := u.srv.Update(ctx, id, company)
+	err = u.srv.Update(ctx, id, company)
 
 	if err != nil {
 		return err
 	}
-	return u.events.SendEvent(updateCompanyTopic, company)
+	u.invalidate(ctx)
+	return u.events.SendEvent(ctx, updateCompanyTopic, company)
+}
+
+// GetAsOf gets state of company at moment of time
+func (u *CompanyUsecase) GetAsOf(ctx context.Context, id uuid.UUID, asOf time.Time) (_ domain.Company, err error) {
+	ctx, span := startSpan(ctx, "CompanyUsecase.GetAsOf")
+	defer func() { endSpan(span, err) }()
+	return u.srv.GetAsOf(ctx, id, asOf)
+}
+
+// History selects history of company changes
+func (u *CompanyUsecase) History(ctx context.Context, id uuid.UUID) (_ []domain.CompanyChange, err error) {
+	ctx, span := startSpan(ctx, "CompanyUsecase.History")
+	defer func() { endSpan(span, err) }()
+	return u.srv.History(ctx, id)
+}
+
+// Batch executes operations. In atomic mode operations are executed in one transaction which
+// is rolled back if any of them fails, otherwise every operation is executed independently.
+// Events are sent for every operation that succeeded, operation whose event failed is still reported
+// as succeeded, since its change is already written. Failed events are logged and counted by event service.
+func (u *CompanyUsecase) Batch(ctx context.Context, ops []domain.CompanyOperation, atomic bool) []domain.CompanyOperationResult {
+	ctx, span := startSpan(ctx, "CompanyUsecase.Batch")
+	defer span.End()
+	results := make([]domain.CompanyOperationResult, len(ops))
+	defer u.invalidate(ctx)
+	if !atomic {
+		for i, op := range ops {
+			results[i] = u.execute(ctx, op)
+			if results[i].Err == nil {
+				u.sendOperationEvent(ctx, op, results[i].ID)
+			}
+		}
+		return results
+	}
+
+	failed := -1
+	err := u.srv.WithinTx(ctx, func(ctx context.Context) error {
+		for i, op := range ops {
+			results[i] = u.execute(ctx, op)
+			if results[i].Err != nil {
+				failed = i
+				return results[i].Err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for i := range results {
+			if i == failed {
+				continue
+			}
+			results[i] = domain.CompanyOperationResult{ID: ops[i].ID, Err: domain.ErrBatchRolledBack}
+			if failed == -1 {
+				// transaction failed on commit
+				results[i].Err = err
+			}
+		}
+		return results
+	}
+	for i, op := range ops {
+		u.sendOperationEvent(ctx, op, results[i].ID)
+	}
+	return results
+}
+
+// Import upserts companies from source by name. In dry run mode source is only read
+// to the end, so that it is validated, and nothing is written.
+func (u *CompanyUsecase) Import(ctx context.Context, src domain.CompanySource, dryRun bool) (_ domain.ImportResult, err error) {
+	ctx, span := startSpan(ctx, "CompanyUsecase.Import")
+	defer func() { endSpan(span, err) }()
+	if dryRun {
+		for src.Next() {
+		}
+		return domain.ImportResult{}, src.Err()
+	}
+	result, err := u.srv.Import(ctx, src)
+	if err != nil {
+		return domain.ImportResult{}, err
+	}
+	u.invalidate(ctx)
+	return result, u.events.SendEvent(ctx, importCompanyTopic, result)
+}
+
+// Export streams companies matching filter to fn
+func (u *CompanyUsecase) Export(ctx context.Context, filter domain.CompanyFilter, fn func(domain.Company) error) (err error) {
+	ctx, span := startSpan(ctx, "CompanyUsecase.Export")
+	defer func() { endSpan(span, err) }()
+	return u.srv.Export(ctx, filter, fn)
+}
+
+// execute executes operation of batch without sending event
+func (u *CompanyUsecase) execute(ctx context.Context, op domain.CompanyOperation) domain.CompanyOperationResult {
+	switch op.Action {
+	case domain.CompanyCreated:
+		id, err := u.srv.Create(ctx, op.Company)
+		return domain.CompanyOperationResult{ID: id, Err: err}
+	case domain.CompanyUpdated:
+		op.Company.ID = op.ID
+		return domain.CompanyOperationResult{ID: op.ID, Err: u.srv.Update(ctx, op.ID, op.Company)}
+	case domain.CompanyDeleted:
+		return domain.CompanyOperationResult{ID: op.ID, Err: u.srv.Delete(ctx, op.ID)}
+	}
+	return domain.CompanyOperationResult{ID: op.ID, Err: domain.ErrUnknownOperation}
+}
+
+// sendOperationEvent sends event of operation of batch, failure is logged
+func (u *CompanyUsecase) sendOperationEvent(ctx context.Context, op domain.CompanyOperation, id uuid.UUID) {
+	var err error
+	switch op.Action {
+	case domain.CompanyCreated:
+		err = u.events.SendEvent(ctx, createCompanyTopic, id)
+	case domain.CompanyUpdated:
+		op.Company.ID = id
+		err = u.events.SendEvent(ctx, updateCompanyTopic, op.Company)
+	case domain.CompanyDeleted:
+		err = u.events.SendEvent(ctx, deleteCompanyTopic, id)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Error("can not send event of batch operation", zap.String("action", string(op.Action)),
+			zap.Stringer("id", id), zap.Error(err))
+	}
 }
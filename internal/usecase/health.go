@@ -0,0 +1,23 @@
+package usecase
+
+import "github.com/Ragnar-BY/companies-handler/internal/domain"
+
+// HealthService describes health service
+type HealthService interface {
+	Ready() domain.HealthReport
+}
+
+// HealthUsecase is usecase for health checks of instance
+type HealthUsecase struct {
+	srv HealthService
+}
+
+// NewHealthUsecase creates new health usecase
+func NewHealthUsecase(srv HealthService) *HealthUsecase {
+	return &HealthUsecase{srv: srv}
+}
+
+// Ready returns report of checks of dependencies
+func (u *HealthUsecase) Ready() domain.HealthReport {
+	return u.srv.Ready()
+}
@@ -0,0 +1,55 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+)
+
+// IdempotencyService describes idempotency service
+type IdempotencyService interface {
+	Start(ctx context.Context, key domain.IdempotencyKey, ttl, lease time.Duration) (domain.IdempotencyKey, bool, error)
+	Complete(ctx context.Context, key domain.IdempotencyKey) error
+	Delete(ctx context.Context, key domain.IdempotencyKey) error
+}
+
+// IdempotencyUsecase is usecase for idempotent retries of requests
+type IdempotencyUsecase struct {
+	srv   IdempotencyService
+	ttl   time.Duration
+	lease time.Duration
+}
+
+// NewIdempotencyUsecase creates new idempotency usecase, keys expire after ttl. Key of request
+// in progress for longer than lease, e.g. of crashed instance, can be taken by retry.
+func NewIdempotencyUsecase(srv IdempotencyService, ttl, lease time.Duration) *IdempotencyUsecase {
+	return &IdempotencyUsecase{srv: srv, ttl: ttl, lease: lease}
+}
+
+// Start starts request with idempotency key. If request with the key was already completed,
+// its stored response is returned and started is false. Key reused with another request is
+// domain.ErrIdempotencyKeyReused, key of request still in progress is domain.ErrIdempotencyKeyInProgress.
+func (u *IdempotencyUsecase) Start(ctx context.Context, key domain.IdempotencyKey) (domain.IdempotencyKey, bool, error) {
+	stored, started, err := u.srv.Start(ctx, key, u.ttl, u.lease)
+	if err != nil || started {
+		return stored, started, err
+	}
+	if stored.Fingerprint != key.Fingerprint {
+		return domain.IdempotencyKey{}, false, domain.ErrIdempotencyKeyReused
+	}
+	if stored.Status == 0 {
+		return domain.IdempotencyKey{}, false, domain.ErrIdempotencyKeyInProgress
+	}
+	return stored, false, nil
+}
+
+// Complete stores response of request
+func (u *IdempotencyUsecase) Complete(ctx context.Context, key domain.IdempotencyKey) error {
+	return u.srv.Complete(ctx, key)
+}
+
+// Abort forgets key of request which failed, so that it can be retried
+func (u *IdempotencyUsecase) Abort(ctx context.Context, key domain.IdempotencyKey) error {
+	return u.srv.Delete(ctx, key)
+}
@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"context"
+	"io"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/google/uuid"
+)
+
+// JobService describes job service
+type JobService interface {
+	Create(ctx context.Context, job domain.Job) (domain.Job, error)
+	Get(ctx context.Context, id uuid.UUID) (domain.Job, error)
+	Cancel(ctx context.Context, id uuid.UUID) (domain.Job, error)
+}
+
+// ArtifactStore describes storage of job files
+type ArtifactStore interface {
+	Save(name string, r io.Reader) (string, error)
+	Remove(path string) error
+}
+
+// JobUsecase is usecase for asynchronous jobs
+type JobUsecase struct {
+	srv         JobService
+	artifacts   ArtifactStore
+	maxAttempts int
+}
+
+// NewJobUsecase creates new job usecase, jobs are attempted up to maxAttempts times
+func NewJobUsecase(srv JobService, artifacts ArtifactStore, maxAttempts int) *JobUsecase {
+	return &JobUsecase{srv: srv, artifacts: artifacts, maxAttempts: maxAttempts}
+}
+
+// Create queues new job, input of import job is stored before job is queued
+func (u *JobUsecase) Create(ctx context.Context, job domain.Job, input io.Reader) (domain.Job, error) {
+	switch job.Type {
+	case domain.JobImport:
+		if input == nil {
+			return domain.Job{}, domain.ErrJobNoInput
+		}
+		path, err := u.artifacts.Save(uuid.NewString()+".input", input)
+		if err != nil {
+			return domain.Job{}, err
+		}
+		job.Input = path
+	case domain.JobExport:
+	default:
+		return domain.Job{}, domain.ErrUnknownJobType
+	}
+	job.MaxAttempts = u.maxAttempts
+	created, err := u.srv.Create(ctx, job)
+	if err != nil {
+		_ = u.artifacts.Remove(job.Input)
+		return domain.Job{}, err
+	}
+	return created, nil
+}
+
+// Get gets job by id
+func (u *JobUsecase) Get(ctx context.Context, id uuid.UUID) (domain.Job, error) {
+	return u.srv.Get(ctx, id)
+}
+
+// Cancel requests cancellation of job
+func (u *JobUsecase) Cancel(ctx context.Context, id uuid.UUID) (domain.Job, error) {
+	return u.srv.Cancel(ctx, id)
+}
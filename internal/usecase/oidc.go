@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+)
+
+// usernameAttempts is number of usernames tried to provision new user of identity provider
+const usernameAttempts = 3
+
+// OIDCService describes service for login with OpenID Connect provider
+type OIDCService interface {
+	AuthCodeURL() (string, error)
+	Exchange(ctx context.Context, code, state string) (domain.User, error)
+}
+
+// OIDCUsecase is usecase for single sign-on with OpenID Connect provider
+type OIDCUsecase struct {
+	oidc  OIDCService
+	auth  AuthService
+	users UserService
+	orgs  OrganizationService
+}
+
+// NewOIDCUsecase creates new OIDC usecase
+func NewOIDCUsecase(oidc OIDCService, auth AuthService, users UserService, orgs OrganizationService) *OIDCUsecase {
+	return &OIDCUsecase{
+		oidc:  oidc,
+		auth:  auth,
+		users: users,
+		orgs:  orgs,
+	}
+}
+
+// LoginURL returns url of provider login page
+func (u *OIDCUsecase) LoginURL() (string, error) {
+	return u.oidc.AuthCodeURL()
+}
+
+// Callback finishes login, provisions user and returns token
+func (u *OIDCUsecase) Callback(ctx context.Context, code, state string) (string, error) {
+	user, err := u.oidc.Exchange(ctx, code, state)
+	if err != nil {
+		return "", err
+	}
+	provisioned, err := u.provision(ctx, user)
+	if err != nil {
+		return "", err
+	}
+	if provisioned.Disabled {
+		return "", domain.ErrUserDisabled
+	}
+	return issueToken(ctx, u.auth, u.orgs, *provisioned)
+}
+
+// provision creates user of identity provider or refreshes it. Local user with the same email
+// is not linked to provider, since provider does not prove that user owns password of local user,
+// domain.ErrOIDCEmailTaken is returned instead. Username taken by another user gets random suffix.
+func (u *OIDCUsecase) provision(ctx context.Context, user domain.User) (*domain.User, error) {
+	for attempt := 1; ; attempt++ {
+		provisioned, err := u.users.CreateUser(ctx, user)
+		if !errors.Is(err, domain.ErrUserExists) {
+			return provisioned, err
+		}
+		existing, getErr := u.users.GetUserByEmail(ctx, user.Email)
+		if getErr == nil && existing.OIDCSubject != user.OIDCSubject {
+			return nil, domain.ErrOIDCEmailTaken
+		}
+		if getErr != nil && !errors.Is(getErr, domain.ErrUserNotFound) {
+			return nil, getErr
+		}
+		if attempt == usernameAttempts {
+			return nil, err
+		}
+		user.Username, err = usernameWithSuffix(user.Username)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// usernameWithSuffix appends random suffix to username keeping it within domain.MaxUsernameLength
+func usernameWithSuffix(username string) (string, error) {
+	b := make([]byte, 2)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	suffix := "-" + hex.EncodeToString(b)
+	if runes := []rune(username); len(runes) > domain.MaxUsernameLength-len(suffix) {
+		username = string(runes[:domain.MaxUsernameLength-len(suffix)])
+	}
+	return username + suffix, nil
+}
@@ -0,0 +1,76 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+)
+
+// OrganizationService describes organization service
+type OrganizationService interface {
+	Create(ctx context.Context, name string) (domain.Organization, error)
+	Select(ctx context.Context) ([]domain.Organization, error)
+	SelectForUser(ctx context.Context, userID int64) ([]domain.Organization, error)
+	IsMember(ctx context.Context, organizationID, userID int64) (bool, error)
+	AddMember(ctx context.Context, organizationID, userID int64) error
+	RemoveMember(ctx context.Context, organizationID, userID int64) error
+}
+
+// OrganizationUsecase is usecase for organizations
+type OrganizationUsecase struct {
+	orgs OrganizationService
+	auth AuthService
+}
+
+// NewOrganizationUsecase creates new organization usecase
+func NewOrganizationUsecase(orgs OrganizationService, auth AuthService) *OrganizationUsecase {
+	return &OrganizationUsecase{orgs: orgs, auth: auth}
+}
+
+// Create creates new organization
+func (u *OrganizationUsecase) Create(ctx context.Context, name string) (domain.Organization, error) {
+	return u.orgs.Create(ctx, name)
+}
+
+// List selects organizations available to user, admins see all organizations
+func (u *OrganizationUsecase) List(ctx context.Context, user domain.User) ([]domain.Organization, error) {
+	if user.Role == domain.RoleAdmin {
+		return u.orgs.Select(ctx)
+	}
+	return u.orgs.SelectForUser(ctx, user.ID)
+}
+
+// AddMember adds user to organization
+func (u *OrganizationUsecase) AddMember(ctx context.Context, organizationID, userID int64) error {
+	return u.orgs.AddMember(ctx, organizationID, userID)
+}
+
+// RemoveMember removes user from organization
+func (u *OrganizationUsecase) RemoveMember(ctx context.Context, organizationID, userID int64) error {
+	return u.orgs.RemoveMember(ctx, organizationID, userID)
+}
+
+// Switch returns token of user scoped to another organization
+func (u *OrganizationUsecase) Switch(ctx context.Context, user domain.User, organizationID int64) (string, error) {
+	member, err := u.orgs.IsMember(ctx, organizationID, user.ID)
+	if err != nil {
+		return "", err
+	}
+	if !member {
+		return "", domain.ErrNotOrganizationMember
+	}
+	user.OrganizationID = organizationID
+	return u.auth.GenerateJWT(user)
+}
+
+// issueToken generates token of user scoped to first organization user is member of
+func issueToken(ctx context.Context, auth AuthService, orgs OrganizationService, user domain.User) (string, error) {
+	userOrgs, err := orgs.SelectForUser(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(userOrgs) > 0 {
+		user.OrganizationID = userOrgs[0].ID
+	}
+	return auth.GenerateJWT(user)
+}
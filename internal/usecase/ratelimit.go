@@ -0,0 +1,27 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/Ragnar-BY/companies-handler/internal/domain"
+)
+
+// RateLimitService describes rate limit service
+type RateLimitService interface {
+	Allow(ctx context.Context, key string, limit domain.RateLimit) domain.RateLimitResult
+}
+
+// RateLimitUsecase is usecase for rate limits of requests
+type RateLimitUsecase struct {
+	srv RateLimitService
+}
+
+// NewRateLimitUsecase creates new rate limit usecase
+func NewRateLimitUsecase(srv RateLimitService) *RateLimitUsecase {
+	return &RateLimitUsecase{srv: srv}
+}
+
+// Allow takes token from bucket of key
+func (u *RateLimitUsecase) Allow(ctx context.Context, key string, limit domain.RateLimit) domain.RateLimitResult {
+	return u.srv.Allow(ctx, key, limit)
+}
@@ -0,0 +1,9 @@
+package usecase
+
+import "context"
+
+// TxManager runs several calls of services atomically. fn can be run more than once,
+// so events are sent after WithinTx returns.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -2,27 +2,188 @@ package usecase
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
 
 	"github.com/Ragnar-BY/companies-handler/internal/domain"
+	"github.com/Ragnar-BY/companies-handler/internal/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	verifyEmailTopic = "verify-email"
+
+	emailVerificationExpireAt = 24 * time.Hour
 )
 
 // UserService describes user service
 type UserService interface {
 	CreateUser(ctx context.Context, u domain.User) (*domain.User, error)
 	GetUserByEmail(ctx context.Context, email string) (*domain.User, error)
+	GetUserByID(ctx context.Context, id int64) (*domain.User, error)
+	UpdateUser(ctx context.Context, u domain.User) (*domain.User, error)
+	DeleteUser(ctx context.Context, id int64) error
+	GetUserByPasswordResetToken(ctx context.Context, token string) (*domain.User, error)
+	SelectUsers(ctx context.Context, filter domain.UserFilter) ([]domain.User, error)
+}
+
+// emailVerification is event sent to new email of user
+type emailVerification struct {
+	UserID int64  `json:"user_id"`
+	Email  string `json:"email"`
+	Token  string `json:"token"`
 }
 
 // UserUsecase is user usecase
 type UserUsecase struct {
-	srv UserService
+	srv    UserService
+	events EventService
+	tx     TxManager
 }
 
 // NewUserUsecase creates new user usecase
-func NewUserUsecase(srv UserService) *UserUsecase {
-	return &UserUsecase{srv: srv}
+func NewUserUsecase(srv UserService, events EventService, tx TxManager) *UserUsecase {
+	return &UserUsecase{srv: srv, events: events, tx: tx}
 }
 
 // CreateUser creates new user
 func (s *UserUsecase) CreateUser(ctx context.Context, u domain.User) (*domain.User, error) {
 	return s.srv.CreateUser(ctx, u)
 }
+
+// Profile gets profile of user
+func (s *UserUsecase) Profile(ctx context.Context, id int64) (*domain.User, error) {
+	return s.srv.GetUserByID(ctx, id)
+}
+
+// UpdateProfile changes username and email of user. Empty values are left unchanged.
+// New email is not applied until it is verified with token sent to it.
+func (s *UserUsecase) UpdateProfile(ctx context.Context, id int64, username, email string) (*domain.User, error) {
+	var token string
+	user, err := s.updateUser(ctx, id, func(user *domain.User) error {
+		if username != "" {
+			user.Username = username
+		}
+		token = ""
+		if email != "" && email != user.Email {
+			var hash string
+			var err error
+			token, hash, err = newVerificationToken()
+			if err != nil {
+				return err
+			}
+			user.PendingEmail = email
+			user.EmailVerificationToken = hash
+			user.EmailVerificationExpiresAt = time.Now().Add(emailVerificationExpireAt)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return user, nil
+	}
+	// profile is already changed, verification can be requested again by changing email
+	err = s.events.SendEvent(ctx, verifyEmailTopic, emailVerification{
+		UserID: user.ID,
+		Email:  user.PendingEmail,
+		Token:  token,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("can not send email verification", zap.Int64("user_id", user.ID), zap.Error(err))
+	}
+	return user, nil
+}
+
+// VerifyEmail applies pending email of user if token matches
+func (s *UserUsecase) VerifyEmail(ctx context.Context, id int64, token string) (*domain.User, error) {
+	return s.updateUser(ctx, id, func(user *domain.User) error {
+		if user.PendingEmail == "" || time.Now().After(user.EmailVerificationExpiresAt) ||
+			subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(user.EmailVerificationToken)) != 1 {
+			return domain.ErrEmailVerificationFail
+		}
+		user.Email = user.PendingEmail
+		user.PendingEmail = ""
+		user.EmailVerificationToken = ""
+		user.EmailVerificationExpiresAt = time.Time{}
+		return nil
+	})
+}
+
+// ChangePassword changes password of user if current password is correct
+func (s *UserUsecase) ChangePassword(ctx context.Context, id int64, currentPassword, newPassword string) error {
+	_, err := s.updateUser(ctx, id, func(user *domain.User) error {
+		if user.CheckPassword(currentPassword) != nil {
+			return domain.ErrInvalidPassword
+		}
+		return user.HashPassword(newPassword)
+	})
+	return err
+}
+
+// ResetPassword sets new password of user with token sent on forced password reset
+func (s *UserUsecase) ResetPassword(ctx context.Context, token, newPassword string) error {
+	return s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		user, err := s.srv.GetUserByPasswordResetToken(ctx, hashToken(token))
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return domain.ErrPasswordResetFail
+		}
+		if err != nil {
+			return err
+		}
+		if time.Now().After(user.PasswordResetExpiresAt) {
+			return domain.ErrPasswordResetFail
+		}
+		err = user.HashPassword(newPassword)
+		if err != nil {
+			return err
+		}
+		user.PasswordResetToken = ""
+		user.PasswordResetExpiresAt = time.Time{}
+		_, err = s.srv.UpdateUser(ctx, *user)
+		return err
+	})
+}
+
+// DeleteAccount deletes user
+func (s *UserUsecase) DeleteAccount(ctx context.Context, id int64) error {
+	return s.srv.DeleteUser(ctx, id)
+}
+
+// updateUser applies change to current state of user in transaction, user is not updated if change fails
+func (s *UserUsecase) updateUser(ctx context.Context, id int64, change func(user *domain.User) error) (*domain.User, error) {
+	var user *domain.User
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		current, err := s.srv.GetUserByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err = change(current); err != nil {
+			return err
+		}
+		user, err = s.srv.UpdateUser(ctx, *current)
+		return err
+	})
+	return user, err
+}
+
+// newVerificationToken generates token to send to user and its hash to store
+func newVerificationToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,13 @@
+-- emails of identity provider can be longer than emails of local users before this migration
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM users WHERE length(email) > 30) THEN
+        RAISE EXCEPTION 'can not revert migration 003: some emails are longer than 30 characters, shorten or delete them first';
+    END IF;
+END $$;
+
+ALTER TABLE users DROP COLUMN IF EXISTS oidc_subject;
+
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+
+ALTER TABLE users ALTER COLUMN email TYPE VARCHAR(30);
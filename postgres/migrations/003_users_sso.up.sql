@@ -0,0 +1,5 @@
+ALTER TABLE users ALTER COLUMN email TYPE VARCHAR(254);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(15) NOT NULL DEFAULT 'user';
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS oidc_subject VARCHAR UNIQUE;
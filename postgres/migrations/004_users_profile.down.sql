@@ -0,0 +1,5 @@
+ALTER TABLE users DROP COLUMN IF EXISTS email_verification_expires_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS email_verification_token;
+
+ALTER TABLE users DROP COLUMN IF EXISTS pending_email;
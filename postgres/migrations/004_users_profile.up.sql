@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email VARCHAR(254);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verification_token VARCHAR;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verification_expires_at TIMESTAMP;
@@ -0,0 +1,5 @@
+ALTER TABLE users DROP COLUMN IF EXISTS password_reset_expires_at;
+
+ALTER TABLE users DROP COLUMN IF EXISTS password_reset_token;
+
+ALTER TABLE users DROP COLUMN IF EXISTS disabled;
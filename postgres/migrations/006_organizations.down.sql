@@ -0,0 +1,16 @@
+-- companies of all organizations are merged into one registry, where names must be unique
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM companies GROUP BY name HAVING count(*) > 1) THEN
+        RAISE EXCEPTION 'can not revert migration 006: organizations have companies with the same name, rename or delete them first';
+    END IF;
+END $$;
+
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_organization_id_name_key;
+ALTER TABLE companies ADD CONSTRAINT companies_name_key UNIQUE (name);
+
+ALTER TABLE companies DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS organization_members;
+
+DROP TABLE IF EXISTS organizations;
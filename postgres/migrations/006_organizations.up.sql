@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS organizations (
+	id serial PRIMARY KEY,
+    name VARCHAR(50) UNIQUE NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    organization_id integer NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, user_id)
+);
+
+-- default organization owns companies and users created before tenants were introduced
+INSERT INTO organizations (id, name) VALUES (1, 'default');
+SELECT setval('organizations_id_seq', 1);
+
+INSERT INTO organization_members (organization_id, user_id) SELECT 1, id FROM users;
+
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS organization_id integer NOT NULL DEFAULT 1 REFERENCES organizations(id);
+ALTER TABLE companies ALTER COLUMN organization_id DROP DEFAULT;
+
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_name_key;
+ALTER TABLE companies ADD CONSTRAINT companies_organization_id_name_key UNIQUE (organization_id, name);
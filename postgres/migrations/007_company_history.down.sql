@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS company_history;
+
+DROP FUNCTION IF EXISTS company_history_append_only();
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS company_history (
+	id bigserial PRIMARY KEY,
+    company_id uuid NOT NULL,
+    organization_id integer NOT NULL,
+    action VARCHAR(15) NOT NULL,
+    before jsonb,
+    after jsonb,
+    actor_id integer,
+    request_id VARCHAR(64),
+	created_at TIMESTAMP NOT NULL DEFAULT clock_timestamp()
+);
+
+CREATE INDEX IF NOT EXISTS company_history_company_id_idx ON company_history (company_id, id);
+
+-- history is append-only
+CREATE OR REPLACE FUNCTION company_history_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'company_history is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER company_history_append_only BEFORE UPDATE OR DELETE ON company_history
+    FOR EACH ROW EXECUTE FUNCTION company_history_append_only();
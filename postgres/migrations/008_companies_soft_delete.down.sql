@@ -0,0 +1,9 @@
+-- irreversible: soft deleted companies are deleted, so that names reused after deletion are unique again
+DELETE FROM companies WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS companies_deleted_at_idx;
+
+DROP INDEX IF EXISTS companies_organization_id_name_key;
+ALTER TABLE companies ADD CONSTRAINT companies_organization_id_name_key UNIQUE (organization_id, name);
+
+ALTER TABLE companies DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,7 @@
+ALTER TABLE companies ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+-- names of deleted companies can be reused
+ALTER TABLE companies DROP CONSTRAINT IF EXISTS companies_organization_id_name_key;
+CREATE UNIQUE INDEX IF NOT EXISTS companies_organization_id_name_key ON companies (organization_id, name) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS companies_deleted_at_idx ON companies (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS jobs (
+    id uuid DEFAULT gen_random_uuid() PRIMARY KEY,
+    organization_id integer NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    user_id integer REFERENCES users (id) ON DELETE SET NULL,
+    type VARCHAR(15) NOT NULL,
+    status VARCHAR(15) NOT NULL DEFAULT 'queued',
+    params jsonb NOT NULL DEFAULT '{}',
+    input VARCHAR,
+    result VARCHAR,
+    progress integer NOT NULL DEFAULT 0,
+    attempts integer NOT NULL DEFAULT 0,
+    max_attempts integer NOT NULL DEFAULT 3,
+    error TEXT,
+    cancel_requested boolean NOT NULL DEFAULT false,
+    run_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS jobs_queued_idx ON jobs (run_at) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS jobs_running_idx ON jobs (updated_at) WHERE status = 'running';
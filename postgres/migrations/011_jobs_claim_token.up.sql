@@ -0,0 +1,2 @@
+-- claim_token is set on every claim of job, worker whose job was claimed again can not update it
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS claim_token uuid;
@@ -0,0 +1,10 @@
+// Package migrations embeds versioned migrations of postgres database,
+// so that server binary can apply them itself
+package migrations
+
+import "embed"
+
+// FS contains migrations named <version>_<title>.up.sql and <version>_<title>.down.sql
+//
+//go:embed *.sql
+var FS embed.FS